@@ -2,8 +2,11 @@ package common
 
 import (
 	"errors"
+	"fmt"
 	"github.com/spf13/afero"
+	"strings"
 	"sync"
+	"time"
 )
 
 type Offset uint64
@@ -16,6 +19,8 @@ var NoBlocksFound = errors.New("no blocks found")
 
 var NoEntriesFound = errors.New("no entries found")
 
+var OffsetBeforeLogStart = errors.New("offset before log start")
+
 type LogBlockPosition struct {
 	Block      LogBlock
 	ByteOffset int64
@@ -24,24 +29,123 @@ type LogBlockPosition struct {
 type EntriesPtr *[][]byte
 
 type ReadLogParams struct {
-	LogChan   chan *[]LogEntry
+	LogChan   chan *EntryBatch
 	Wg        *sync.WaitGroup
 	From      Offset
 	BatchSize uint32
+	// EndOffset is the offset after the last entry to read, MaxEntries and MaxBytes limit the entries and
+	// payload bytes read. MaxBytes is a soft limit, the entry reaching it is the last entry read. Zero is
+	// no limit.
+	EndOffset  Offset
+	MaxEntries uint64
+	MaxBytes   uint64
+	// Filter selects the entries sent, nil sends all entries
+	Filter EntryFilter
 }
 
 type LogEntry struct {
-	Offset   uint64
-	Crc      uint32
-	ByteSize int
-	Entry    []byte
+	Offset    uint64
+	Crc       uint32
+	ByteSize  int
+	Timestamp int64
+	Key       []byte
+	Headers   map[string]string
+	Entry     []byte
+}
+
+// EntryMetadata is the optional key and headers written together with an entry
+type EntryMetadata struct {
+	Key     []byte
+	Headers map[string]string
+}
+
+func (m EntryMetadata) IsEmpty() bool {
+	return len(m.Key) == 0 && len(m.Headers) == 0
+}
+
+// ProducerBatch identifies a batch sent by an idempotent producer, the sequence increases with every
+// new batch sent by the producer and is kept when a batch is retried
+type ProducerBatch struct {
+	ProducerId string
+	Sequence   uint64
+}
+
+type TimeOffsetPtr struct {
+	Timestamp int64
+	Offset    Offset
+}
+
+type Durability int
+
+const (
+	// DurabilityNone leaves flushing of written entries to the operating system
+	DurabilityNone Durability = iota
+	// FsyncPerWrite syncs the log block to disk before every write is acknowledged
+	FsyncPerWrite
+	// GroupCommit coalesces concurrent writes into one sync within a time window
+	GroupCommit
+)
+
+var durabilityNames = map[string]Durability{
+	"none":            DurabilityNone,
+	"fsync-per-write": FsyncPerWrite,
+	"group-commit":    GroupCommit,
+}
+
+func ParseDurability(durability string) (Durability, error) {
+	value, ok := durabilityNames[strings.ToLower(durability)]
+	if !ok {
+		return DurabilityNone, fmt.Errorf("unknown durability [%s], expected one of none, fsync-per-write or group-commit", durability)
+	}
+	return value, nil
+}
+
+func (d *Durability) UnmarshalText(text []byte) error {
+	durability, err := ParseDurability(string(text))
+	if err != nil {
+		return err
+	}
+	*d = durability
+	return nil
+}
+
+// RetentionPolicy limits how much of a topic is kept, sealed blocks are deleted from the
+// start of the log until all limits are satisfied. Zero values are unlimited.
+type RetentionPolicy struct {
+	MaxAge    time.Duration
+	MaxBytes  int64
+	MaxBlocks int
+}
+
+func (r RetentionPolicy) IsEnabled() bool {
+	return r.MaxAge > 0 || r.MaxBytes > 0 || r.MaxBlocks > 0
+}
+
+// CompactionPolicy makes a topic keep only the latest entry for each key in sealed blocks. Entries
+// without a key are never compacted, and a tombstone (a keyed entry without payload) is removed after
+// the grace period, so readers have time to see the delete.
+type CompactionPolicy struct {
+	Enabled              bool
+	TombstoneGracePeriod time.Duration
 }
 
 type TopicParams struct {
-	Afs          *afero.Afero
-	RootPath     string
-	TopicName    string
-	MaxBlockSize int
+	// ReadOnly topics never change files when they are loaded
+	ReadOnly          bool
+	Afs               *afero.Afero
+	RootPath          string
+	TopicName         string
+	MaxBlockSize      int
+	CorruptionPolicy  CorruptionPolicy
+	Durability        Durability
+	GroupCommitWindow time.Duration
+	Retention         RetentionPolicy
+	Compaction        CompactionPolicy
+	Compression       Compression
+	// ProducerExpiry is how long an idempotent producer is remembered after its last write, 0 is forever
+	ProducerExpiry time.Duration
+	// IndexCacheSize is the max bytes of sparse index kept in memory for a topic with its own index cache
+	IndexCacheSize int64
 }
 
 type OffsetFilePtr struct {
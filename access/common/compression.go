@@ -0,0 +1,337 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/spf13/afero"
+	"github.com/tcw/ibsen/errore"
+	"io"
+	"strings"
+)
+
+type Compression byte
+
+const (
+	CompressionNone Compression = iota
+	CompressionGzip
+	CompressionZlib
+	CompressionFlate
+)
+
+var compressionNames = map[string]Compression{
+	"none":  CompressionNone,
+	"gzip":  CompressionGzip,
+	"zlib":  CompressionZlib,
+	"flate": CompressionFlate,
+}
+
+func ParseCompression(compression string) (Compression, error) {
+	value, ok := compressionNames[strings.ToLower(compression)]
+	if !ok {
+		return CompressionNone, fmt.Errorf("unknown compression [%s], expected one of none, gzip, zlib or flate", compression)
+	}
+	return value, nil
+}
+
+func (c *Compression) UnmarshalText(text []byte) error {
+	compression, err := ParseCompression(string(text))
+	if err != nil {
+		return err
+	}
+	*c = compression
+	return nil
+}
+
+var CorruptCompressedBlock = errors.New("corrupt compressed block")
+
+// A compressed log block is a header followed by frames of entries compressed independently of each
+// other, so reading can start at any frame. A frame always starts at an entry that is a point in the
+// sparse offset index, and the index of a compressed block points at frames instead of entries.
+//
+// header: magic (4) | compression (1) | uncompressed block size (8)
+// frame:  first offset in frame (8) | compressed size (8) | compressed entries
+const (
+	CompressedBlockExtension = ".logz"
+	compressedBlockMagic     = "IBSZ"
+	compressedHeaderSize     = 13
+	frameHeaderSize          = 16
+)
+
+func IsCompressedBlock(fileName string) bool {
+	return strings.HasSuffix(fileName, CompressedBlockExtension)
+}
+
+// CompressBlock compresses the entries of an uncompressed log block, starting a new frame at the first
+// index point after a frame has reached frameSize uncompressed bytes. Returns the binary frame index of
+// the compressed block.
+func CompressBlock(block io.Reader, blockSize int64, compressed io.Writer, compression Compression, oneEntryForEvery uint32, frameSize int) ([]byte, error) {
+	writer := &countingWriter{writer: compressed}
+	header := append([]byte(compressedBlockMagic), byte(compression))
+	_, err := writer.Write(append(header, Uint64ToLittleEndian(uint64(blockSize))...))
+	if err != nil {
+		return nil, errore.Wrap(err)
+	}
+	var frameIndex []uint64
+	var frame bytes.Buffer
+	var entryBytes bytes.Buffer
+	reader := io.TeeReader(block, &entryBytes)
+	var byteOffset int64 = 0
+	var frameOffset uint64 = 0
+	var previousOffset uint64 = 0
+	for {
+		entryBytes.Reset()
+		entry, entrySize, err := ReadByteEntry(reader, blockSize-byteOffset)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, &CorruptEntryError{
+				ByteOffset: byteOffset,
+				Offset:     Offset(entry.Offset),
+				Err:        err,
+			}
+		}
+		isIndexPoint := entry.Offset/uint64(oneEntryForEvery) != previousOffset/uint64(oneEntryForEvery)
+		if byteOffset == 0 {
+			frameOffset = entry.Offset
+		} else if isIndexPoint && frame.Len() >= frameSize {
+			frameIndex = append(frameIndex, frameOffset, uint64(writer.written))
+			err = writeFrame(writer, compression, frameOffset, frame.Bytes())
+			if err != nil {
+				return nil, errore.Wrap(err)
+			}
+			frame.Reset()
+			frameOffset = entry.Offset
+		}
+		frame.Write(entryBytes.Bytes())
+		previousOffset = entry.Offset
+		byteOffset = byteOffset + entrySize
+	}
+	if frame.Len() > 0 {
+		frameIndex = append(frameIndex, frameOffset, uint64(writer.written))
+		err = writeFrame(writer, compression, frameOffset, frame.Bytes())
+		if err != nil {
+			return nil, errore.Wrap(err)
+		}
+	}
+	return Uint64ArrayToBytes(frameIndex), nil
+}
+
+type countingWriter struct {
+	writer  io.Writer
+	written int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.written = w.written + int64(n)
+	return n, err
+}
+
+func writeFrame(compressed io.Writer, compression Compression, firstOffset uint64, entries []byte) error {
+	var frame bytes.Buffer
+	writer, err := newCompressionWriter(&frame, compression)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	_, err = writer.Write(entries)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	err = writer.Close()
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	header := append(Uint64ToLittleEndian(firstOffset), Uint64ToLittleEndian(uint64(frame.Len()))...)
+	_, err = compressed.Write(append(header, frame.Bytes()...))
+	return err
+}
+
+func newCompressionWriter(writer io.Writer, compression Compression) (io.WriteCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewWriter(writer), nil
+	case CompressionZlib:
+		return zlib.NewWriter(writer), nil
+	case CompressionFlate:
+		return flate.NewWriter(writer, flate.DefaultCompression)
+	}
+	return nil, errore.NewF("no compression writer for compression %d", compression)
+}
+
+func newDecompressionReader(reader io.Reader, compression Compression) (io.Reader, error) {
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewReader(reader)
+	case CompressionZlib:
+		return zlib.NewReader(reader)
+	case CompressionFlate:
+		return flate.NewReader(reader), nil
+	}
+	return nil, fmt.Errorf("%w: unknown compression %d", CorruptCompressedBlock, compression)
+}
+
+// BlockReader reads the uncompressed entries of a log block from a byte offset in the block file. For
+// compressed blocks the byte offset must be the start of a frame, or zero.
+type BlockReader struct {
+	Reader io.Reader
+	// BytesLeft is an upper bound of the uncompressed bytes left in the block
+	BytesLeft int64
+	frame     []byte
+}
+
+func NewBlockReader(file afero.File, byteOffset int64) (BlockReader, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return BlockReader{}, errore.Wrap(err)
+	}
+	if !IsCompressedBlock(file.Name()) {
+		if byteOffset > 0 {
+			_, err = file.Seek(byteOffset, io.SeekStart)
+			if err != nil {
+				return BlockReader{}, errore.Wrap(err)
+			}
+		}
+		return BlockReader{
+			Reader:    bufio.NewReader(file),
+			BytesLeft: info.Size() - byteOffset,
+			frame:     make([]byte, entryFrameSize),
+		}, nil
+	}
+	header, err := readCompressedHeader(file)
+	if err != nil {
+		return BlockReader{}, err
+	}
+	if byteOffset < compressedHeaderSize {
+		byteOffset = compressedHeaderSize
+	}
+	_, err = file.Seek(byteOffset, io.SeekStart)
+	if err != nil {
+		return BlockReader{}, errore.Wrap(err)
+	}
+	return BlockReader{
+		Reader: &frameReader{
+			file:        bufio.NewReader(file),
+			compression: Compression(header[4]),
+		},
+		BytesLeft: int64(binary.LittleEndian.Uint64(header[5:13])),
+		frame:     make([]byte, entryFrameSize),
+	}, nil
+}
+
+// BlockCompression reads the compression used in a compressed block
+func BlockCompression(afs *afero.Afero, fileName string) (Compression, error) {
+	file, err := OpenFileForRead(afs, fileName)
+	if err != nil {
+		return CompressionNone, errore.Wrap(err)
+	}
+	defer file.Close()
+	header, err := readCompressedHeader(file)
+	if err != nil {
+		return CompressionNone, err
+	}
+	return Compression(header[4]), nil
+}
+
+func readCompressedHeader(file afero.File) ([]byte, error) {
+	header := make([]byte, compressedHeaderSize)
+	_, err := file.ReadAt(header, 0)
+	if err != nil || string(header[:4]) != compressedBlockMagic {
+		return nil, fmt.Errorf("%w: invalid header in %s", CorruptCompressedBlock, file.Name())
+	}
+	return header, nil
+}
+
+// Next reads the next entry, see ReadByteEntry
+func (r *BlockReader) Next() (LogEntry, int64, error) {
+	entry, entryBytes, err := ReadByteEntry(r.Reader, r.BytesLeft)
+	r.BytesLeft = r.BytesLeft - entryBytes
+	return entry, entryBytes, err
+}
+
+// NextInto reads the next entry like Next, with the entry bytes read into the batch. The entry is
+// kept in the batch if it is added, the bytes are reused by the next read otherwise.
+func (r *BlockReader) NextInto(batch *EntryBatch) (LogEntry, int64, error) {
+	entry, entryBytes, err := readByteEntry(r.Reader, r.BytesLeft, r.frame, batch.reserve)
+	r.BytesLeft = r.BytesLeft - entryBytes
+	return entry, entryBytes, err
+}
+
+// frameReader decompresses one frame at a time, entries never span frames
+type frameReader struct {
+	file        *bufio.Reader
+	compression Compression
+	frame       io.Reader
+	header      [frameHeaderSize]byte
+}
+
+func (r *frameReader) Read(p []byte) (int, error) {
+	for {
+		if r.frame == nil {
+			header := r.header[:]
+			_, err := io.ReadFull(r.file, header)
+			if err == io.EOF {
+				return 0, io.EOF
+			}
+			if err != nil {
+				return 0, fmt.Errorf("%w: %s", CorruptCompressedBlock, err)
+			}
+			compressedSize := binary.LittleEndian.Uint64(header[8:])
+			r.frame, err = newDecompressionReader(io.LimitReader(r.file, int64(compressedSize)), r.compression)
+			if err != nil {
+				return 0, fmt.Errorf("%w: %s", CorruptCompressedBlock, err)
+			}
+		}
+		n, err := r.frame.Read(p)
+		if err == io.EOF {
+			r.frame = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		if err != nil {
+			return n, fmt.Errorf("%w: %s", CorruptCompressedBlock, err)
+		}
+		return n, nil
+	}
+}
+
+// FrameIndex reads the binary frame index from the frame headers of a compressed block
+func FrameIndex(afs *afero.Afero, fileName string) ([]byte, error) {
+	file, err := OpenFileForRead(afs, fileName)
+	if err != nil {
+		return nil, errore.Wrap(err)
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	_, err = reader.Discard(compressedHeaderSize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid header in %s", CorruptCompressedBlock, fileName)
+	}
+	var frameIndex []uint64
+	byteOffset := int64(compressedHeaderSize)
+	header := make([]byte, frameHeaderSize)
+	for {
+		_, err = io.ReadFull(reader, header)
+		if err == io.EOF {
+			return Uint64ArrayToBytes(frameIndex), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", CorruptCompressedBlock, err)
+		}
+		compressedSize := int64(binary.LittleEndian.Uint64(header[8:]))
+		frameIndex = append(frameIndex, binary.LittleEndian.Uint64(header[:8]), uint64(byteOffset))
+		_, err = reader.Discard(int(compressedSize))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", CorruptCompressedBlock, err)
+		}
+		byteOffset = byteOffset + frameHeaderSize + compressedSize
+	}
+}
@@ -0,0 +1,60 @@
+package common
+
+import "sync"
+
+// EntryBatch is a batch of entries read from the log. Batches are pooled, the entries and the bytes
+// they point to are reused after Release, so they must not be used after the batch is released.
+// Batches not released are garbage collected as usual.
+type EntryBatch struct {
+	Entries []LogEntry
+	// arena holds the bytes of all entries in the batch
+	arena    []byte
+	reserved int
+}
+
+// maxPooledArenaBytes keeps batches of unusually large entries from pinning memory in the pool
+const maxPooledArenaBytes = 16 * 1024 * 1024
+
+var entryBatches = sync.Pool{
+	New: func() any {
+		return &EntryBatch{}
+	},
+}
+
+// NewEntryBatch returns an empty batch from the pool
+func NewEntryBatch() *EntryBatch {
+	return entryBatches.Get().(*EntryBatch)
+}
+
+// Add appends the entry, and keeps the bytes it was read into
+func (b *EntryBatch) Add(entry LogEntry) {
+	b.arena = b.arena[:len(b.arena)+b.reserved]
+	b.reserved = 0
+	b.Entries = append(b.Entries, entry)
+}
+
+// Release returns the batch to the pool
+func (b *EntryBatch) Release() {
+	for i := range b.Entries {
+		b.Entries[i] = LogEntry{}
+	}
+	b.Entries = b.Entries[:0]
+	b.arena = b.arena[:0]
+	b.reserved = 0
+	if cap(b.arena) > maxPooledArenaBytes {
+		return
+	}
+	entryBatches.Put(b)
+}
+
+// reserve returns size bytes after the bytes of the entries in the batch, entries already in the batch
+// keep pointing to the old bytes if the arena has to grow
+func (b *EntryBatch) reserve(size int) []byte {
+	if cap(b.arena)-len(b.arena) < size {
+		arena := make([]byte, len(b.arena), 2*cap(b.arena)+size)
+		copy(arena, b.arena)
+		b.arena = arena
+	}
+	b.reserved = size
+	return b.arena[len(b.arena) : len(b.arena)+size]
+}
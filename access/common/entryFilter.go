@@ -0,0 +1,89 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// EntryFilter selects the entries a read sends by their payload. Filters are evaluated before entries
+// are batched, so batch sizes and read limits count the entries that match.
+type EntryFilter func(payload []byte) bool
+
+// AllFilters matches entries matching all filters, it is nil if there are no filters
+func AllFilters(filters ...EntryFilter) EntryFilter {
+	if len(filters) == 0 {
+		return nil
+	}
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	return func(payload []byte) bool {
+		for _, filter := range filters {
+			if !filter(payload) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func PrefixFilter(prefix []byte) EntryFilter {
+	return func(payload []byte) bool {
+		return bytes.HasPrefix(payload, prefix)
+	}
+}
+
+func ContainsFilter(substring []byte) EntryFilter {
+	return func(payload []byte) bool {
+		return bytes.Contains(payload, substring)
+	}
+}
+
+func RegexFilter(expression string) (EntryFilter, error) {
+	regex, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+	return regex.Match, nil
+}
+
+// JSONFieldFilter matches JSON objects where the field equals value. Fields of nested objects are
+// separated with dots. Strings are compared with their content, numbers, booleans and null with their
+// JSON text, so 42 does not match 42.0. Payloads that are not JSON objects never match.
+func JSONFieldFilter(field string, value string) EntryFilter {
+	path := strings.Split(field, ".")
+	return func(payload []byte) bool {
+		decoder := json.NewDecoder(bytes.NewReader(payload))
+		decoder.UseNumber()
+		var document any
+		if decoder.Decode(&document) != nil {
+			return false
+		}
+		for _, name := range path {
+			object, isObject := document.(map[string]any)
+			if !isObject {
+				return false
+			}
+			var found bool
+			document, found = object[name]
+			if !found {
+				return false
+			}
+		}
+		switch fieldValue := document.(type) {
+		case string:
+			return fieldValue == value
+		case json.Number:
+			return fieldValue.String() == value
+		case bool:
+			return strconv.FormatBool(fieldValue) == value
+		case nil:
+			return value == "null"
+		default:
+			return false
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package common
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/tcw/ibsen/utils"
+	"hash/crc32"
+	"io"
+	"sort"
+	"strings"
+)
+
+type CorruptionPolicy int
+
+const (
+	// FailOnCorruption aborts the read with an error at the first corrupt entry
+	FailOnCorruption CorruptionPolicy = iota
+	// SkipCorrupted logs and skips corrupt entries as long as the entry framing is intact
+	SkipCorrupted
+	// StopAtCorruption delivers all entries up to the last good entry and then ends the read
+	StopAtCorruption
+)
+
+var corruptionPolicyNames = map[string]CorruptionPolicy{
+	"fail": FailOnCorruption,
+	"skip": SkipCorrupted,
+	"stop": StopAtCorruption,
+}
+
+func ParseCorruptionPolicy(policy string) (CorruptionPolicy, error) {
+	value, ok := corruptionPolicyNames[strings.ToLower(policy)]
+	if !ok {
+		return FailOnCorruption, fmt.Errorf("unknown corruption policy [%s], expected one of fail, skip or stop", policy)
+	}
+	return value, nil
+}
+
+var ChecksumMismatch = errors.New("checksum mismatch")
+
+var InvalidEntrySize = errors.New("invalid entry size")
+
+// TruncatedEntry is an entry running past the end of the block, as left behind by an interrupted write
+var TruncatedEntry = fmt.Errorf("%w: entry runs past the end of the block", InvalidEntrySize)
+
+var InvalidEntryMetadata = errors.New("invalid entry metadata")
+
+// CorruptEntryError describes where in a topic a corrupt entry was found.
+// Stopped is set when the read was ended gracefully at the last good entry.
+type CorruptEntryError struct {
+	Topic      string
+	Block      LogBlock
+	ByteOffset int64
+	Offset     Offset
+	Stopped    bool
+	Err        error
+}
+
+func (e *CorruptEntryError) Error() string {
+	return fmt.Sprintf("corrupt entry in topic [%s] block [%d] at offset [%d] (byte offset %d): %s",
+		e.Topic, e.Block, e.Offset, e.ByteOffset, e.Err)
+}
+
+func (e *CorruptEntryError) Unwrap() error {
+	return e.Err
+}
+
+// The entry format version is stored in the most significant byte of the size field, the
+// remaining bytes hold the size of everything between the size field and the trailing offset.
+// Blocks written before versioning only contain EntryFormatV0 entries.
+const (
+	// EntryFormatV0 is crc, size, payload, offset
+	EntryFormatV0 byte = 0
+	// EntryFormatV1 is crc, size, timestamp, payload, offset
+	EntryFormatV1 byte = 1
+	// EntryFormatV2 is crc, size, timestamp, key, headers, payload, offset
+	EntryFormatV2 byte = 2
+)
+
+const entrySizeMask = 1<<56 - 1
+
+// EntryBodySize returns the number of bytes between the size field and the trailing offset
+func EntryBodySize(sizeField uint64) uint64 {
+	return sizeField & entrySizeMask
+}
+
+func EntryFormat(sizeField uint64) byte {
+	return byte(sizeField >> 56)
+}
+
+// CreateTimestampedByteEntry creates an EntryFormatV1 entry with the time it was written in unix nanoseconds
+func CreateTimestampedByteEntry(entry []byte, currentOffset Offset, timestamp int64) []byte {
+	offset := Uint64ToLittleEndian(uint64(currentOffset))
+	bodySize := 8 + len(entry)
+	byteSize := Uint64ToLittleEndian(uint64(EntryFormatV1)<<56 | uint64(bodySize))
+	timestampBytes := Uint64ToLittleEndian(uint64(timestamp))
+	checksum := crc32.Checksum(byteSize, crc32q)
+	checksum = crc32.Update(checksum, crc32q, timestampBytes)
+	checksum = crc32.Update(checksum, crc32q, entry)
+	checksum = crc32.Update(checksum, crc32q, offset)
+	check := Uint32ToLittleEndian(checksum)
+	return utils.JoinSize(20+bodySize, check, byteSize, timestampBytes, entry, offset)
+}
+
+// CreateByteEntryWithMetadata creates an EntryFormatV2 entry if the entry has a key or headers,
+// and falls back to the more compact EntryFormatV1 otherwise.
+// Key and headers are stored as uvarint length prefixed fields, with headers sorted by name.
+func CreateByteEntryWithMetadata(entry []byte, currentOffset Offset, timestamp int64, metadata EntryMetadata) []byte {
+	if metadata.IsEmpty() {
+		return CreateTimestampedByteEntry(entry, currentOffset, timestamp)
+	}
+	offset := Uint64ToLittleEndian(uint64(currentOffset))
+	timestampBytes := Uint64ToLittleEndian(uint64(timestamp))
+	encodedMetadata := encodeMetadata(metadata)
+	bodySize := 8 + len(encodedMetadata) + len(entry)
+	byteSize := Uint64ToLittleEndian(uint64(EntryFormatV2)<<56 | uint64(bodySize))
+	checksum := crc32.Checksum(byteSize, crc32q)
+	checksum = crc32.Update(checksum, crc32q, timestampBytes)
+	checksum = crc32.Update(checksum, crc32q, encodedMetadata)
+	checksum = crc32.Update(checksum, crc32q, entry)
+	checksum = crc32.Update(checksum, crc32q, offset)
+	check := Uint32ToLittleEndian(checksum)
+	return utils.JoinSize(20+bodySize, check, byteSize, timestampBytes, encodedMetadata, entry, offset)
+}
+
+// EntryFrameSize returns the number of bytes AppendEntryFrame appends for the entry
+func EntryFrameSize(entry []byte, metadata EntryMetadata) int {
+	return ByteEntrySize(entry, metadata) - len(entry)
+}
+
+// AppendEntryFrame appends everything but the payload of the entry created by CreateByteEntryWithMetadata
+// to frame: the header (crc, size, timestamp and metadata) followed by the trailing offset. The entry
+// is written as header, payload and trailing offset, without copying the payload.
+func AppendEntryFrame(frame []byte, entry []byte, currentOffset Offset, timestamp int64, metadata EntryMetadata) ([]byte, int) {
+	format := EntryFormatV2
+	if metadata.IsEmpty() {
+		format = EntryFormatV1
+	}
+	start := len(frame)
+	frame = append(frame, 0, 0, 0, 0)
+	bodyStart := len(frame)
+	frame = binary.LittleEndian.AppendUint64(frame, 0)
+	frame = binary.LittleEndian.AppendUint64(frame, uint64(timestamp))
+	if format == EntryFormatV2 {
+		frame = appendMetadata(frame, metadata)
+	}
+	bodySize := len(frame) - bodyStart - 8 + len(entry)
+	binary.LittleEndian.PutUint64(frame[bodyStart:], uint64(format)<<56|uint64(bodySize))
+	headerSize := len(frame) - start
+	frame = binary.LittleEndian.AppendUint64(frame, uint64(currentOffset))
+	checksum := crc32.Checksum(frame[bodyStart:start+headerSize], crc32q)
+	checksum = crc32.Update(checksum, crc32q, entry)
+	checksum = crc32.Update(checksum, crc32q, frame[start+headerSize:])
+	binary.LittleEndian.PutUint32(frame[start:], checksum)
+	return frame, headerSize
+}
+
+// ByteEntrySize returns the number of bytes CreateByteEntryWithMetadata will use for the entry
+func ByteEntrySize(entry []byte, metadata EntryMetadata) int {
+	if metadata.IsEmpty() {
+		return 28 + len(entry)
+	}
+	return 28 + metadataSize(metadata) + len(entry)
+}
+
+func metadataSize(metadata EntryMetadata) int {
+	size := uvarintSize(uint64(len(metadata.Key))) + len(metadata.Key) + uvarintSize(uint64(len(metadata.Headers)))
+	for name, value := range metadata.Headers {
+		size = size + uvarintSize(uint64(len(name))) + len(name) + uvarintSize(uint64(len(value))) + len(value)
+	}
+	return size
+}
+
+func uvarintSize(value uint64) int {
+	size := 1
+	for value >= 0x80 {
+		value >>= 7
+		size++
+	}
+	return size
+}
+
+func encodeMetadata(metadata EntryMetadata) []byte {
+	return appendMetadata(make([]byte, 0, metadataSize(metadata)), metadata)
+}
+
+func appendMetadata(bytes []byte, metadata EntryMetadata) []byte {
+	bytes = binary.AppendUvarint(bytes, uint64(len(metadata.Key)))
+	bytes = append(bytes, metadata.Key...)
+	bytes = binary.AppendUvarint(bytes, uint64(len(metadata.Headers)))
+	names := make([]string, 0, len(metadata.Headers))
+	for name := range metadata.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := metadata.Headers[name]
+		bytes = binary.AppendUvarint(bytes, uint64(len(name)))
+		bytes = append(bytes, name...)
+		bytes = binary.AppendUvarint(bytes, uint64(len(value)))
+		bytes = append(bytes, value...)
+	}
+	return bytes
+}
+
+// decodeMetadata reads key and headers from the start of a EntryFormatV2 body (after the timestamp)
+// and returns the remaining payload
+func decodeMetadata(body []byte) (EntryMetadata, []byte, error) {
+	key, rest, err := readLengthPrefixed(body)
+	if err != nil {
+		return EntryMetadata{}, nil, err
+	}
+	headerCount, n := binary.Uvarint(rest)
+	if n <= 0 || headerCount > uint64(len(rest)) {
+		return EntryMetadata{}, nil, InvalidEntryMetadata
+	}
+	rest = rest[n:]
+	metadata := EntryMetadata{}
+	if len(key) > 0 {
+		metadata.Key = key
+	}
+	if headerCount > 0 {
+		metadata.Headers = make(map[string]string, headerCount)
+	}
+	for i := uint64(0); i < headerCount; i++ {
+		var name, value []byte
+		name, rest, err = readLengthPrefixed(rest)
+		if err != nil {
+			return EntryMetadata{}, nil, err
+		}
+		value, rest, err = readLengthPrefixed(rest)
+		if err != nil {
+			return EntryMetadata{}, nil, err
+		}
+		metadata.Headers[string(name)] = string(value)
+	}
+	return metadata, rest, nil
+}
+
+func readLengthPrefixed(bytes []byte) ([]byte, []byte, error) {
+	length, n := binary.Uvarint(bytes)
+	if n <= 0 || length > uint64(len(bytes)-n) {
+		return nil, nil, InvalidEntryMetadata
+	}
+	end := n + int(length)
+	return bytes[n:end], bytes[end:], nil
+}
+
+// ReadByteEntry reads and verifies one entry in any entry format, and returns the
+// number of bytes consumed. bytesLeft is the number of bytes remaining in the file and
+// guards against allocating for a corrupt size field.
+// On ChecksumMismatch the entry and byte count are still returned, so the caller can skip it.
+func ReadByteEntry(reader io.Reader, bytesLeft int64) (LogEntry, int64, error) {
+	return readByteEntry(reader, bytesLeft, make([]byte, entryFrameSize), func(size int) []byte {
+		return make([]byte, size)
+	})
+}
+
+// entryFrameSize is the size of the crc, size and offset fields, which are read into a frame buffer
+const entryFrameSize = 20
+
+// readByteEntry reads the fixed size fields into frame, and the body into the buffer from allocate
+func readByteEntry(reader io.Reader, bytesLeft int64, frame []byte, allocate func(size int) []byte) (LogEntry, int64, error) {
+	header := frame[:12]
+	_, err := io.ReadFull(reader, header)
+	if err == io.EOF {
+		return LogEntry{}, 0, io.EOF
+	}
+	if err == io.ErrUnexpectedEOF {
+		return LogEntry{}, 0, TruncatedEntry
+	}
+	if err != nil {
+		return LogEntry{}, 0, err
+	}
+	checksum := binary.LittleEndian.Uint32(header[:4])
+	sizeField := binary.LittleEndian.Uint64(header[4:12])
+	size := EntryBodySize(sizeField)
+	format := EntryFormat(sizeField)
+	if format > EntryFormatV2 {
+		return LogEntry{}, 0, InvalidEntrySize
+	}
+	if bytesLeft < 20 || size > uint64(bytesLeft-20) {
+		return LogEntry{}, 0, TruncatedEntry
+	}
+	if format >= EntryFormatV1 && size < 8 {
+		return LogEntry{}, 0, InvalidEntrySize
+	}
+	body := allocate(int(size))
+	_, err = io.ReadFull(reader, body)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return LogEntry{}, 0, TruncatedEntry
+	}
+	if err != nil {
+		return LogEntry{}, 0, err
+	}
+	offsetBytes := frame[12:20]
+	_, err = io.ReadFull(reader, offsetBytes)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return LogEntry{}, 0, TruncatedEntry
+	}
+	if err != nil {
+		return LogEntry{}, 0, err
+	}
+	logEntry := LogEntry{
+		Offset: binary.LittleEndian.Uint64(offsetBytes),
+		Crc:    checksum,
+		Entry:  body,
+	}
+	if format >= EntryFormatV1 {
+		logEntry.Timestamp = int64(binary.LittleEndian.Uint64(body[:8]))
+		logEntry.Entry = body[8:]
+	}
+	logEntry.ByteSize = len(logEntry.Entry)
+	consumed := int64(20 + size)
+	computed := crc32.Checksum(header[4:12], crc32q)
+	computed = crc32.Update(computed, crc32q, body)
+	computed = crc32.Update(computed, crc32q, offsetBytes)
+	if computed != checksum {
+		return logEntry, consumed, ChecksumMismatch
+	}
+	if format == EntryFormatV2 {
+		metadata, payload, err := decodeMetadata(logEntry.Entry)
+		if err != nil {
+			return logEntry, consumed, err
+		}
+		logEntry.Key = metadata.Key
+		logEntry.Headers = metadata.Headers
+		logEntry.Entry = payload
+		logEntry.ByteSize = len(payload)
+	}
+	return logEntry, consumed, nil
+}
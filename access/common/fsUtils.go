@@ -44,6 +44,23 @@ func OpenFileForRead(afs *afero.Afero, fileName string) (afero.File, error) {
 	return f, nil
 }
 
+// SyncDirectory makes creation of new files in a directory durable
+func SyncDirectory(afs *afero.Afero, path string) error {
+	dir, err := afs.Open(path)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	err = dir.Sync()
+	if err != nil {
+		ioErr := dir.Close()
+		if ioErr != nil {
+			return errore.WrapError(ioErr, err)
+		}
+		return errore.Wrap(err)
+	}
+	return dir.Close()
+}
+
 func CreateByteEntry(entry []byte, currentOffset Offset) []byte {
 	offset := Uint64ToLittleEndian(uint64(currentOffset))
 	entrySize := len(entry)
@@ -74,3 +91,27 @@ func Uint32ToLittleEndian(number uint32) []byte {
 	binary.LittleEndian.PutUint32(bytes, number)
 	return bytes
 }
+
+func writeBuffers(file afero.File, buffers [][]byte) (int, error) {
+	written := 0
+	for _, buffer := range buffers {
+		n, err := file.Write(buffer)
+		written = written + n
+		if err != nil {
+			return written, errore.Wrap(err)
+		}
+	}
+	return written, nil
+}
+
+// skipWritten drops the first n bytes from the buffers
+func skipWritten(buffers [][]byte, n int) [][]byte {
+	for len(buffers) > 0 && n >= len(buffers[0]) {
+		n = n - len(buffers[0])
+		buffers = buffers[1:]
+	}
+	if len(buffers) > 0 {
+		buffers[0] = buffers[0][n:]
+	}
+	return buffers
+}
@@ -0,0 +1,52 @@
+package common
+
+import (
+	"github.com/spf13/afero"
+	"github.com/tcw/ibsen/errore"
+	"golang.org/x/sys/unix"
+	"os"
+)
+
+// maxIovecs is the max number of buffers in one writev call (IOV_MAX)
+const maxIovecs = 1024
+
+// WriteVectored writes the buffers in order, using writev for files on disk. The buffers slice is
+// modified if the write is partial.
+func WriteVectored(file afero.File, buffers [][]byte) (int, error) {
+	osFile, ok := file.(*os.File)
+	if !ok {
+		return writeBuffers(file, buffers)
+	}
+	rawConn, err := osFile.SyscallConn()
+	if err != nil {
+		return 0, errore.Wrap(err)
+	}
+	written := 0
+	var writeErr error
+	err = rawConn.Write(func(fd uintptr) bool {
+		for len(buffers) > 0 {
+			chunk := buffers
+			if len(chunk) > maxIovecs {
+				chunk = chunk[:maxIovecs]
+			}
+			n, err := unix.Writev(int(fd), chunk)
+			if err == unix.EINTR {
+				continue
+			}
+			if err != nil {
+				writeErr = err
+				return true
+			}
+			written = written + n
+			buffers = skipWritten(buffers, n)
+		}
+		return true
+	})
+	if err != nil {
+		return written, errore.Wrap(err)
+	}
+	if writeErr != nil {
+		return written, errore.Wrap(writeErr)
+	}
+	return written, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package common
+
+import (
+	"github.com/spf13/afero"
+)
+
+// WriteVectored writes the buffers in order
+func WriteVectored(file afero.File, buffers [][]byte) (int, error) {
+	return writeBuffers(file, buffers)
+}
@@ -0,0 +1,48 @@
+package access
+
+import (
+	"sync"
+	"time"
+)
+
+// groupCommit coalesces durability requests from concurrent writers into a single sync.
+// The first writer waiting schedules a sync after the commit window, every writer
+// registered before the sync starts is acknowledged by it.
+type groupCommit struct {
+	window  time.Duration
+	sync    func() error
+	mutex   sync.Mutex
+	waiting []chan error
+	pending bool
+}
+
+func newGroupCommit(window time.Duration, sync func() error) *groupCommit {
+	return &groupCommit{
+		window: window,
+		sync:   sync,
+	}
+}
+
+func (g *groupCommit) await() error {
+	done := make(chan error, 1)
+	g.mutex.Lock()
+	g.waiting = append(g.waiting, done)
+	if !g.pending {
+		g.pending = true
+		time.AfterFunc(g.window, g.flush)
+	}
+	g.mutex.Unlock()
+	return <-done
+}
+
+func (g *groupCommit) flush() {
+	g.mutex.Lock()
+	waiting := g.waiting
+	g.waiting = nil
+	g.pending = false
+	g.mutex.Unlock()
+	err := g.sync()
+	for _, done := range waiting {
+		done <- err
+	}
+}
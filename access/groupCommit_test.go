@@ -0,0 +1,53 @@
+package access
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/tcw/ibsen/access/common"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupCommit_coalesces_waiting_writers(t *testing.T) {
+	var syncs int32
+	commit := newGroupCommit(20*time.Millisecond, func() error {
+		atomic.AddInt32(&syncs, 1)
+		return nil
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, commit.await())
+		}()
+	}
+	wg.Wait()
+	assert.Less(t, atomic.LoadInt32(&syncs), int32(50))
+	assert.GreaterOrEqual(t, atomic.LoadInt32(&syncs), int32(1))
+}
+
+func TestTopic_Write_durability_modes(t *testing.T) {
+	for _, durability := range []common.Durability{common.DurabilityNone, common.FsyncPerWrite, common.GroupCommit} {
+		afs := common.MemAfs()
+		topic := NewLogTopic(common.TopicParams{
+			Afs:               afs,
+			RootPath:          "tmp",
+			TopicName:         "topic1",
+			MaxBlockSize:      100,
+			Durability:        durability,
+			GroupCommitWindow: time.Millisecond,
+		})
+		err := topic.LoadOrCreate()
+		assert.Nil(t, err)
+		for i := 0; i < 5; i++ {
+			_, err = topic.Write(createInputEntries(10), nil)
+			assert.Nil(t, err)
+			err = topic.AwaitDurable()
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, common.Offset(50), topic.NextOffset)
+		assert.Greater(t, len(topic.LogBlocks()), 1)
+	}
+}
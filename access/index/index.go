@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"github.com/tcw/ibsen/access/common"
+	"sort"
 )
 
 type Index struct {
@@ -49,15 +50,15 @@ func (idx *Index) ToString() string {
 	return indexToString
 }
 
-// Todo: this is linear search, should use range tree for large indices
+// FindNearestByteOffset finds the last index point at or before offset, index points are ordered by offset
 func (idx *Index) FindNearestByteOffset(offset common.Offset) common.OffsetFilePtr {
-	for i := len(idx.IndexOffsets) - 1; i >= 0; i-- {
-		if offset >= idx.IndexOffsets[i].Offset {
-			byteOffset := idx.IndexOffsets[i]
-			return byteOffset
-		}
+	i := sort.Search(len(idx.IndexOffsets), func(i int) bool {
+		return idx.IndexOffsets[i].Offset > offset
+	})
+	if i == 0 {
+		return common.OffsetFilePtr{}
 	}
-	return common.OffsetFilePtr{}
+	return idx.IndexOffsets[i-1]
 }
 
 func (idx *Index) add(pair common.OffsetFilePtr) {
@@ -0,0 +1,167 @@
+package index
+
+import (
+	"container/list"
+	"github.com/tcw/ibsen/access/common"
+	"sync"
+)
+
+const indexPointSize = 16
+
+// Cache holds the sparse indices of the index blocks of all topics in memory, and evicts the least
+// recently used index blocks when the indices take up more than the memory budget.
+//
+// A cached index is never changed after it is handed out, Append replaces it with an extended copy.
+type Cache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	blocks   map[cacheKey]*list.Element
+	loading  map[cacheKey]*pendingLoad
+	lru      *list.List
+}
+
+// TopicCache is the part of the cache holding the indices of one topic
+type TopicCache struct {
+	cache *Cache
+	topic string
+}
+
+type cacheKey struct {
+	topic string
+	block common.IndexBlock
+}
+
+type cachedIndex struct {
+	key   cacheKey
+	index *Index
+}
+
+// pendingLoad is an index file being read, readers of the same index block wait for it. A load made
+// stale by Append or Remove is returned to its readers, but is not cached.
+type pendingLoad struct {
+	done  chan struct{}
+	index *Index
+	err   error
+	stale bool
+}
+
+// NewCache creates a cache bounded by maxBytes, a cache with a budget of zero bytes holds nothing
+func NewCache(maxBytes int64) *Cache {
+	return &Cache{
+		maxBytes: maxBytes,
+		blocks:   make(map[cacheKey]*list.Element),
+		loading:  make(map[cacheKey]*pendingLoad),
+		lru:      list.New(),
+	}
+}
+
+// ForTopic returns the part of the cache holding the indices of the topic
+func (c *Cache) ForTopic(topic string) *TopicCache {
+	return &TopicCache{cache: c, topic: topic}
+}
+
+// GetOrLoad returns the cached index of the index block, or caches the index returned by load. The
+// index file is read without holding the cache lock, and only once for concurrent readers.
+func (tc *TopicCache) GetOrLoad(block common.IndexBlock, load func() ([]byte, error)) (*Index, error) {
+	c := tc.cache
+	key := cacheKey{topic: tc.topic, block: block}
+	c.mu.Lock()
+	if element, ok := c.blocks[key]; ok {
+		c.lru.MoveToFront(element)
+		c.mu.Unlock()
+		return element.Value.(*cachedIndex).index, nil
+	}
+	if pending, ok := c.loading[key]; ok {
+		c.mu.Unlock()
+		<-pending.done
+		return pending.index, pending.err
+	}
+	pending := &pendingLoad{done: make(chan struct{})}
+	c.loading[key] = pending
+	c.mu.Unlock()
+
+	bytes, err := load()
+
+	c.mu.Lock()
+	delete(c.loading, key)
+	if err != nil {
+		pending.err = err
+	} else {
+		pending.index = NewIndex(bytes)
+		if !pending.stale {
+			c.put(key, pending.index)
+		}
+	}
+	c.mu.Unlock()
+	close(pending.done)
+	return pending.index, pending.err
+}
+
+// Append adds the index points written to the index block file, points already in the cached index are
+// skipped. Nothing is cached if the index block is not already cached.
+func (tc *TopicCache) Append(block common.IndexBlock, bytes []byte) {
+	c := tc.cache
+	key := cacheKey{topic: tc.topic, block: block}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pending, ok := c.loading[key]; ok {
+		pending.stale = true
+	}
+	element, ok := c.blocks[key]
+	if !ok {
+		return
+	}
+	cached := element.Value.(*cachedIndex)
+	added := NewIndex(bytes).IndexOffsets
+	for len(added) > 0 && !cached.index.IsEmpty() && added[0].Offset <= cached.index.Head().Offset {
+		added = added[1:]
+	}
+	if len(added) == 0 {
+		return
+	}
+	extended := make([]common.OffsetFilePtr, 0, cached.index.Size()+len(added))
+	extended = append(extended, cached.index.IndexOffsets...)
+	extended = append(extended, added...)
+	c.remove(element)
+	c.put(key, &Index{IndexOffsets: extended})
+}
+
+// Remove drops the cached index of an index block that was rewritten or deleted
+func (tc *TopicCache) Remove(block common.IndexBlock) {
+	c := tc.cache
+	key := cacheKey{topic: tc.topic, block: block}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if pending, ok := c.loading[key]; ok {
+		pending.stale = true
+	}
+	if element, ok := c.blocks[key]; ok {
+		c.remove(element)
+	}
+}
+
+// Bytes is the memory used by the cached index points of all topics
+func (c *Cache) Bytes() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.bytes
+}
+
+func (c *Cache) put(key cacheKey, idx *Index) {
+	size := int64(idx.Size() * indexPointSize)
+	if size > c.maxBytes {
+		return
+	}
+	for c.bytes+size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+	c.blocks[key] = c.lru.PushFront(&cachedIndex{key: key, index: idx})
+	c.bytes = c.bytes + size
+}
+
+func (c *Cache) remove(element *list.Element) {
+	cached := c.lru.Remove(element).(*cachedIndex)
+	delete(c.blocks, cached.key)
+	c.bytes = c.bytes - int64(cached.index.Size()*indexPointSize)
+}
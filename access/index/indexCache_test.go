@@ -0,0 +1,152 @@
+package index
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/tcw/ibsen/access/common"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCache_evicts_least_recently_used(t *testing.T) {
+	shared := NewCache(3 * 10 * indexPointSize)
+	cache := shared.ForTopic("topic")
+	loads := 0
+	load := func() ([]byte, error) {
+		loads = loads + 1
+		return createIndexBytes(10), nil
+	}
+	for _, block := range []common.IndexBlock{0, 100, 200} {
+		_, err := cache.GetOrLoad(block, load)
+		assert.Nil(t, err)
+	}
+	_, err := cache.GetOrLoad(0, load)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, loads)
+
+	_, err = cache.GetOrLoad(300, load)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3*10*indexPointSize), shared.Bytes())
+	_, err = cache.GetOrLoad(0, load)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, loads)
+	_, err = cache.GetOrLoad(100, load)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, loads)
+}
+
+func TestCache_Append(t *testing.T) {
+	shared := NewCache(1024)
+	cache := shared.ForTopic("topic")
+	cache.Append(0, createIndexBytes(2))
+	assert.Equal(t, int64(0), shared.Bytes())
+
+	loaded, err := cache.GetOrLoad(0, func() ([]byte, error) {
+		return createIndexBytes(4), nil
+	})
+	assert.Nil(t, err)
+	// points already loaded from the index block file are skipped
+	cache.Append(0, createIndexBytes(6)[2*indexPointSize:])
+	appended, err := cache.GetOrLoad(0, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, loaded.Size())
+	assert.Equal(t, 6, appended.Size())
+	assert.Equal(t, common.Offset(50), appended.Head().Offset)
+	assert.Equal(t, common.Offset(30), appended.FindNearestByteOffset(35).Offset)
+
+	cache.Remove(0)
+	assert.Equal(t, int64(0), shared.Bytes())
+}
+
+func TestCache_does_not_cache_failed_load_or_index_over_budget(t *testing.T) {
+	shared := NewCache(5 * indexPointSize)
+	cache := shared.ForTopic("topic")
+	_, err := cache.GetOrLoad(0, func() ([]byte, error) {
+		return nil, errors.New("missing")
+	})
+	assert.NotNil(t, err)
+	idx, err := cache.GetOrLoad(0, func() ([]byte, error) {
+		return createIndexBytes(6), nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 6, idx.Size())
+	assert.Equal(t, int64(0), shared.Bytes())
+}
+
+func TestCache_budget_is_shared_by_topics(t *testing.T) {
+	shared := NewCache(2 * 10 * indexPointSize)
+	load := func() ([]byte, error) {
+		return createIndexBytes(10), nil
+	}
+	for _, topic := range []string{"a", "b", "c"} {
+		_, err := shared.ForTopic(topic).GetOrLoad(0, load)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, int64(2*10*indexPointSize), shared.Bytes())
+
+	loaded := false
+	_, err := shared.ForTopic("a").GetOrLoad(0, func() ([]byte, error) {
+		loaded = true
+		return createIndexBytes(10), nil
+	})
+	assert.Nil(t, err)
+	assert.True(t, loaded)
+}
+
+func TestCache_loads_index_once_without_blocking_other_blocks(t *testing.T) {
+	cache := NewCache(1024).ForTopic("topic")
+	loading := make(chan struct{})
+	release := make(chan struct{})
+	var loads atomic.Int32
+	slowLoad := func() ([]byte, error) {
+		if loads.Add(1) == 1 {
+			close(loading)
+		}
+		<-release
+		return createIndexBytes(4), nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			idx, err := cache.GetOrLoad(0, slowLoad)
+			assert.Nil(t, err)
+			assert.Equal(t, 4, idx.Size())
+		}()
+	}
+	<-loading
+	idx, err := cache.GetOrLoad(100, func() ([]byte, error) {
+		return createIndexBytes(2), nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, idx.Size())
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), loads.Load())
+}
+
+func TestCache_does_not_cache_load_raced_by_remove(t *testing.T) {
+	cache := NewCache(1024).ForTopic("topic")
+	idx, err := cache.GetOrLoad(0, func() ([]byte, error) {
+		cache.Remove(0)
+		return createIndexBytes(4), nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, idx.Size())
+	reloaded, err := cache.GetOrLoad(0, func() ([]byte, error) {
+		return createIndexBytes(2), nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, reloaded.Size())
+}
+
+// creates index points for offsets [0,10,20,...]
+func createIndexBytes(points int) []byte {
+	var index []uint64
+	for i := 0; i < points; i++ {
+		index = append(index, uint64(i*10), uint64(i*100))
+	}
+	return common.Uint64ArrayToBytes(index)
+}
@@ -7,35 +7,71 @@ import (
 	"github.com/tcw/ibsen/access/common"
 	"github.com/tcw/ibsen/errore"
 	"io"
+	"os"
 )
 
+// BlockIndices are the sparse indices created for a log block, offsets to byte offsets and
+// timestamps to offsets
+type BlockIndices struct {
+	Offsets    []byte
+	Timestamps []byte
+}
+
+// ReadThrottle is called with the number of bytes read from a log file before they are indexed, and may
+// block to limit the read rate
+type ReadThrottle func(bytes int64)
+
+type throttledReader struct {
+	reader   io.Reader
+	throttle ReadThrottle
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.throttle(int64(n))
+	}
+	return n, err
+}
+
 func CreateBinaryIndexFromLogFile(afs *afero.Afero, logFileName string, logfileByteOffset int64, oneEntryForEvery uint32) ([]byte, int64, error) {
+	indices, byteOffset, err := CreateBinaryIndicesFromLogFile(afs, logFileName, logfileByteOffset, oneEntryForEvery, nil)
+	return indices.Offsets, byteOffset, err
+}
+
+// CreateBinaryIndicesFromLogFile indexes every n-th offset from the byte offset in the log file. The time
+// index also includes the first entry in the block, entries written before timestamps were added are not
+// included in the time index. Every chunk read from the log file is charged to the throttle, if not nil.
+func CreateBinaryIndicesFromLogFile(afs *afero.Afero, logFileName string, logfileByteOffset int64, oneEntryForEvery uint32, throttle ReadThrottle) (BlockIndices, int64, error) {
 	exists, err := afs.Exists(logFileName)
 	if err != nil {
-		return nil, 0, errore.Wrap(err)
+		return BlockIndices{}, 0, errore.Wrap(err)
 	}
 	if !exists {
-		return nil, 0, errore.New("NoFile")
+		return BlockIndices{}, 0, errore.New("NoFile")
 	}
 
 	file, err := common.OpenFileForRead(afs, logFileName)
 	if err != nil {
-		return nil, 0, errore.Wrap(err)
+		return BlockIndices{}, 0, errore.Wrap(err)
 	}
 	var index []uint64
+	var timeIndex []uint64
 	var byteOffset int64 = 0
 	if logfileByteOffset > 0 {
 		byteOffset, err = file.Seek(logfileByteOffset, io.SeekStart)
 		if err != nil {
-			ioErr := file.Close()
-			if ioErr != nil {
-				return nil, 0, errore.WrapError(ioErr, err)
-			}
-			return nil, byteOffset, errore.Wrap(err)
+			return BlockIndices{}, byteOffset, closeOnError(file, err)
 		}
 	}
 	isFirst := true
-	reader := bufio.NewReader(file)
+	var previousOffset uint64 = 0
+	isBlockStart := logfileByteOffset == 0
+	var source io.Reader = file
+	if throttle != nil {
+		source = &throttledReader{reader: file, throttle: throttle}
+	}
+	reader := bufio.NewReader(source)
 	bytes := make([]byte, 8)
 	bytesCrc := make([]byte, 4)
 	for {
@@ -43,49 +79,116 @@ func CreateBinaryIndexFromLogFile(afs *afero.Afero, logFileName string, logfileB
 		if err == io.EOF {
 			ioErr := file.Close()
 			if ioErr != nil {
-				return nil, 0, errore.WrapError(ioErr, err)
+				return BlockIndices{}, 0, errore.WrapError(ioErr, err)
 			}
-			return common.Uint64ArrayToBytes(index), byteOffset, nil
+			return BlockIndices{
+				Offsets:    common.Uint64ArrayToBytes(index),
+				Timestamps: common.Uint64ArrayToBytes(timeIndex),
+			}, byteOffset, nil
 		}
 		if err != nil {
-			ioErr := file.Close()
-			if ioErr != nil {
-				return nil, 0, errore.WrapError(ioErr, err)
-			}
-			return nil, byteOffset, errore.Wrap(err)
+			return BlockIndices{}, byteOffset, closeOnError(file, err)
 		}
 		byteSize, err := io.ReadFull(reader, bytes)
 		if err != nil {
-			ioErr := file.Close()
-			if ioErr != nil {
-				return nil, 0, errore.WrapError(ioErr, err)
-			}
-			return nil, byteOffset, errore.Wrap(err)
+			return BlockIndices{}, byteOffset, closeOnError(file, err)
 		}
-		size := binary.LittleEndian.Uint32(bytes)
-		entry := make([]byte, size)
+		sizeField := binary.LittleEndian.Uint64(bytes)
+		entry := make([]byte, common.EntryBodySize(sizeField))
 		entrySize, err := io.ReadFull(reader, entry)
 		if err != nil {
-			ioErr := file.Close()
-			if ioErr != nil {
-				return nil, 0, errore.WrapError(ioErr, err)
-			}
-			return nil, byteOffset, errore.Wrap(err)
+			return BlockIndices{}, byteOffset, closeOnError(file, err)
 		}
 		offsetSize, err := io.ReadFull(reader, bytes)
 		if err != nil {
-			ioErr := file.Close()
-			if ioErr != nil {
-				return nil, 0, errore.WrapError(ioErr, err)
-			}
-			return nil, byteOffset, errore.Wrap(err)
+			return BlockIndices{}, byteOffset, closeOnError(file, err)
 		}
 		offset := binary.LittleEndian.Uint64(bytes)
-		if !isFirst && offset%uint64(oneEntryForEvery) == 0 {
+		// compacted blocks have gaps in offsets, so the first entry in every n offsets is indexed
+		isIndexed := offset%uint64(oneEntryForEvery) == 0 ||
+			!isFirst && offset/uint64(oneEntryForEvery) != previousOffset/uint64(oneEntryForEvery)
+		previousOffset = offset
+		if !isFirst && isIndexed {
 			index = append(index, offset)
 			index = append(index, uint64(byteOffset))
 		}
+		hasTimestamp := common.EntryFormat(sizeField) >= common.EntryFormatV1 && len(entry) >= 8
+		if hasTimestamp && (isBlockStart || !isFirst && isIndexed) {
+			timeIndex = append(timeIndex, binary.LittleEndian.Uint64(entry[:8]))
+			timeIndex = append(timeIndex, offset)
+		}
 		isFirst = false
+		isBlockStart = false
 		byteOffset = byteOffset + int64(offsetSize+crcSize+byteSize+entrySize)
 	}
 }
+
+func closeOnError(file afero.File, err error) error {
+	ioErr := file.Close()
+	if ioErr != nil {
+		return errore.WrapError(ioErr, err)
+	}
+	return errore.Wrap(err)
+}
+
+// TruncateIndexFile removes index entries pointing at or beyond byteSize of the log block,
+// and returns the number of entries removed
+func TruncateIndexFile(afs *afero.Afero, indexFileName string, byteSize int64) (int, error) {
+	bytes, err := afs.ReadFile(indexFileName)
+	if err != nil {
+		return 0, errore.Wrap(err)
+	}
+	idx := NewIndex(bytes)
+	keep := 0
+	for _, ptr := range idx.IndexOffsets {
+		if ptr.ByteOffset >= byteSize {
+			break
+		}
+		keep = keep + 1
+	}
+	if keep == idx.Size() && len(bytes) == keep*16 {
+		return 0, nil
+	}
+	file, err := afs.OpenFile(indexFileName, os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, errore.Wrap(err)
+	}
+	err = file.Truncate(int64(keep * 16))
+	if err != nil {
+		ioErr := file.Close()
+		if ioErr != nil {
+			return 0, errore.WrapError(ioErr, err)
+		}
+		return 0, errore.Wrap(err)
+	}
+	return idx.Size() - keep, file.Close()
+}
+
+// TruncateTimeIndexFile removes time index entries pointing at or beyond nextOffset, and returns
+// the number of entries removed
+func TruncateTimeIndexFile(afs *afero.Afero, timeIndexFileName string, nextOffset common.Offset) (int, error) {
+	bytes, err := afs.ReadFile(timeIndexFileName)
+	if err != nil {
+		return 0, errore.Wrap(err)
+	}
+	idx := NewTimeIndex(bytes)
+	keep := 0
+	for _, ptr := range idx.TimeOffsets {
+		if ptr.Offset >= nextOffset {
+			break
+		}
+		keep = keep + 1
+	}
+	if keep == len(idx.TimeOffsets) && len(bytes) == keep*16 {
+		return 0, nil
+	}
+	file, err := afs.OpenFile(timeIndexFileName, os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, errore.Wrap(err)
+	}
+	err = file.Truncate(int64(keep * 16))
+	if err != nil {
+		return 0, closeOnError(file, err)
+	}
+	return len(idx.TimeOffsets) - keep, file.Close()
+}
@@ -22,6 +22,50 @@ func TestCreateIndex(t *testing.T) {
 	assert.Equal(t, index.Size(), 9)
 }
 
+func TestCreateTimeIndex(t *testing.T) {
+	var fs = afero.NewMemMapFs()
+	afs := &afero.Afero{Fs: fs}
+	var logBytes []byte
+	for i := 0; i < 30; i++ {
+		logBytes = append(logBytes, common.CreateTimestampedByteEntry([]byte("dummy"+strconv.Itoa(i)), common.Offset(i), int64(i*100))...)
+	}
+	err := afs.WriteFile("tmp/test.log", logBytes, 0744)
+	assert.Nil(t, err)
+	indices, _, err := CreateBinaryIndicesFromLogFile(afs, "tmp/test.log", 0, 10, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, NewIndex(indices.Offsets).Size())
+	timeIndex := NewTimeIndex(indices.Timestamps)
+	assert.Equal(t, []common.TimeOffsetPtr{
+		{Timestamp: 0, Offset: 0},
+		{Timestamp: 1000, Offset: 10},
+		{Timestamp: 2000, Offset: 20},
+	}, timeIndex.TimeOffsets)
+	nearest, found := timeIndex.FindNearestOffsetBefore(1500)
+	assert.True(t, found)
+	assert.Equal(t, common.Offset(10), nearest.Offset)
+	_, found = timeIndex.FindNearestOffsetBefore(0)
+	assert.False(t, found)
+}
+
+func TestCreateIndex_offset_gaps(t *testing.T) {
+	var fs = afero.NewMemMapFs()
+	afs := &afero.Afero{Fs: fs}
+	var logBytes []byte
+	for _, offset := range []common.Offset{0, 4, 9, 13, 18, 35, 41} {
+		logBytes = append(logBytes, common.CreateByteEntry([]byte("dummy"), offset)...)
+	}
+	err := afs.WriteFile("tmp/test.log", logBytes, 0744)
+	assert.Nil(t, err)
+	binaryIndex, _, err := CreateBinaryIndexFromLogFile(afs, "tmp/test.log", 0, 10)
+	assert.Nil(t, err)
+	idx := NewIndex(binaryIndex)
+	assert.Equal(t, []common.OffsetFilePtr{
+		{Offset: 13, ByteOffset: 75},
+		{Offset: 35, ByteOffset: 125},
+		{Offset: 41, ByteOffset: 150},
+	}, idx.IndexOffsets)
+}
+
 func createLogEntries(entries int) []byte {
 	var log = make([]byte, 0)
 	for i := 0; i < entries; i++ {
@@ -29,3 +73,24 @@ func createLogEntries(entries int) []byte {
 	}
 	return log
 }
+
+func TestCreateIndex_charges_throttle_for_every_chunk_read(t *testing.T) {
+	var fs = afero.NewMemMapFs()
+	afs := &afero.Afero{Fs: fs}
+	var logBytes []byte
+	for i := 0; i < 1000; i++ {
+		logBytes = append(logBytes, common.CreateByteEntry([]byte("dummy"+strconv.Itoa(i)), common.Offset(i))...)
+	}
+	err := afs.WriteFile("tmp/test.log", logBytes, 0744)
+	assert.Nil(t, err)
+	var charges []int64
+	var charged int64
+	_, byteOffset, err := CreateBinaryIndicesFromLogFile(afs, "tmp/test.log", 0, 10, func(bytes int64) {
+		charges = append(charges, bytes)
+		charged = charged + bytes
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(logBytes)), byteOffset)
+	assert.Equal(t, int64(len(logBytes)), charged)
+	assert.Greater(t, len(charges), 1)
+}
@@ -0,0 +1,40 @@
+package index
+
+import (
+	"encoding/binary"
+	"github.com/tcw/ibsen/access/common"
+	"sort"
+)
+
+// TimeIndex maps write timestamps to offsets in a log block. Timestamps are non-decreasing
+// within a topic, so the index can be binary searched.
+type TimeIndex struct {
+	TimeOffsets []common.TimeOffsetPtr
+}
+
+func NewTimeIndex(bytes []byte) *TimeIndex {
+	batchSize := 16
+	index := TimeIndex{TimeOffsets: make([]common.TimeOffsetPtr, 0)}
+	for i := 0; i+batchSize <= len(bytes); i += batchSize {
+		index.TimeOffsets = append(index.TimeOffsets, common.TimeOffsetPtr{
+			Timestamp: int64(binary.LittleEndian.Uint64(bytes[i : i+8])),
+			Offset:    common.Offset(binary.LittleEndian.Uint64(bytes[i+8 : i+16])),
+		})
+	}
+	return &index
+}
+
+func (idx *TimeIndex) IsEmpty() bool {
+	return len(idx.TimeOffsets) == 0
+}
+
+// FindNearestOffsetBefore returns the last indexed entry written before timestamp
+func (idx *TimeIndex) FindNearestOffsetBefore(timestamp int64) (common.TimeOffsetPtr, bool) {
+	i := sort.Search(len(idx.TimeOffsets), func(i int) bool {
+		return idx.TimeOffsets[i].Timestamp >= timestamp
+	})
+	if i == 0 {
+		return common.TimeOffsetPtr{}, false
+	}
+	return idx.TimeOffsets[i-1], true
+}
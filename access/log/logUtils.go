@@ -2,6 +2,7 @@ package log
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"github.com/rs/zerolog/log"
@@ -9,7 +10,6 @@ import (
 	"github.com/tcw/ibsen/access/common"
 	"github.com/tcw/ibsen/errore"
 	"io"
-	"math"
 	"os"
 	"path/filepath"
 	"sort"
@@ -22,8 +22,6 @@ type BlockSizeInBytes uint64
 type FileName string
 type StrictlyMonotonicVarIntIndex []byte
 
-var NoByteOffsetFound = errors.New("no byte offset found")
-
 func CreateTopicDirectory(afs *afero.Afero, rootPath string, topic string) (bool, error) {
 	path := rootPath + common.Sep + topic
 	exists, err := afero.Exists(afs, path)
@@ -56,18 +54,27 @@ func LoadTopicBlocks(afs *afero.Afero, rootPath string, topic string) ([]common.
 	}
 	var indexBlocks []common.IndexBlock
 	var logBlocks []common.LogBlock
+	hasLogBlock := make(map[uint64]bool)
 	for _, info := range filesInTopic {
 		if info.IsDir() {
 			continue
 		}
 		nameExt := strings.Split(info.Name(), ".")
 		fileExtension := filepath.Ext(info.Name())
+		// other files in the topic, like producer state, are not blocks
+		if !isBlockFileExtension(fileExtension) {
+			continue
+		}
 		parseUint, err := strconv.ParseUint(nameExt[0], 10, 64)
 		if err != nil {
 			return nil, nil, errore.Wrap(err)
 		}
-		if fileExtension == ".log" {
-			logBlocks = append(logBlocks, common.LogBlock(parseUint))
+		// a block is both compressed and uncompressed if ibsen stopped before the uncompressed block was removed
+		if fileExtension == ".log" || fileExtension == common.CompressedBlockExtension {
+			if !hasLogBlock[parseUint] {
+				logBlocks = append(logBlocks, common.LogBlock(parseUint))
+			}
+			hasLogBlock[parseUint] = true
 		}
 		if fileExtension == ".idx" {
 			indexBlocks = append(indexBlocks, common.IndexBlock(parseUint))
@@ -78,6 +85,10 @@ func LoadTopicBlocks(afs *afero.Afero, rootPath string, topic string) ([]common.
 	return logBlocks, indexBlocks, nil
 }
 
+func isBlockFileExtension(extension string) bool {
+	return extension == ".log" || extension == ".idx" || extension == ".tidx" || extension == common.CompressedBlockExtension
+}
+
 func ListAllTopics(afs *afero.Afero, dir string) ([]string, error) {
 	var filenames []string
 	file, err := common.OpenFileForRead(afs, dir)
@@ -95,7 +106,7 @@ func ListAllTopics(afs *afero.Afero, dir string) ([]string, error) {
 	return filenames, nil
 }
 
-func FindByteOffsetFromAndIncludingOffset(afs *afero.Afero, fileName string, startAtByteOffset int64, offset common.Offset) (int64, int, error) {
+func FindByteOffsetFromAndIncludingOffset(afs *afero.Afero, fileName string, startAtByteOffset int64, offset common.Offset, policy common.CorruptionPolicy) (int64, int, error) {
 	scanCount := 0
 	if offset == 0 {
 		return 0, 0, nil
@@ -105,58 +116,148 @@ func FindByteOffsetFromAndIncludingOffset(afs *afero.Afero, fileName string, sta
 		return 0, scanCount, errore.Wrap(err)
 	}
 	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return 0, scanCount, errore.Wrap(err)
+	}
 
 	if startAtByteOffset > 0 {
 		_, err = file.Seek(startAtByteOffset, io.SeekStart)
 		if err != nil {
 			return 0, scanCount, errore.Wrap(err)
 		}
-		lastOffset, err := offsetLookBack(file)
-		if err != nil {
-			return 0, 0, errore.Wrap(err)
-		}
-		if lastOffset+1 == offset {
-			return startAtByteOffset, 0, nil
-		}
 	}
 
+	// offsets are increasing but may have gaps in compacted blocks, so the first entry at or after
+	// the offset is found. If all entries are before the offset, the end of the block is returned.
 	reader := bufio.NewReader(file)
-	bytes := make([]byte, 8)
-	checksum := make([]byte, 4)
-
-	var offsetInFile int64 = math.MaxInt64 - 1
 	var byteOffset = startAtByteOffset
 	for {
-		if common.Offset(offsetInFile+1) == offset {
+		entry, entryBytes, err := common.ReadByteEntry(reader, info.Size()-byteOffset)
+		if err == io.EOF {
 			return byteOffset, scanCount, nil
 		}
-		checksumBytes, err := io.ReadFull(reader, checksum)
+		if isCorruption(err) {
+			if policy != common.SkipCorrupted || errors.Is(err, common.InvalidEntrySize) {
+				return 0, scanCount, &common.CorruptEntryError{
+					ByteOffset: byteOffset,
+					Offset:     common.Offset(entry.Offset),
+					Stopped:    policy != common.FailOnCorruption,
+					Err:        err,
+				}
+			}
+			warnSkippedEntry(file.Name(), common.Offset(entry.Offset), byteOffset, err)
+		} else if err != nil {
+			return 0, scanCount, errore.Wrap(err)
+		} else if common.Offset(entry.Offset) >= offset {
+			return byteOffset, scanCount, nil
+		}
+		byteOffset = byteOffset + entryBytes
+		scanCount = scanCount + 1
+	}
+}
+
+// FindOffsetFromTimestamp scans a log block from the byte offset for the first entry written at or
+// after the timestamp, entries at or beyond endOffset are not considered
+func FindOffsetFromTimestamp(afs *afero.Afero, fileName string, startAtByteOffset int64, timestamp int64, endOffset common.Offset, policy common.CorruptionPolicy) (common.Offset, bool, error) {
+	file, err := common.OpenFileForRead(afs, fileName)
+	if err != nil {
+		return 0, false, errore.Wrap(err)
+	}
+	defer file.Close()
+	reader, err := common.NewBlockReader(file, startAtByteOffset)
+	if err != nil {
+		return 0, false, errore.Wrap(err)
+	}
+	byteOffset := startAtByteOffset
+	for {
+		entry, entryBytes, err := reader.Next()
 		if err == io.EOF {
-			return 0, scanCount, NoByteOffsetFound
+			return 0, false, nil
 		}
-		if err != nil {
-			return 0, scanCount, errore.Wrap(err)
+		if isCorruption(err) {
+			if policy != common.SkipCorrupted || errors.Is(err, common.InvalidEntrySize) {
+				return 0, false, &common.CorruptEntryError{
+					ByteOffset: byteOffset,
+					Offset:     common.Offset(entry.Offset),
+					Stopped:    policy != common.FailOnCorruption,
+					Err:        err,
+				}
+			}
+			warnSkippedEntry(file.Name(), common.Offset(entry.Offset), byteOffset, err)
+			byteOffset = byteOffset + entryBytes
+			continue
 		}
-		sizeBytes, err := io.ReadFull(reader, bytes)
 		if err != nil {
-			return 0, scanCount, errore.Wrap(err)
+			return 0, false, errore.Wrap(err)
 		}
-		entrySize := binary.LittleEndian.Uint64(bytes)
-		entryBytes := make([]byte, entrySize)
-		_, err = io.ReadFull(reader, entryBytes)
-		if err != nil {
-			return 0, scanCount, errore.Wrap(err)
+		if common.Offset(entry.Offset) >= endOffset {
+			return 0, false, nil
+		}
+		if entry.Timestamp >= timestamp {
+			return common.Offset(entry.Offset), true, nil
+		}
+		byteOffset = byteOffset + entryBytes
+	}
+}
+
+// LastTimestamp scans a log block from the byte offset to the end, and returns the timestamp of the last
+// entry written. Not found if no entry has a timestamp.
+func LastTimestamp(afs *afero.Afero, fileName string, startAtByteOffset int64, policy common.CorruptionPolicy) (int64, bool, error) {
+	file, err := common.OpenFileForRead(afs, fileName)
+	if err != nil {
+		return 0, false, errore.Wrap(err)
+	}
+	defer file.Close()
+	reader, err := common.NewBlockReader(file, startAtByteOffset)
+	if err != nil {
+		return 0, false, errore.Wrap(err)
+	}
+	byteOffset := startAtByteOffset
+	var lastTimestamp int64 = 0
+	for {
+		entry, entryBytes, err := reader.Next()
+		if err == io.EOF {
+			return lastTimestamp, lastTimestamp > 0, nil
+		}
+		if isCorruption(err) {
+			if policy != common.SkipCorrupted || errors.Is(err, common.InvalidEntrySize) {
+				return 0, false, &common.CorruptEntryError{
+					ByteOffset: byteOffset,
+					Offset:     common.Offset(entry.Offset),
+					Err:        err,
+				}
+			}
+			warnSkippedEntry(file.Name(), common.Offset(entry.Offset), byteOffset, err)
+			byteOffset = byteOffset + entryBytes
+			continue
 		}
-		offsetBytes, err := io.ReadFull(reader, bytes)
 		if err != nil {
-			return 0, scanCount, errore.Wrap(err)
+			return 0, false, errore.Wrap(err)
 		}
-		offsetInFile = int64(binary.LittleEndian.Uint64(bytes))
-		byteOffset = byteOffset + int64(offsetBytes) + int64(checksumBytes) + int64(sizeBytes) + int64(entrySize)
-		scanCount = scanCount + 1
+		if entry.Timestamp > lastTimestamp {
+			lastTimestamp = entry.Timestamp
+		}
+		byteOffset = byteOffset + entryBytes
 	}
 }
 
+func isCorruption(err error) bool {
+	return errors.Is(err, common.ChecksumMismatch) ||
+		errors.Is(err, common.InvalidEntrySize) ||
+		errors.Is(err, common.InvalidEntryMetadata) ||
+		errors.Is(err, common.CorruptCompressedBlock)
+}
+
+func warnSkippedEntry(fileName string, offset common.Offset, byteOffset int64, err error) {
+	log.Warn().
+		Str("filename", fileName).
+		Uint64("offset", uint64(offset)).
+		Int64("byteOffset", byteOffset).
+		Err(err).
+		Msg("skipped corrupt entry")
+}
+
 func offsetLookBack(file afero.File) (common.Offset, error) {
 	_, err := file.Seek(-8, io.SeekCurrent)
 	if err != nil {
@@ -189,23 +290,172 @@ func BlockInfo(afs *afero.Afero, blockFileName string) (common.Offset, int64, er
 	return common.Offset(offset), fileSize, nil
 }
 
+type BlockRecovery struct {
+	HasEntries     bool
+	LastOffset     common.Offset
+	LastTimestamp  int64
+	ValidByteSize  int64
+	DroppedBytes   int64
+	CorruptEntries int
+	// Sealed is set when the block is kept with a corrupt entry, and must not be written to again
+	Sealed bool
+}
+
+// RecoverBlock scans a log block from the start and validates the size and checksum of every entry.
+// The valid byte size ends before a partial or corrupt trailing entry left behind by an interrupted
+// write, corrupt entries followed by valid entries are only counted. An entry with an invalid size
+// that is not the trailing entry can not be skipped, it is returned as a CorruptEntryError together
+// with the recovery up to the entry, and the block is not truncated.
+func RecoverBlock(afs *afero.Afero, blockFileName string) (BlockRecovery, error) {
+	file, err := common.OpenFileForRead(afs, blockFileName)
+	if err != nil {
+		return BlockRecovery{}, errore.Wrap(err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return BlockRecovery{}, errore.Wrap(err)
+	}
+	fileSize := info.Size()
+	recovery := BlockRecovery{}
+	reader := bufio.NewReader(file)
+	var byteOffset int64 = 0
+	for {
+		entry, entryBytes, err := common.ReadByteEntry(reader, fileSize-byteOffset)
+		if err == io.EOF {
+			break
+		}
+		// after a corrupt entry the framing can be off, so only an entry past the end of an intact block
+		// is trusted to be an interrupted write
+		if errors.Is(err, common.TruncatedEntry) && recovery.CorruptEntries == 0 {
+			break
+		}
+		if errors.Is(err, common.InvalidEntrySize) {
+			recovery.ValidByteSize = byteOffset
+			recovery.DroppedBytes = 0
+			corruptOffset := common.Offset(0)
+			if recovery.HasEntries {
+				corruptOffset = recovery.LastOffset + 1
+			}
+			return recovery, &common.CorruptEntryError{
+				ByteOffset: byteOffset,
+				Offset:     corruptOffset,
+				Err:        err,
+			}
+		}
+		if errors.Is(err, common.ChecksumMismatch) {
+			if byteOffset+entryBytes == fileSize {
+				break
+			}
+			recovery.CorruptEntries = recovery.CorruptEntries + 1
+		} else if err != nil {
+			return BlockRecovery{}, errore.Wrap(err)
+		}
+		byteOffset = byteOffset + entryBytes
+		recovery.HasEntries = true
+		recovery.LastOffset = common.Offset(entry.Offset)
+		if entry.Timestamp > recovery.LastTimestamp {
+			recovery.LastTimestamp = entry.Timestamp
+		}
+	}
+	recovery.ValidByteSize = byteOffset
+	recovery.DroppedBytes = fileSize - byteOffset
+	return recovery, nil
+}
+
+// ScanBlock reads a log block up to the entry before endOffset and calls onEntry with every entry and its
+// uncompressed bytes, the bytes are only valid until onEntry returns. Entries written after endOffset are
+// never read, so the head block can be scanned while it is written. Scanning stops with a
+// CorruptEntryError at the first corrupt entry.
+func ScanBlock(afs *afero.Afero, blockFileName string, endOffset common.Offset, onEntry func(entry common.LogEntry, entryBytes []byte)) error {
+	file, err := common.OpenFileForRead(afs, blockFileName)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	defer file.Close()
+	blockReader, err := common.NewBlockReader(file, 0)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	var entryBytes bytes.Buffer
+	blockReader.Reader = io.TeeReader(blockReader.Reader, &entryBytes)
+	var byteOffset int64 = 0
+	for {
+		entryBytes.Reset()
+		entry, entrySize, err := blockReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if isCorruption(err) {
+			return &common.CorruptEntryError{
+				ByteOffset: byteOffset,
+				Offset:     common.Offset(entry.Offset),
+				Err:        err,
+			}
+		}
+		if err != nil {
+			return errore.Wrap(err)
+		}
+		if common.Offset(entry.Offset) >= endOffset {
+			return nil
+		}
+		onEntry(entry, entryBytes.Bytes())
+		if common.Offset(entry.Offset)+1 >= endOffset {
+			return nil
+		}
+		byteOffset = byteOffset + entrySize
+	}
+}
+
+func TruncateBlock(afs *afero.Afero, blockFileName string, byteSize int64) error {
+	file, err := afs.OpenFile(blockFileName, os.O_WRONLY, 0600)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	err = file.Truncate(byteSize)
+	if err != nil {
+		ioErr := file.Close()
+		if ioErr != nil {
+			return errore.WrapError(ioErr, err)
+		}
+		return errore.Wrap(err)
+	}
+	return file.Close()
+}
+
 type ReadFileParams struct {
-	File            afero.File
-	LogChan         chan *[]common.LogEntry
-	Wg              *sync.WaitGroup
-	BatchSize       uint32
-	StartByteOffset int64
-	EndOffset       common.Offset
+	File             afero.File
+	LogChan          chan *common.EntryBatch
+	Wg               *sync.WaitGroup
+	BatchSize        uint32
+	StartByteOffset  int64
+	FromOffset       common.Offset
+	EndOffset        common.Offset
+	CorruptionPolicy common.CorruptionPolicy
+	// MaxEntries and MaxBytes stop the read when reached, zero is no limit
+	MaxEntries uint64
+	MaxBytes   uint64
+	// Filter selects the entries sent, entries filtered out do not count towards the batch size or limits
+	Filter common.EntryFilter
+}
+
+func (p ReadFileParams) limitReached(entriesRead uint64, bytesRead uint64) bool {
+	return (p.MaxEntries > 0 && entriesRead >= p.MaxEntries) ||
+		(p.MaxBytes > 0 && bytesRead >= p.MaxBytes)
 }
 
 type ReadResult struct {
-	LastLogOffset common.Offset
-	EntriesRead   uint64
+	LastLogOffset  common.Offset
+	EntriesRead    uint64
+	EntriesSkipped uint64
+	BytesRead      uint64
 }
 
 func (r *ReadResult) Update(result ReadResult) {
 	r.LastLogOffset = result.LastLogOffset
 	r.EntriesRead = r.EntriesRead + result.EntriesRead
+	r.EntriesSkipped = r.EntriesSkipped + result.EntriesSkipped
+	r.BytesRead = r.BytesRead + result.BytesRead
 }
 
 func (r *ReadResult) NextOffset() common.Offset {
@@ -216,12 +466,14 @@ func ReadFile(params ReadFileParams) (ReadResult, error) {
 	var currentOffset common.Offset = 0
 	var offsetFromLogg common.Offset = 0
 	var entriesRead uint64 = 0
+	var entriesSkipped uint64 = 0
+	var bytesRead uint64 = 0
 	currentBatchInBytes := 0
 	log.Debug().
 		Str("filename", params.File.Name()).
 		Int64("byteOffset", params.StartByteOffset).
 		Msg("read file")
-	if params.StartByteOffset > 0 {
+	if params.StartByteOffset > 0 && !common.IsCompressedBlock(params.File.Name()) {
 		_, err := params.File.Seek(params.StartByteOffset, io.SeekStart)
 		if err != nil {
 			return ReadResult{}, errore.Wrap(err)
@@ -232,91 +484,113 @@ func ReadFile(params ReadFileParams) (ReadResult, error) {
 		}
 		currentOffset = offsetFromLogg + 1
 	}
-	reader := bufio.NewReader(params.File)
-	bytes := make([]byte, 8)
-	checksum := make([]byte, 4)
-	logEntries := make([]common.LogEntry, params.BatchSize)
-	slicePointer := 0
+	byteOffset := params.StartByteOffset
+	reader, err := common.NewBlockReader(params.File, params.StartByteOffset)
+	if err != nil {
+		return ReadResult{}, errore.Wrap(err)
+	}
+	batch := common.NewEntryBatch()
 	for {
-		if currentOffset == params.EndOffset {
-			if logEntries != nil && slicePointer > 0 {
-				params.Wg.Add(1)
-				sendingEntries := logEntries[:slicePointer]
-				params.LogChan <- &sendingEntries
-			}
+		if currentOffset >= params.EndOffset || params.limitReached(entriesRead, bytesRead) {
+			sendLastBatch(params, batch)
 			return ReadResult{
-				LastLogOffset: offsetFromLogg,
-				EntriesRead:   entriesRead,
+				LastLogOffset:  offsetFromLogg,
+				EntriesRead:    entriesRead,
+				EntriesSkipped: entriesSkipped,
+				BytesRead:      bytesRead,
 			}, nil
 		}
-		if (slicePointer != 0 &&
-			uint32(slicePointer)%params.BatchSize == 0) ||
+		if (len(batch.Entries) != 0 &&
+			uint32(len(batch.Entries))%params.BatchSize == 0) ||
 			currentBatchInBytes > 10*1024*1024 {
 			params.Wg.Add(1)
-			sendingEntries := logEntries[:slicePointer]
-			logEntryCopy := make([]common.LogEntry, slicePointer)
-			copy(logEntryCopy, sendingEntries)
-			params.LogChan <- &logEntryCopy
-			slicePointer = 0
+			params.LogChan <- batch
+			batch = common.NewEntryBatch()
 			currentBatchInBytes = 0
 		}
-		// Checksum
-		_, err := io.ReadFull(reader, checksum)
+		logEntry, entryBytes, err := reader.NextInto(batch)
 		if err == io.EOF {
-			if logEntries != nil && slicePointer > 0 {
-				params.Wg.Add(1)
-				sendingEntries := logEntries[:slicePointer]
-				params.LogChan <- &sendingEntries
-			}
+			sendLastBatch(params, batch)
 			return ReadResult{
-				LastLogOffset: offsetFromLogg,
-				EntriesRead:   entriesRead,
+				LastLogOffset:  offsetFromLogg,
+				EntriesRead:    entriesRead,
+				EntriesSkipped: entriesSkipped,
+				BytesRead:      bytesRead,
 			}, nil
 		}
-		if err != nil {
-			return ReadResult{}, errore.Wrap(err)
-		}
-		checksumValue := binary.LittleEndian.Uint32(bytes)
-
-		// Entry size
-		_, err = io.ReadFull(reader, bytes)
-		if err != nil {
-			return ReadResult{}, errore.Wrap(err)
+		if isCorruption(err) {
+			corruptOffset := currentOffset
+			if currentOffset == 0 {
+				corruptOffset = common.Offset(logEntry.Offset)
+			}
+			corruption := &common.CorruptEntryError{
+				ByteOffset: byteOffset,
+				Offset:     corruptOffset,
+				Err:        err,
+			}
+			// an entry can only be skipped if its size can be trusted
+			if params.CorruptionPolicy == common.SkipCorrupted && errors.Is(err, common.ChecksumMismatch) {
+				warnSkippedEntry(params.File.Name(), corruptOffset, byteOffset, err)
+				byteOffset = byteOffset + entryBytes
+				offsetFromLogg = corruptOffset
+				currentOffset = corruptOffset + 1
+				entriesSkipped = entriesSkipped + 1
+				continue
+			}
+			if params.CorruptionPolicy == common.FailOnCorruption {
+				batch.Release()
+				return ReadResult{}, corruption
+			}
+			sendLastBatch(params, batch)
+			corruption.Stopped = true
+			return ReadResult{
+				LastLogOffset:  offsetFromLogg,
+				EntriesRead:    entriesRead,
+				EntriesSkipped: entriesSkipped,
+				BytesRead:      bytesRead,
+			}, corruption
 		}
-
-		size := binary.LittleEndian.Uint64(bytes)
-
-		// Entry bytes
-		entry := make([]byte, size)
-
-		_, err = io.ReadFull(reader, entry)
 		if err != nil {
+			batch.Release()
 			return ReadResult{}, errore.Wrap(err)
 		}
-
-		// offset
-		_, err = io.ReadFull(reader, bytes)
-		if err != nil {
-			return ReadResult{}, errore.Wrap(err)
+		// compacted blocks have gaps in offsets, but offsets are always increasing
+		if common.Offset(logEntry.Offset) < currentOffset {
+			batch.Release()
+			return ReadResult{}, errore.NewF("read order assertion failed, expected at least [%d] actual [%d]", currentOffset, logEntry.Offset)
 		}
-
-		offset := int64(binary.LittleEndian.Uint64(bytes))
-		offsetFromLogg = common.Offset(offset)
-		if currentOffset == 0 {
-			currentOffset = offsetFromLogg
+		if common.Offset(logEntry.Offset) >= params.EndOffset {
+			sendLastBatch(params, batch)
+			return ReadResult{
+				LastLogOffset:  offsetFromLogg,
+				EntriesRead:    entriesRead,
+				EntriesSkipped: entriesSkipped,
+				BytesRead:      bytesRead,
+			}, nil
 		}
-		if currentOffset != offsetFromLogg {
-			return ReadResult{}, errore.NewF("read order assertion failed, expected [%d] actual [%d]", currentOffset, offsetFromLogg)
+		byteOffset = byteOffset + entryBytes
+		offsetFromLogg = common.Offset(logEntry.Offset)
+		currentOffset = offsetFromLogg + 1
+		// compressed blocks are read from the start of a frame, which can be before the offset to read from
+		if offsetFromLogg < params.FromOffset {
+			continue
 		}
-		logEntries[slicePointer] = common.LogEntry{
-			Offset:   uint64(offset),
-			Crc:      checksumValue,
-			ByteSize: int(size),
-			Entry:    entry,
+		if params.Filter != nil && !params.Filter(logEntry.Entry) {
+			continue
 		}
-		currentBatchInBytes = +int(size)
+		batch.Add(logEntry)
+		currentBatchInBytes = currentBatchInBytes + logEntry.ByteSize
 		entriesRead = entriesRead + 1
-		currentOffset = currentOffset + 1
-		slicePointer = slicePointer + 1
+		bytesRead = bytesRead + uint64(logEntry.ByteSize)
+	}
+}
+
+// sendLastBatch sends the batch if it has entries, the receiver releases batches sent
+func sendLastBatch(params ReadFileParams, batch *common.EntryBatch) {
+	if len(batch.Entries) == 0 {
+		batch.Release()
+		return
 	}
+	params.Wg.Add(1)
+	params.LogChan <- batch
 }
@@ -1,14 +1,24 @@
 package log
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"github.com/rs/zerolog"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/tcw/ibsen/access/common"
 	"github.com/tcw/ibsen/access/index"
+	"io"
+	"math"
 	"sync"
 	"testing"
 )
 
+func init() {
+	zerolog.SetGlobalLevel(zerolog.InfoLevel)
+}
+
 func TestCreateTopic(t *testing.T) {
 	afs := common.MemAfs()
 	_, err := CreateTopicDirectory(afs, "tmp", "topic1")
@@ -61,7 +71,7 @@ func TestCreateByteEntry(t *testing.T) {
 	assert.Nil(t, err)
 	file, err := common.OpenFileForRead(afs, "tmp/topic1/001.log")
 	assert.Nil(t, err)
-	logChan := make(chan *[]common.LogEntry)
+	logChan := make(chan *common.EntryBatch)
 	var wg sync.WaitGroup
 	go func() {
 		_, err = ReadFile(ReadFileParams{
@@ -77,13 +87,314 @@ func TestCreateByteEntry(t *testing.T) {
 	}()
 	wg.Wait()
 	logEntry := <-logChan
-	for _, l := range *logEntry {
+	for _, l := range logEntry.Entries {
 		assert.Equal(t, 5, l.ByteSize)
 		assert.Equal(t, uint64(0), l.Offset)
 		assert.Equal(t, "dummy", string(l.Entry))
 	}
 }
 
+func TestReadFile_corruption_policies(t *testing.T) {
+	tests := []struct {
+		name            string
+		policy          common.CorruptionPolicy
+		expectedOffsets []uint64
+		expectedStopped bool
+		expectError     bool
+	}{
+		{
+			name:        "fail",
+			policy:      common.FailOnCorruption,
+			expectError: true,
+		},
+		{
+			name:            "skip",
+			policy:          common.SkipCorrupted,
+			expectedOffsets: []uint64{0, 2},
+		},
+		{
+			name:            "stop",
+			policy:          common.StopAtCorruption,
+			expectedOffsets: []uint64{0},
+			expectedStopped: true,
+			expectError:     true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			afs := common.MemAfs()
+			fileName := "tmp/topic1/001.log"
+			writeCorruptLog(t, afs, fileName)
+			file, err := common.OpenFileForRead(afs, fileName)
+			assert.Nil(t, err)
+			logChan := make(chan *common.EntryBatch, 10)
+			var wg sync.WaitGroup
+			result, err := ReadFile(ReadFileParams{
+				File:             file,
+				LogChan:          logChan,
+				Wg:               &wg,
+				BatchSize:        10,
+				StartByteOffset:  0,
+				EndOffset:        3,
+				CorruptionPolicy: test.policy,
+			})
+			close(logChan)
+			if test.expectError {
+				var corruption *common.CorruptEntryError
+				assert.True(t, errors.As(err, &corruption))
+				assert.Equal(t, common.Offset(1), corruption.Offset)
+				assert.Equal(t, int64(26), corruption.ByteOffset)
+				assert.Equal(t, test.expectedStopped, corruption.Stopped)
+			} else {
+				assert.Nil(t, err)
+				assert.Equal(t, uint64(1), result.EntriesSkipped)
+			}
+			var offsets []uint64
+			for batch := range logChan {
+				for _, entry := range batch.Entries {
+					offsets = append(offsets, entry.Offset)
+				}
+			}
+			assert.Equal(t, test.expectedOffsets, offsets)
+		})
+	}
+}
+
+func TestFindByteOffset_skips_corrupt_entry(t *testing.T) {
+	afs := common.MemAfs()
+	fileName := "tmp/topic1/001.log"
+	writeCorruptLog(t, afs, fileName)
+	_, _, err := FindByteOffsetFromAndIncludingOffset(afs, fileName, 0, 2, common.FailOnCorruption)
+	assert.True(t, errors.Is(err, common.ChecksumMismatch))
+	byteOffset, scanned, err := FindByteOffsetFromAndIncludingOffset(afs, fileName, 0, 2, common.SkipCorrupted)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(52), byteOffset)
+	assert.Equal(t, 2, scanned)
+}
+
+func TestRecoverBlock_partial_trailing_entry(t *testing.T) {
+	afs := common.MemAfs()
+	fileName := "tmp/topic1/001.log"
+	var logBytes []byte
+	logBytes = append(logBytes, common.CreateByteEntry([]byte("dummy1"), 0)...)
+	logBytes = append(logBytes, common.CreateByteEntry([]byte("dummy2"), 1)...)
+	logBytes = append(logBytes, common.CreateByteEntry([]byte("dummy3"), 2)[:20]...)
+	err := afs.WriteFile(fileName, logBytes, 0600)
+	assert.Nil(t, err)
+	recovery, err := RecoverBlock(afs, fileName)
+	assert.Nil(t, err)
+	assert.True(t, recovery.HasEntries)
+	assert.Equal(t, common.Offset(1), recovery.LastOffset)
+	assert.Equal(t, int64(52), recovery.ValidByteSize)
+	assert.Equal(t, int64(20), recovery.DroppedBytes)
+}
+
+func TestRecoverBlock_invalid_size_before_end(t *testing.T) {
+	afs := common.MemAfs()
+	fileName := "tmp/topic1/001.log"
+	corrupt := common.CreateByteEntry([]byte("dummy2"), 1)
+	corrupt[11] = 0xEE
+	var logBytes []byte
+	logBytes = append(logBytes, common.CreateByteEntry([]byte("dummy1"), 0)...)
+	logBytes = append(logBytes, corrupt...)
+	logBytes = append(logBytes, common.CreateByteEntry([]byte("dummy3"), 2)...)
+	err := afs.WriteFile(fileName, logBytes, 0600)
+	assert.Nil(t, err)
+	recovery, err := RecoverBlock(afs, fileName)
+	var corruption *common.CorruptEntryError
+	assert.True(t, errors.As(err, &corruption))
+	assert.Equal(t, int64(26), corruption.ByteOffset)
+	assert.Equal(t, common.Offset(1), corruption.Offset)
+	assert.Equal(t, common.Offset(0), recovery.LastOffset)
+	assert.Equal(t, int64(0), recovery.DroppedBytes)
+
+	// a size past the end after a checksum mismatch can be a misframe, not an interrupted write
+	writeCorruptLog(t, afs, fileName)
+	logBytes, err = afs.ReadFile(fileName)
+	assert.Nil(t, err)
+	err = afs.WriteFile(fileName, append(logBytes, common.CreateByteEntry([]byte("dummy4"), 3)[:20]...), 0600)
+	assert.Nil(t, err)
+	_, err = RecoverBlock(afs, fileName)
+	assert.True(t, errors.As(err, &corruption))
+}
+
+func TestReadFile_mixed_entry_formats(t *testing.T) {
+	afs := common.MemAfs()
+	fileName := "tmp/topic1/001.log"
+	var logBytes []byte
+	logBytes = append(logBytes, common.CreateByteEntry([]byte("dummy1"), 0)...)
+	logBytes = append(logBytes, common.CreateTimestampedByteEntry([]byte("dummy2"), 1, 1000)...)
+	logBytes = append(logBytes, common.CreateTimestampedByteEntry([]byte("dummy3"), 2, 2000)...)
+	err := afs.WriteFile(fileName, logBytes, 0600)
+	assert.Nil(t, err)
+	file, err := common.OpenFileForRead(afs, fileName)
+	assert.Nil(t, err)
+	logChan := make(chan *common.EntryBatch, 10)
+	var wg sync.WaitGroup
+	result, err := ReadFile(ReadFileParams{
+		File:            file,
+		LogChan:         logChan,
+		Wg:              &wg,
+		BatchSize:       10,
+		StartByteOffset: 0,
+		EndOffset:       3,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), result.EntriesRead)
+	batch := (<-logChan).Entries
+	assert.Equal(t, "dummy1", string(batch[0].Entry))
+	assert.Equal(t, int64(0), batch[0].Timestamp)
+	assert.Equal(t, "dummy2", string(batch[1].Entry))
+	assert.Equal(t, int64(1000), batch[1].Timestamp)
+	assert.Equal(t, 6, batch[2].ByteSize)
+	assert.Equal(t, int64(2000), batch[2].Timestamp)
+
+	byteOffset, _, err := FindByteOffsetFromAndIncludingOffset(afs, fileName, 0, 2, common.FailOnCorruption)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(60), byteOffset)
+
+	offset, found, err := FindOffsetFromTimestamp(afs, fileName, 0, 1500, 3, common.FailOnCorruption)
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, common.Offset(2), offset)
+	_, found, err = FindOffsetFromTimestamp(afs, fileName, 0, 1500, 2, common.FailOnCorruption)
+	assert.Nil(t, err)
+	assert.False(t, found)
+}
+
+func TestReadFile_entries_with_metadata(t *testing.T) {
+	afs := common.MemAfs()
+	fileName := "tmp/topic1/001.log"
+	var logBytes []byte
+	logBytes = append(logBytes, common.CreateByteEntry([]byte("dummy1"), 0)...)
+	logBytes = append(logBytes, common.CreateByteEntryWithMetadata([]byte("dummy2"), 1, 1000, common.EntryMetadata{
+		Key:     []byte("key1"),
+		Headers: map[string]string{"type": "created", "source": "test"},
+	})...)
+	logBytes = append(logBytes, common.CreateByteEntryWithMetadata([]byte("dummy3"), 2, 2000, common.EntryMetadata{})...)
+	assert.Equal(t, len(logBytes), 26+common.ByteEntrySize([]byte("dummy2"), common.EntryMetadata{
+		Key:     []byte("key1"),
+		Headers: map[string]string{"type": "created", "source": "test"},
+	})+34)
+	err := afs.WriteFile(fileName, logBytes, 0600)
+	assert.Nil(t, err)
+	file, err := common.OpenFileForRead(afs, fileName)
+	assert.Nil(t, err)
+	logChan := make(chan *common.EntryBatch, 10)
+	var wg sync.WaitGroup
+	result, err := ReadFile(ReadFileParams{
+		File:            file,
+		LogChan:         logChan,
+		Wg:              &wg,
+		BatchSize:       10,
+		StartByteOffset: 0,
+		EndOffset:       3,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), result.EntriesRead)
+	batch := (<-logChan).Entries
+	assert.Nil(t, batch[0].Key)
+	assert.Equal(t, "dummy2", string(batch[1].Entry))
+	assert.Equal(t, 6, batch[1].ByteSize)
+	assert.Equal(t, int64(1000), batch[1].Timestamp)
+	assert.Equal(t, "key1", string(batch[1].Key))
+	assert.Equal(t, map[string]string{"type": "created", "source": "test"}, batch[1].Headers)
+	assert.Equal(t, "dummy3", string(batch[2].Entry))
+	assert.Nil(t, batch[2].Key)
+	assert.Nil(t, batch[2].Headers)
+}
+
+func TestReadFile_offset_gaps(t *testing.T) {
+	afs := common.MemAfs()
+	fileName := "tmp/topic1/001.log"
+	var logBytes []byte
+	for _, offset := range []common.Offset{0, 3, 7} {
+		logBytes = append(logBytes, common.CreateTimestampedByteEntry([]byte("dummy"), offset, 1000)...)
+	}
+	err := afs.WriteFile(fileName, logBytes, 0600)
+	assert.Nil(t, err)
+
+	byteOffset, _, err := FindByteOffsetFromAndIncludingOffset(afs, fileName, 0, 2, common.FailOnCorruption)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(33), byteOffset)
+	byteOffset, _, err = FindByteOffsetFromAndIncludingOffset(afs, fileName, 33, 8, common.FailOnCorruption)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(99), byteOffset)
+
+	file, err := common.OpenFileForRead(afs, fileName)
+	assert.Nil(t, err)
+	logChan := make(chan *common.EntryBatch, 10)
+	var wg sync.WaitGroup
+	result, err := ReadFile(ReadFileParams{
+		File:            file,
+		LogChan:         logChan,
+		Wg:              &wg,
+		BatchSize:       10,
+		StartByteOffset: 33,
+		EndOffset:       7,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), result.EntriesRead)
+	assert.Equal(t, common.Offset(3), result.LastLogOffset)
+	batch := (<-logChan).Entries
+	assert.Equal(t, uint64(3), batch[0].Offset)
+}
+
+func TestReadFile_compressed_block(t *testing.T) {
+	afs := common.MemAfs()
+	fileName := "tmp/topic1/001" + common.CompressedBlockExtension
+	var logBytes []byte
+	for i := 0; i < 35; i++ {
+		logBytes = append(logBytes, common.CreateTimestampedByteEntry([]byte(fmt.Sprintf("dummy%d", i)), common.Offset(i), 1000)...)
+	}
+	var compressed bytes.Buffer
+	frameIndex, err := common.CompressBlock(bytes.NewReader(logBytes), int64(len(logBytes)), &compressed, common.CompressionGzip, 10, 100)
+	assert.Nil(t, err)
+	err = afs.WriteFile(fileName, compressed.Bytes(), 0600)
+	assert.Nil(t, err)
+	idx := index.NewIndex(frameIndex)
+	assert.Equal(t, 4, len(idx.IndexOffsets))
+	assert.Equal(t, common.Offset(10), idx.IndexOffsets[1].Offset)
+
+	file, err := common.OpenFileForRead(afs, fileName)
+	assert.Nil(t, err)
+	logChan := make(chan *common.EntryBatch, 10)
+	var wg sync.WaitGroup
+	result, err := ReadFile(ReadFileParams{
+		File:            file,
+		LogChan:         logChan,
+		Wg:              &wg,
+		BatchSize:       100,
+		StartByteOffset: idx.IndexOffsets[1].ByteOffset,
+		FromOffset:      12,
+		EndOffset:       math.MaxUint64,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(23), result.EntriesRead)
+	batch := (<-logChan).Entries
+	assert.Equal(t, uint64(12), batch[0].Offset)
+	assert.Equal(t, "dummy34", string(batch[22].Entry))
+}
+
+func TestReadByteEntry_invalid_size(t *testing.T) {
+	entry := common.CreateByteEntry([]byte("dummy1"), 0)
+	entry[11] = 0xff
+	_, _, err := common.ReadByteEntry(bytes.NewReader(entry), int64(len(entry)))
+	assert.Equal(t, common.InvalidEntrySize, err)
+}
+
+// writes offsets 0,1 and 2 where the payload of offset 1 is corrupted
+func writeCorruptLog(t *testing.T, afs *afero.Afero, fileName string) {
+	corrupt := common.CreateByteEntry([]byte("dummy2"), 1)
+	corrupt[14] = 'X'
+	var logBytes []byte
+	logBytes = append(logBytes, common.CreateByteEntry([]byte("dummy1"), 0)...)
+	logBytes = append(logBytes, corrupt...)
+	logBytes = append(logBytes, common.CreateByteEntry([]byte("dummy3"), 2)...)
+	err := afs.WriteFile(fileName, logBytes, 0600)
+	assert.Nil(t, err)
+}
+
 func TestFindBlockInfo(t *testing.T) {
 	afs := common.MemAfs()
 	file, err := common.OpenFileForWrite(afs, "tmp/topic1/001.log")
@@ -169,7 +480,7 @@ func Test(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(fmt.Sprintf("byteOffset %d and offset %d", test.byteOffsetInput, test.offsetInput), func(t *testing.T) {
-			byteOffset, scanned, err := FindByteOffsetFromAndIncludingOffset(afs, fileName, test.byteOffsetInput, common.Offset(test.offsetInput))
+			byteOffset, scanned, err := FindByteOffsetFromAndIncludingOffset(afs, fileName, test.byteOffsetInput, common.Offset(test.offsetInput), common.FailOnCorruption)
 			assert.Nil(t, err)
 			assert.Equal(t, test.expectedByteOffset, byteOffset)
 			assert.Equal(t, test.expectedScanned, scanned)
@@ -196,8 +507,209 @@ func TestLoadTopicBlocks(t *testing.T) {
 	assert.Nil(t, err)
 	err = afs.WriteFile(indexFileName, idx, 0600)
 	assert.Nil(t, err)
+	// files that are not blocks are skipped
+	err = afs.WriteFile("tmp/topic1/producers.state", []byte{}, 0600)
+	assert.Nil(t, err)
 	logBlocks, indexBlocks, err := LoadTopicBlocks(afs, "tmp", "topic1")
 	assert.Nil(t, err)
 	assert.Len(t, logBlocks, 1)
 	assert.Len(t, indexBlocks, 1)
 }
+
+func TestReadFile_released_batches_are_reused(t *testing.T) {
+	afs := common.MemAfs()
+	writeBenchmarkBlock(t, afs, "tmp/topic1/001.log", 1000)
+	file, err := common.OpenFileForRead(afs, "tmp/topic1/001.log")
+	assert.Nil(t, err)
+	logChan := make(chan *common.EntryBatch)
+	var wg sync.WaitGroup
+	var offsets []uint64
+	done := make(chan bool)
+	go func() {
+		for batch := range logChan {
+			for _, entry := range batch.Entries {
+				assert.Equal(t, benchmarkEntry(int(entry.Offset)), string(entry.Entry))
+				offsets = append(offsets, entry.Offset)
+			}
+			batch.Release()
+			wg.Done()
+		}
+		done <- true
+	}()
+	_, err = ReadFile(ReadFileParams{
+		File:      file,
+		LogChan:   logChan,
+		Wg:        &wg,
+		BatchSize: 64,
+		EndOffset: math.MaxUint64,
+	})
+	assert.Nil(t, err)
+	wg.Wait()
+	close(logChan)
+	<-done
+	assert.Equal(t, 1000, len(offsets))
+	assert.Equal(t, uint64(999), offsets[999])
+}
+
+func TestReadFile_filtered_entries_are_batched(t *testing.T) {
+	afs := common.MemAfs()
+	fileName := "tmp/topic1/001.log"
+	var logBytes []byte
+	for i := 0; i < 100; i++ {
+		tenant := "b"
+		if i%4 == 0 {
+			tenant = "a"
+		}
+		payload := fmt.Sprintf(`{"order":{"tenant":"%s","n":%d,"paid":%t}}`, tenant, i, i%2 == 0)
+		logBytes = append(logBytes, common.CreateTimestampedByteEntry([]byte(payload), common.Offset(i), 1000)...)
+	}
+	err := afs.WriteFile(fileName, logBytes, 0600)
+	assert.Nil(t, err)
+
+	file, err := common.OpenFileForRead(afs, fileName)
+	assert.Nil(t, err)
+	logChan := make(chan *common.EntryBatch, 10)
+	var wg sync.WaitGroup
+	result, err := ReadFile(ReadFileParams{
+		File:      file,
+		LogChan:   logChan,
+		Wg:        &wg,
+		BatchSize: 10,
+		EndOffset: math.MaxUint64,
+		Filter:    common.JSONFieldFilter("order.tenant", "a"),
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(25), result.EntriesRead)
+	assert.Equal(t, common.Offset(99), result.LastLogOffset)
+	assert.Equal(t, 3, len(logChan))
+	assert.Equal(t, 10, len((<-logChan).Entries))
+	assert.Equal(t, 10, len((<-logChan).Entries))
+	last := (<-logChan).Entries
+	assert.Equal(t, 5, len(last))
+	assert.Equal(t, uint64(96), last[4].Offset)
+
+	regex, err := common.RegexFilter(`"n":[0-9]*7,`)
+	assert.Nil(t, err)
+	_, err = common.RegexFilter(`(`)
+	assert.NotNil(t, err)
+	filters := map[string]common.EntryFilter{
+		"number":   common.JSONFieldFilter("order.n", "42"),
+		"bool":     common.JSONFieldFilter("order.paid", "true"),
+		"missing":  common.JSONFieldFilter("order.tenant.id", "a"),
+		"prefix":   common.PrefixFilter([]byte(`{"order":{"tenant":"a"`)),
+		"contains": common.ContainsFilter([]byte(`"n":5`)),
+		"regex":    regex,
+		"all":      common.AllFilters(regex, common.JSONFieldFilter("order.tenant", "b")),
+	}
+	expected := map[string]uint64{"number": 1, "bool": 50, "missing": 0, "prefix": 25, "contains": 11, "regex": 10, "all": 10}
+	for name, filter := range filters {
+		file, err := common.OpenFileForRead(afs, fileName)
+		assert.Nil(t, err)
+		result, err := ReadFile(ReadFileParams{
+			File:      file,
+			LogChan:   make(chan *common.EntryBatch, 100),
+			Wg:        &sync.WaitGroup{},
+			BatchSize: 10,
+			EndOffset: math.MaxUint64,
+			Filter:    filter,
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, expected[name], result.EntriesRead, name)
+	}
+}
+
+func BenchmarkReadFile(b *testing.B) {
+	afs := common.MemAfs()
+	writeBenchmarkBlock(b, afs, "tmp/topic1/001.log", 10_000)
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			file, err := common.OpenFileForRead(afs, "tmp/topic1/001.log")
+			if err != nil {
+				b.Fatal(err)
+			}
+			logChan := make(chan *common.EntryBatch)
+			var wg sync.WaitGroup
+			go func() {
+				for batch := range logChan {
+					batch.Release()
+					wg.Done()
+				}
+			}()
+			_, err = ReadFile(ReadFileParams{
+				File:      file,
+				LogChan:   logChan,
+				Wg:        &wg,
+				BatchSize: 1000,
+				EndOffset: math.MaxUint64,
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			wg.Wait()
+			close(logChan)
+		}
+	})
+	b.Run("allocating", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			file, err := common.OpenFileForRead(afs, "tmp/topic1/001.log")
+			if err != nil {
+				b.Fatal(err)
+			}
+			logChan := make(chan *[]common.LogEntry)
+			go func() {
+				for range logChan {
+				}
+			}()
+			err = readFileAllocating(file, logChan, 1000)
+			if err != nil {
+				b.Fatal(err)
+			}
+			close(logChan)
+		}
+	})
+}
+
+// readFileAllocating is how blocks were read before entry batches were pooled: every entry body is
+// allocated, and every batch is copied into a new slice before it is sent
+func readFileAllocating(file afero.File, logChan chan *[]common.LogEntry, batchSize int) error {
+	reader, err := common.NewBlockReader(file, 0)
+	if err != nil {
+		return err
+	}
+	logEntries := make([]common.LogEntry, batchSize)
+	slicePointer := 0
+	for {
+		if slicePointer == batchSize {
+			logEntryCopy := make([]common.LogEntry, slicePointer)
+			copy(logEntryCopy, logEntries)
+			logChan <- &logEntryCopy
+			slicePointer = 0
+		}
+		logEntry, _, err := reader.Next()
+		if err == io.EOF {
+			sendingEntries := logEntries[:slicePointer]
+			logChan <- &sendingEntries
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		logEntries[slicePointer] = logEntry
+		slicePointer = slicePointer + 1
+	}
+}
+
+func writeBenchmarkBlock(t testing.TB, afs *afero.Afero, fileName string, entries int) {
+	var block []byte
+	for i := 0; i < entries; i++ {
+		block = append(block, common.CreateTimestampedByteEntry([]byte(benchmarkEntry(i)), common.Offset(i), int64(i))...)
+	}
+	err := afs.WriteFile(fileName, block, 0600)
+	assert.Nil(t, err)
+}
+
+func benchmarkEntry(i int) string {
+	return fmt.Sprintf("entry %d %s", i, bytes.Repeat([]byte{'x'}, i%200))
+}
@@ -9,106 +9,187 @@ import (
 	"github.com/tcw/ibsen/access/index"
 	ibsLog "github.com/tcw/ibsen/access/log"
 	"github.com/tcw/ibsen/errore"
+	"os"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type TopicAccess interface {
 	UpdateIndex() (bool, error)
+	IndexNewEntries(throttle index.ReadThrottle) (int64, error)
 	LoadOrCreate() error
-	Read(params common.ReadLogParams) error
-	Write(entries common.EntriesPtr) error
+	Read(params common.ReadLogParams) (common.Offset, error)
+	Write(entries common.EntriesPtr, metadata []common.EntryMetadata) (common.Offset, error)
+	AwaitDurable() error
+	ApplyRetention(now time.Time) ([]common.LogBlock, error)
+	Compact(now time.Time) ([]common.LogBlock, error)
+	FindOffsetForTimestamp(timestamp int64) (common.Offset, error)
+	Close() error
 }
 
 var _ TopicAccess = &Topic{}
 
 type Topic struct {
-	Afs            *afero.Afero
-	RootPath       string
-	TopicName      string
-	indexMutex     int32
-	indexWg        *sync.WaitGroup
-	MaxBlockSize   int
-	NextOffset     common.Offset
-	HeadBlockSize  int
-	LogBlockList   []common.LogBlock
-	IndexBlockList []common.IndexBlock
-	IndexPosition  *common.LogBlockPosition
+	Afs              *afero.Afero
+	RootPath         string
+	TopicName        string
+	indexLock        *sync.Mutex
+	MaxBlockSize     int
+	CorruptionPolicy common.CorruptionPolicy
+	Durability       common.Durability
+	Retention        common.RetentionPolicy
+	Compaction       common.CompactionPolicy
+	Compression      common.Compression
+	ProducerExpiry   time.Duration
+	ReadOnly         bool
+	compressedUpTo   common.LogBlock
+	blockSwapLock    *sync.RWMutex
+	indexCache       *index.TopicCache
+	groupCommit      *groupCommit
+	head             *headBlock
+	blocks           atomic.Pointer[blockSnapshot]
+	blocksLock       *sync.Mutex
+	highWatermark    atomic.Uint64
+	indexPosition    atomic.Pointer[common.LogBlockPosition]
+	lastTimestamp    int64
+	// NextOffset and HeadBlockSize are owned by the writer, readers use HighWatermark
+	NextOffset    common.Offset
+	HeadBlockSize int
+	// IndexPosition is owned by the indexer
+	IndexPosition *common.LogBlockPosition
+	// producers is the last batch of each idempotent producer, owned by the writer
+	producers       map[string]producerState
+	producerRecords int
 }
 
 func NewLogTopic(params common.TopicParams) *Topic {
-	return &Topic{
-		Afs:            params.Afs,
-		RootPath:       params.RootPath,
-		TopicName:      params.TopicName,
-		indexWg:        &sync.WaitGroup{},
-		NextOffset:     0,
-		HeadBlockSize:  0,
-		MaxBlockSize:   params.MaxBlockSize,
-		LogBlockList:   []common.LogBlock{},
-		IndexBlockList: []common.IndexBlock{},
-		IndexPosition:  nil,
-	}
-}
-
+	return NewLogTopicWithIndexCache(params, index.NewCache(params.IndexCacheSize))
+}
+
+// NewLogTopicWithIndexCache creates a topic keeping its sparse indices in a cache shared with other topics
+func NewLogTopicWithIndexCache(params common.TopicParams, indexCache *index.Cache) *Topic {
+	topic := &Topic{
+		Afs:              params.Afs,
+		RootPath:         params.RootPath,
+		TopicName:        params.TopicName,
+		indexLock:        &sync.Mutex{},
+		NextOffset:       0,
+		HeadBlockSize:    0,
+		MaxBlockSize:     params.MaxBlockSize,
+		CorruptionPolicy: params.CorruptionPolicy,
+		Durability:       params.Durability,
+		Retention:        params.Retention,
+		Compaction:       params.Compaction,
+		ProducerExpiry:   params.ProducerExpiry,
+		ReadOnly:         params.ReadOnly,
+		Compression:      params.Compression,
+		blockSwapLock:    &sync.RWMutex{},
+		indexCache:       indexCache.ForTopic(params.TopicName),
+		blocksLock:       &sync.Mutex{},
+		IndexPosition:    nil,
+		producers:        map[string]producerState{},
+	}
+	topic.blocks.Store(emptySnapshot)
+	topic.groupCommit = newGroupCommit(params.GroupCommitWindow, topic.syncHeadBlock)
+	return topic
+}
+
+// UpdateIndex indexes entries written since the last update, unless the index is being updated,
+// compacted or trimmed by retention already
 func (t *Topic) UpdateIndex() (bool, error) {
 
 	// Check if an index is currently running
-	if !atomic.CompareAndSwapInt32(&t.indexMutex, 0, 1) {
+	if !t.indexLock.TryLock() {
 		log.Debug().Msg("competing indices")
 		return false, nil
 	}
-	defer atomic.CompareAndSwapInt32(&t.indexMutex, 1, 0)
-	t.indexWg.Add(1)
-	defer func() {
-		t.indexWg.Done()
-	}()
+	defer t.indexLock.Unlock()
+	_, err := t.updateIndex(nil)
+	if err == common.NoBlocksFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// IndexNewEntries waits for a running index update, compaction or retention to finish, and indexes
+// entries written since the last update. Log bytes are charged to the throttle as they are read, if not
+// nil. Returns the number of log bytes indexed.
+func (t *Topic) IndexNewEntries(throttle index.ReadThrottle) (int64, error) {
+	t.indexLock.Lock()
+	defer t.indexLock.Unlock()
+	indexed, err := t.updateIndex(throttle)
+	if err == common.NoBlocksFound {
+		return 0, nil
+	}
+	return indexed, err
+}
+
+func (t *Topic) updateIndex(throttle index.ReadThrottle) (int64, error) {
+	var indexed int64
 
 	// index log blocks not already indexed
 	notIndexed, err := t.findBlocksToIndex()
 	if err == common.NoBlocksFound {
-		return false, nil
+		return 0, err
 	}
 	if err != nil {
-		return false, errore.Wrap(err)
+		return 0, errore.Wrap(err)
 	}
 
 	for _, block := range notIndexed {
 		// if no blocks have been indexed
 		if t.IndexPosition == nil {
-			pos, err := t.indexBlock(block, 0)
+			pos, err := t.indexBlock(block, 0, throttle)
 			if err != nil {
-				return true, errore.Wrap(err)
+				return indexed, errore.Wrap(err)
 			}
 			debugLogIndexing(t.TopicName, pos.Block, true, "first block")
-			t.addNewIndexBlock(block)
-			t.IndexPosition = &pos
+			t.addIndexBlock(block)
+			t.setIndexPosition(&pos)
+			indexed = indexed + pos.ByteOffset
 			continue
 		}
 		position := t.IndexPosition
 		// if indexing a block which is partly indexed
 		if position.Block == block {
-			pos, err := t.indexBlock(block, position.ByteOffset)
+			pos, err := t.indexBlock(block, position.ByteOffset, throttle)
 			if err != nil {
-				return true, errore.Wrap(err)
+				return indexed, errore.Wrap(err)
 			}
 			debugLogIndexing(t.TopicName, pos.Block, pos.ByteOffset == position.ByteOffset, "existing block")
-			t.IndexPosition = &pos
+			t.setIndexPosition(&pos)
+			indexed = indexed + pos.ByteOffset - position.ByteOffset
 			continue
 		}
 		// indexing a new block after start block
-		pos, err := t.indexBlock(block, 0)
+		pos, err := t.indexBlock(block, 0, throttle)
 		debugLogIndexing(t.TopicName, pos.Block, true, "new block")
 		if err != nil {
-			return true, errore.Wrap(err)
+			return indexed, errore.Wrap(err)
 		}
-		t.addNewIndexBlock(block)
-		t.IndexPosition = &pos
+		t.addIndexBlock(block)
+		t.setIndexPosition(&pos)
+		indexed = indexed + pos.ByteOffset
+	}
+	err = t.compressSealedBlocks()
+	if err != nil {
+		return indexed, errore.Wrap(err)
 	}
-	return true, nil
+	return indexed, nil
 }
 
+// LoadOrCreate loads the topic from its files, and recovers writes and block changes interrupted when
+// ibsen was stopped. Must be serialized with Write, a read only topic never changes any files.
 func (t *Topic) LoadOrCreate() error {
+	// the index position is replaced, so wait for indexing started by earlier writes
+	t.indexLock.Lock()
+	defer t.indexLock.Unlock()
+	// the head block is truncated by recovery, and may be a different block after loading
+	err := t.closeHeadBlock()
+	if err != nil {
+		return errore.Wrap(err)
+	}
 	created, err := ibsLog.CreateTopicDirectory(t.Afs, t.RootPath, t.TopicName)
 	if created {
 		return nil
@@ -121,181 +202,484 @@ func (t *Topic) LoadOrCreate() error {
 	if len(logBlocks) == 0 {
 		return common.NoBlocksFound
 	}
-	t.IndexBlockList = indexBlocks
-	t.LogBlockList = logBlocks
+	t.blocks.Store(&blockSnapshot{
+		logBlocks:   logBlocks,
+		indexBlocks: indexBlocks,
+	})
+	if !t.ReadOnly {
+		// temporary files may belong to compaction or compression by the writing instance
+		err = t.recoverTemporaryFiles()
+		if err != nil {
+			return errore.Wrap(err)
+		}
+	}
 
 	// Find position of last entry write to log
-	head, hasBlockHead := t.logBlockHead()
+	head, hasBlockHead := t.snapshot().logBlockHead()
 	if !hasBlockHead {
 		return errore.New("Topic " + t.TopicName + " has no block head")
 	}
-	offset, byteSize, err := t.findCurrentLogPosition(err, head)
+	recovery, err := t.recoverHeadBlock(head)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	if recovery.HasEntries {
+		t.NextOffset = recovery.LastOffset + 1
+	} else {
+		t.NextOffset = common.Offset(head)
+	}
+	t.HeadBlockSize = int(recovery.ValidByteSize)
+	if recovery.Sealed {
+		// the next write starts a new block
+		t.HeadBlockSize = t.MaxBlockSize + 1
+	}
+	t.lastTimestamp = recovery.LastTimestamp
+	t.publishHighWatermark()
+	err = t.loadProducerState()
 	if err != nil {
 		return errore.Wrap(err)
 	}
-	t.NextOffset = offset + 1
-	t.HeadBlockSize = int(byteSize)
 
 	// Find position of last entry write to index
 	position, _, err := t.findCurrentIndexLogBlockPosition()
 	if err != nil {
 		return errore.Wrap(err)
 	}
-	t.IndexPosition = position
+	t.setIndexPosition(position)
 	t.debugLogLoadResult(logBlocks, indexBlocks)
 	return nil
 }
 
 // ReadLog
-// Reads a log from and including the ReadLogParams.From offset until end of log.
-func (t *Topic) Read(params common.ReadLogParams) error {
+// Reads a log from and including the ReadLogParams.From offset until the high-watermark at the time
+// the read starts. Returns the offset a following read continues from: after the last entry scanned
+// when a read limit stops the read, else the end of the read. Entries below it that were not sent are
+// filtered out or removed by compaction. A block removed by retention before it is read ends the read
+// with OffsetBeforeLogStart, and the offset of the removed block.
+func (t *Topic) Read(params common.ReadLogParams) (common.Offset, error) {
 	// ensures reader will not read partially written log entries from file
-	endOffset, exists := t.findLastConfirmedWrittenEntryOffset(params.From)
-	if !exists {
-		return common.NoEntriesFound
+	endOffset := t.HighWatermark()
+	if params.EndOffset > 0 && params.EndOffset < endOffset {
+		endOffset = params.EndOffset
 	}
-	block, found := t.logBlockContaining(params.From)
-	if !found {
-		return errore.New("offset out of bounds, this should never happen!")
+	blocks := t.snapshot()
+	if blocks.isEmpty() || params.From >= endOffset {
+		return params.From, common.NoEntriesFound
 	}
-
-	// find byte offset in file to set seek point to
-	byteOffset, scanCount, err := t.findByteOffsetInLogBlockFile(params.From)
-	if err == ibsLog.NoByteOffsetFound {
-		return common.NoEntriesFound
+	if logStart, hasStart := blocks.logStartOffset(); hasStart && params.From < logStart {
+		return params.From, errore.WrapWithContextF(common.OffsetBeforeLogStart, "offset %d is before log start %d", params.From, logStart)
 	}
-	if err != nil {
-		return errore.Wrap(err)
+	block, found := blocks.logBlockContaining(params.From, endOffset)
+	if !found {
+		return params.From, errore.New("offset out of bounds, this should never happen!")
 	}
-	t.debugLogIndexLookup(params.From, byteOffset, scanCount)
 
-	// find log file that contains offset
-	fileName, err := t.logBlockFileName(block)
-	if err != nil {
-		return errore.Wrap(err)
+	// the byte offset is only valid for the log block file it was found in, if the block is compacted
+	// while it is read the reader continues reading the file it has open
+	t.blockSwapLock.RLock()
+	file, byteOffset, err := t.openLogBlockAtOffset(blocks, block, params.From, endOffset)
+	t.blockSwapLock.RUnlock()
+	if errors.Is(err, common.FileNotFound) {
+		return params.From, blockRemovedError(block)
 	}
-	file, err := common.OpenFileForRead(t.Afs, fileName)
 	if err != nil {
-		return errore.Wrap(err)
+		return params.From, err
 	}
 
 	// read log file from byte offset position (with seek)
-	_, err = ibsLog.ReadFile(ibsLog.ReadFileParams{
-		File:            file,
-		LogChan:         params.LogChan,
-		Wg:              params.Wg,
-		BatchSize:       params.BatchSize,
-		StartByteOffset: byteOffset,
-		EndOffset:       endOffset,
+	read, err := ibsLog.ReadFile(ibsLog.ReadFileParams{
+		File:             file,
+		LogChan:          params.LogChan,
+		Wg:               params.Wg,
+		BatchSize:        params.BatchSize,
+		StartByteOffset:  byteOffset,
+		FromOffset:       params.From,
+		EndOffset:        endOffset,
+		CorruptionPolicy: t.CorruptionPolicy,
+		MaxEntries:       params.MaxEntries,
+		MaxBytes:         params.MaxBytes,
+		Filter:           params.Filter,
 	})
 	if err != nil {
 		closeFile(file)
-		return errore.Wrap(err)
+		return params.From, t.annotateCorruption(err, block)
 	}
 	closeFile(file)
+	if readLimitReached(params, read) {
+		return read.NextOffset(), nil
+	}
 
 	// read remaining log files
-	wasFound, i := t.findBlockArrayIndex(block)
-	if wasFound {
-		if t.logSize()-1 == i {
-			return nil
+	wasFound, i := blocks.findBlockArrayIndex(block)
+	if !wasFound {
+		return endOffset, nil
+	}
+	for _, b := range blocks.logBlocks[i+1:] {
+		if common.Offset(b) >= endOffset {
+			break
 		}
-		for _, b := range t.LogBlockList[i+1:] {
-			fileName, err = t.logBlockFileName(b)
-			file, err = common.OpenFileForRead(t.Afs, fileName)
-			if errors.Is(err, common.FileNotFound) {
-				closeFile(file)
-				break
-			}
-			if err != nil {
-				closeFile(file)
-				return errore.Wrap(err)
-			}
-			endOffset, _ = t.endBoundaryForReadOffset()
-			_, err = ibsLog.ReadFile(ibsLog.ReadFileParams{
-				File:            file,
-				LogChan:         params.LogChan,
-				Wg:              params.Wg,
-				BatchSize:       params.BatchSize,
-				StartByteOffset: 0,
-				EndOffset:       endOffset,
-			})
+		t.blockSwapLock.RLock()
+		file, err = t.openLogBlock(b)
+		t.blockSwapLock.RUnlock()
+		if errors.Is(err, common.FileNotFound) {
+			// removed by retention after the snapshot was taken, the entries in it are never sent
+			return common.Offset(b), blockRemovedError(b)
+		}
+		if err != nil {
+			return common.Offset(b), errore.Wrap(err)
+		}
+		result, err := ibsLog.ReadFile(ibsLog.ReadFileParams{
+			File:             file,
+			LogChan:          params.LogChan,
+			Wg:               params.Wg,
+			BatchSize:        params.BatchSize,
+			StartByteOffset:  0,
+			EndOffset:        endOffset,
+			CorruptionPolicy: t.CorruptionPolicy,
+			MaxEntries:       remainingLimit(params.MaxEntries, read.EntriesRead),
+			MaxBytes:         remainingLimit(params.MaxBytes, read.BytesRead),
+			Filter:           params.Filter,
+		})
+		if err != nil {
+			closeFile(file)
+			return common.Offset(b), t.annotateCorruption(err, b)
+		}
+		closeFile(file)
+		read.Update(result)
+		if readLimitReached(params, read) {
+			return read.NextOffset(), nil
+		}
+	}
+	return endOffset, nil
+}
+
+func blockRemovedError(block common.LogBlock) error {
+	return errore.WrapWithContextF(common.OffsetBeforeLogStart, "block %d was removed by retention while it was read", block)
+}
+
+func readLimitReached(params common.ReadLogParams, read ibsLog.ReadResult) bool {
+	return (params.MaxEntries > 0 && read.EntriesRead >= params.MaxEntries) ||
+		(params.MaxBytes > 0 && read.BytesRead >= params.MaxBytes)
+}
+
+// remainingLimit is what is left of a read limit after a number of entries or bytes are read, a read
+// with the limit reached is not continued, so zero is still no limit
+func remainingLimit(limit uint64, read uint64) uint64 {
+	if limit == 0 {
+		return 0
+	}
+	return limit - read
+}
+
+func (t *Topic) openLogBlockAtOffset(blocks *blockSnapshot, block common.LogBlock, offset common.Offset, highWatermark common.Offset) (afero.File, int64, error) {
+	// find byte offset in file to set seek point to
+	byteOffset, scanCount, err := t.findByteOffsetInLogBlockFile(blocks, offset, highWatermark)
+	if err != nil {
+		return nil, 0, t.annotateCorruption(err, block)
+	}
+	t.debugLogIndexLookup(offset, byteOffset, scanCount)
+	file, err := t.openLogBlock(block)
+	if err != nil {
+		return nil, 0, errore.Wrap(err)
+	}
+	return file, byteOffset, nil
+}
+
+func (t *Topic) openLogBlock(block common.LogBlock) (afero.File, error) {
+	fileName, err := t.readableLogBlockFileName(block)
+	if err != nil {
+		return nil, errore.Wrap(err)
+	}
+	return common.OpenFileForRead(t.Afs, fileName)
+}
+
+// FindOffsetForTimestamp finds the first offset written at or after the timestamp (unix nanoseconds).
+// If all entries are older, the next offset to be written is returned.
+func (t *Topic) FindOffsetForTimestamp(timestamp int64) (common.Offset, error) {
+	endOffset := t.HighWatermark()
+	blocks := t.snapshot()
+	if endOffset == 0 || blocks.isEmpty() {
+		return endOffset, nil
+	}
+	t.blockSwapLock.RLock()
+	defer t.blockSwapLock.RUnlock()
+	// find the newest block with an indexed entry written before the timestamp
+	startBlock := 0
+	startOffset := common.Offset(blocks.logBlocks[0])
+	for i := blocks.logSize() - 1; i >= 0; i-- {
+		timeIndex, err := t.getTimeIndexFromIndexBlock(common.IndexBlock(blocks.logBlocks[i]))
+		if err != nil {
+			return 0, errore.Wrap(err)
+		}
+		if timeIndex == nil {
+			continue
+		}
+		nearest, found := timeIndex.FindNearestOffsetBefore(timestamp)
+		if found {
+			startBlock = i
+			startOffset = nearest.Offset
+			break
+		}
+	}
+	for i, block := range blocks.logBlocks[startBlock:] {
+		fileName, err := t.readableLogBlockFileName(block)
+		if err != nil {
+			return 0, errore.Wrap(err)
+		}
+		var byteOffset int64 = 0
+		if i == 0 && startOffset < endOffset {
+			byteOffset, _, err = t.findByteOffsetInLogBlockFile(blocks, startOffset, endOffset)
 			if err != nil {
-				closeFile(file)
-				return errore.Wrap(err)
+				return 0, t.annotateCorruption(err, block)
 			}
-			closeFile(file)
+		}
+		offset, found, err := ibsLog.FindOffsetFromTimestamp(t.Afs, fileName, byteOffset, timestamp, endOffset, t.CorruptionPolicy)
+		if err != nil {
+			return 0, t.annotateCorruption(err, block)
+		}
+		if found {
+			return offset, nil
 		}
 	}
-	return nil
+	return endOffset, nil
 }
 
-func (t *Topic) Write(entries common.EntriesPtr) error {
+// Write appends entries to the head block. metadata is either nil or holds the key and headers
+// for the entry with the same index. Returns the offset of the first entry, the entries in the batch
+// are given consecutive offsets.
+func (t *Topic) Write(entries common.EntriesPtr, metadata []common.EntryMetadata) (common.Offset, error) {
+	if metadata != nil && len(metadata) != len(*entries) {
+		return 0, errore.NewF("got metadata for %d entries in a batch of %d", len(metadata), len(*entries))
+	}
 
+	head, hasBlockHead := t.snapshot().logBlockHead()
+	createdBlock := false
 	// if topic is empty create the first log block
-	if t.logBlockIsEmpty() {
-		t.addNewLogBlock()
+	if !hasBlockHead {
+		head = common.LogBlock(t.NextOffset)
+		createdBlock = true
 	}
 	// if block has excited is max size create a new block
 	if t.HeadBlockSize > t.MaxBlockSize {
-		t.addNewLogBlock()
-		t.resetHeadBlockSize()
+		// writes waiting for group commit are in the sealed block
+		if t.Durability == common.GroupCommit {
+			err := t.syncHeadBlock()
+			if err != nil {
+				return 0, errore.Wrap(err)
+			}
+		}
+		head = common.LogBlock(t.NextOffset)
+		createdBlock = true
 	}
-	// create a byte representation of entries and write to disk
-	bytes, offsets := t.buildBinaryEntryRepresentation(entries)
-	head, hasBlockHead := t.logBlockHead()
-	if !hasBlockHead {
-		return errors.New("Topic " + t.TopicName + " has no block head")
+	// write headers and payloads of all entries to the head block in one go
+	file, err := t.openHeadBlock(head)
+	if err != nil {
+		return 0, errore.Wrap(err)
 	}
-	blockFileName, err := t.logBlockFileName(head)
-
-	file, err := common.OpenFileForWrite(t.Afs, blockFileName)
+	firstOffset := t.NextOffset
+	batch := t.encodeBatch(entries, metadata)
+	offsets := len(*entries)
+	n, err := common.WriteVectored(file, batch.buffers)
+	batch.release()
 	if err != nil {
-		ioErr := file.Close()
+		// the handle is opened again on the next write
+		ioErr := t.closeHeadBlock()
 		if ioErr != nil {
-			return errore.WrapError(ioErr, err)
+			return 0, errore.WrapError(ioErr, err)
 		}
-		return err
+		return 0, errore.Wrap(err)
 	}
-
-	n, err := file.Write(bytes)
-	if err != nil {
-		ioErr := file.Close()
-		if ioErr != nil {
-			return errore.WrapError(ioErr, err)
+	if t.Durability == common.FsyncPerWrite {
+		err = file.Sync()
+		if err != nil {
+			return 0, errore.Wrap(err)
+		}
+	}
+	if createdBlock && t.Durability != common.DurabilityNone {
+		err = common.SyncDirectory(t.Afs, t.RootPath+common.Sep+t.TopicName)
+		if err != nil {
+			return 0, errore.Wrap(err)
 		}
-		return err
 	}
 
-	// update internal log state
+	// update internal log state, and publish the new block before the entries in it
+	if createdBlock {
+		t.resetHeadBlockSize()
+		t.addLogBlock(head)
+	}
 	t.incrementOffset(offsets)
 	t.incrementHeadBlockSize(n)
+	t.publishHighWatermark()
+	return firstOffset, nil
+}
 
-	// update index async if no index is running
-	go func() {
-		wasExecuted, err := t.UpdateIndex()
-		if err != nil {
-			log.Warn().Err(err)
-		}
-		log.Trace().Msg(fmt.Sprintf("index update executed: %t", wasExecuted))
-	}()
-	ioErr := file.Close()
-	if ioErr != nil {
-		return ioErr
+// AwaitDurable blocks until previous writes are durable according to the topics durability mode
+func (t *Topic) AwaitDurable() error {
+	if t.Durability != common.GroupCommit {
+		return nil
+	}
+	return t.groupCommit.await()
+}
+
+func (t *Topic) syncHeadBlock() error {
+	head, hasBlockHead := t.snapshot().logBlockHead()
+	if !hasBlockHead {
+		return nil
+	}
+	blockFileName, err := t.logBlockFileName(head)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	err = syncFile(t.Afs, blockFileName)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	// the state of idempotent producers is written after their batches
+	producerStateFileName := t.producerStateFileName()
+	hasProducers, err := t.Afs.Exists(producerStateFileName)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	if hasProducers {
+		return syncFile(t.Afs, producerStateFileName)
 	}
 	return nil
 }
 
-func (t *Topic) findCurrentLogPosition(err error, head common.LogBlock) (common.Offset, int64, error) {
+func syncFile(afs *afero.Afero, fileName string) error {
+	file, err := common.OpenFileForWrite(afs, fileName)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	err = file.Sync()
+	if err != nil {
+		ioErr := file.Close()
+		if ioErr != nil {
+			return errore.WrapError(ioErr, err)
+		}
+		return errore.Wrap(err)
+	}
+	return file.Close()
+}
+
+// recoverHeadBlock truncates a partially written entry at the end of the head block, left behind
+// if ibsen was stopped in the middle of a write, and removes index entries pointing past the new end
+func (t *Topic) recoverHeadBlock(head common.LogBlock) (ibsLog.BlockRecovery, error) {
 	blockFileName, err := t.logBlockFileName(head)
 	if err != nil {
-		return 0, 0, errore.Wrap(err)
+		return ibsLog.BlockRecovery{}, errore.Wrap(err)
+	}
+	recovery, err := ibsLog.RecoverBlock(t.Afs, blockFileName)
+	var corruption *common.CorruptEntryError
+	if errors.As(err, &corruption) {
+		return t.sealCorruptHeadBlock(head, blockFileName, recovery, corruption)
 	}
-	offset, byteSize, err := ibsLog.BlockInfo(t.Afs, blockFileName)
 	if err != nil {
-		return 0, 0, errore.Wrap(err)
+		return ibsLog.BlockRecovery{}, errore.Wrap(err)
+	}
+	if recovery.CorruptEntries > 0 {
+		log.Warn().Str("topic", t.TopicName).
+			Uint64("logBlock", uint64(head)).
+			Int("corruptEntries", recovery.CorruptEntries).
+			Msg("head block contains corrupt entries")
+	}
+	if recovery.DroppedBytes == 0 {
+		return recovery, nil
+	}
+	if t.ReadOnly {
+		// the partial entry is above the high-watermark, so it is never read
+		log.Warn().Str("topic", t.TopicName).
+			Uint64("logBlock", uint64(head)).
+			Int64("partialBytes", recovery.DroppedBytes).
+			Msg("read only, partially written entry in head block is not truncated")
+		return recovery, nil
+	}
+	droppedIndexEntries := 0
+	indexBlockFileName, err := t.indexBlockFileName(common.IndexBlock(head))
+	if err != nil {
+		return ibsLog.BlockRecovery{}, errore.Wrap(err)
 	}
-	return offset, byteSize, nil
+	timeIndexBlockFileName, err := t.timeIndexBlockFileName(common.IndexBlock(head))
+	if err != nil {
+		return ibsLog.BlockRecovery{}, errore.Wrap(err)
+	}
+	t.indexCache.Remove(common.IndexBlock(head))
+	err = ibsLog.TruncateBlock(t.Afs, blockFileName, recovery.ValidByteSize)
+	if err == nil && t.hasIndexBlock(common.IndexBlock(head)) {
+		droppedIndexEntries, err = index.TruncateIndexFile(t.Afs, indexBlockFileName, recovery.ValidByteSize)
+	}
+	if err == nil && t.hasTimeIndexBlock(common.IndexBlock(head)) {
+		nextOffset := common.Offset(head)
+		if recovery.HasEntries {
+			nextOffset = recovery.LastOffset + 1
+		}
+		_, err = index.TruncateTimeIndexFile(t.Afs, timeIndexBlockFileName, nextOffset)
+	}
+	if err != nil {
+		log.Warn().Str("topic", t.TopicName).
+			Uint64("logBlock", uint64(head)).
+			Err(err).
+			Msg("unable to truncate partially written entry, continuing without truncation")
+	}
+	log.Warn().Str("topic", t.TopicName).
+		Uint64("logBlock", uint64(head)).
+		Bool("hasEntries", recovery.HasEntries).
+		Uint64("lastValidOffset", uint64(recovery.LastOffset)).
+		Int64("validByteSize", recovery.ValidByteSize).
+		Int64("droppedBytes", recovery.DroppedBytes).
+		Int("droppedIndexEntries", droppedIndexEntries).
+		Msg("recovered head block after partial write")
+	return recovery, nil
+}
+
+// sealCorruptHeadBlock handles an entry with an invalid size before the end of the head block. The entries
+// after it were acknowledged, so the block is not truncated. With the fail policy the topic is not loaded,
+// otherwise the block is kept as it is and writes continue in a new block, after the offset of the last
+// entry in the block.
+func (t *Topic) sealCorruptHeadBlock(head common.LogBlock, blockFileName string, recovery ibsLog.BlockRecovery,
+	corruption *common.CorruptEntryError) (ibsLog.BlockRecovery, error) {
+
+	if !recovery.HasEntries {
+		corruption.Offset = common.Offset(head)
+	}
+	err := t.annotateCorruption(corruption, head)
+	if t.CorruptionPolicy == common.FailOnCorruption {
+		return ibsLog.BlockRecovery{}, err
+	}
+	lastOffset, fileSize, infoErr := ibsLog.BlockInfo(t.Afs, blockFileName)
+	if infoErr != nil {
+		return ibsLog.BlockRecovery{}, errore.WrapError(infoErr, err)
+	}
+	// every entry takes at least 20 bytes, an offset that does not fit is not the offset of a last entry
+	unreadableEntries := uint64(fileSize-recovery.ValidByteSize) / 20
+	if lastOffset < corruption.Offset || lastOffset < common.Offset(head) ||
+		uint64(lastOffset-corruption.Offset) >= unreadableEntries {
+		return ibsLog.BlockRecovery{}, err
+	}
+	log.Error().Str("topic", t.TopicName).
+		Uint64("logBlock", uint64(head)).
+		Int64("byteOffset", corruption.ByteOffset).
+		Err(corruption).
+		Msg("head block has a corrupt entry, the block is kept and new entries are written to a new block")
+	recovery.HasEntries = true
+	recovery.LastOffset = lastOffset
+	recovery.ValidByteSize = fileSize
+	recovery.DroppedBytes = 0
+	recovery.Sealed = true
+	return recovery, nil
+}
+
+func (t *Topic) hasTimeIndexBlock(block common.IndexBlock) bool {
+	fileName, err := t.timeIndexBlockFileName(block)
+	if err != nil {
+		return false
+	}
+	exists, err := t.Afs.Exists(fileName)
+	return err == nil && exists
+}
+
+func (t *Topic) hasIndexBlock(block common.IndexBlock) bool {
+	return t.snapshot().hasIndexBlock(block)
 }
 
 func (t *Topic) debugLogLoadResult(logBlocks []common.LogBlock, indexBlocks []common.IndexBlock) {
@@ -319,6 +703,16 @@ func debugLogIndexing(topicName string, logBlock common.LogBlock, indexUpdated b
 	}
 }
 
+// annotateCorruption adds topic and block to a corrupt entry error, so it can be reported to clients
+func (t *Topic) annotateCorruption(err error, block common.LogBlock) error {
+	var corruption *common.CorruptEntryError
+	if errors.As(err, &corruption) {
+		corruption.Topic = t.TopicName
+		corruption.Block = block
+	}
+	return errore.Wrap(err)
+}
+
 func closeFile(file afero.File) {
 	if file == nil {
 		return
@@ -329,22 +723,8 @@ func closeFile(file afero.File) {
 	}
 }
 
-func (t *Topic) findLastConfirmedWrittenEntryOffset(from common.Offset) (common.Offset, bool) {
-	if t.logBlockIsEmpty() {
-		return 0, false
-	}
-	endOffset, hasEnd := t.endBoundaryForReadOffset()
-	if !hasEnd {
-		return 0, false
-	}
-	if from >= endOffset {
-		return 0, false
-	}
-	return endOffset, true
-}
-
 func (t *Topic) ToString() string {
-	list := t.LogBlockList
+	list := t.LogBlocks()
 	blocklist := ""
 	for i, val := range list {
 		blocklist = blocklist + fmt.Sprintf("%d -> %d\n", i, val)
@@ -352,52 +732,53 @@ func (t *Topic) ToString() string {
 	return blocklist
 }
 
-func (t *Topic) buildBinaryEntryRepresentation(entries common.EntriesPtr) ([]byte, int) {
-	neededAllocation := 0
-	for _, entry := range *entries {
-		neededAllocation = neededAllocation + len(entry) + 20
-	}
-	var bytes = make([]byte, neededAllocation)
-	start := 0
-	end := 0
-	entriesWritten := 0
-	for _, entry := range *entries {
-		byteEntry := common.CreateByteEntry(entry, t.NextOffset+common.Offset(entriesWritten))
-		end = start + len(byteEntry)
-		copy(bytes[start:end], byteEntry)
-		start = start + len(byteEntry)
-		entriesWritten = entriesWritten + 1
+func entryMetadata(metadata []common.EntryMetadata, i int) common.EntryMetadata {
+	if metadata == nil {
+		return common.EntryMetadata{}
 	}
-	return bytes, entriesWritten
+	return metadata[i]
 }
 
-func (t *Topic) endBoundaryForReadOffset() (common.Offset, bool) {
-	if t.NextOffset == 0 {
-		return 0, false
+// nextTimestamp never goes back in time, so the time index can be searched even if the clock is adjusted
+func (t *Topic) nextTimestamp() int64 {
+	now := time.Now().UnixNano()
+	if now > t.lastTimestamp {
+		t.lastTimestamp = now
 	}
-	return t.NextOffset, true
+	return t.lastTimestamp
 }
 
 func (t *Topic) logBlockFileName(block common.LogBlock) (string, error) {
-	if t.logBlockIsEmpty() {
+	if t.snapshot().isEmpty() {
 		return "", common.NoBlocksFound
 	}
-	return t.RootPath + common.Sep + t.TopicName + common.Sep + fmt.Sprintf("%020d.log", block), nil
+	return t.blockFileName(uint64(block), "log"), nil
 }
 
 func (t *Topic) indexBlockFileName(block common.IndexBlock) (string, error) {
-	if t.logBlockIsEmpty() {
+	if t.snapshot().isEmpty() {
+		return "", common.NoBlocksFound
+	}
+	return t.blockFileName(uint64(block), "idx"), nil
+}
+
+func (t *Topic) timeIndexBlockFileName(block common.IndexBlock) (string, error) {
+	if t.snapshot().isEmpty() {
 		return "", common.NoBlocksFound
 	}
-	return t.RootPath + common.Sep + t.TopicName + common.Sep + fmt.Sprintf("%020d.idx", block), nil
+	return t.blockFileName(uint64(block), "tidx"), nil
+}
+
+func (t *Topic) blockFileName(block uint64, extension string) string {
+	return t.RootPath + common.Sep + t.TopicName + common.Sep + fmt.Sprintf("%020d.%s", block, extension)
 }
 
-func (t *Topic) indexBlock(block common.LogBlock, byteOffset int64) (common.LogBlockPosition, error) {
+func (t *Topic) indexBlock(block common.LogBlock, byteOffset int64, throttle index.ReadThrottle) (common.LogBlockPosition, error) {
 	logBlockFilename, err := t.logBlockFileName(block)
 	if err != nil {
 		return common.LogBlockPosition{}, errore.Wrap(err)
 	}
-	indexAsBytes, newByteOffset, err := index.CreateBinaryIndexFromLogFile(t.Afs, logBlockFilename, byteOffset, 10)
+	indices, newByteOffset, err := index.CreateBinaryIndicesFromLogFile(t.Afs, logBlockFilename, byteOffset, 10, throttle)
 	if err != nil {
 		return common.LogBlockPosition{}, errore.Wrap(err)
 	}
@@ -405,11 +786,16 @@ func (t *Topic) indexBlock(block common.LogBlock, byteOffset int64) (common.LogB
 	if err != nil {
 		return common.LogBlockPosition{}, errore.Wrap(err)
 	}
-	file, err := common.OpenFileForWrite(t.Afs, indexBlockFilename)
+	err = appendToFile(t.Afs, indexBlockFilename, indices.Offsets)
 	if err != nil {
 		return common.LogBlockPosition{}, errore.Wrap(err)
 	}
-	_, err = file.Write(indexAsBytes)
+	t.indexCache.Append(common.IndexBlock(block), indices.Offsets)
+	timeIndexBlockFilename, err := t.timeIndexBlockFileName(common.IndexBlock(block))
+	if err != nil {
+		return common.LogBlockPosition{}, errore.Wrap(err)
+	}
+	err = appendToFile(t.Afs, timeIndexBlockFilename, indices.Timestamps)
 	if err != nil {
 		return common.LogBlockPosition{}, errore.Wrap(err)
 	}
@@ -419,8 +805,24 @@ func (t *Topic) indexBlock(block common.LogBlock, byteOffset int64) (common.LogB
 	}, nil
 }
 
+func appendToFile(afs *afero.Afero, fileName string, bytes []byte) error {
+	file, err := common.OpenFileForWrite(afs, fileName)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	_, err = file.Write(bytes)
+	if err != nil {
+		ioErr := file.Close()
+		if ioErr != nil {
+			return errore.WrapError(ioErr, err)
+		}
+		return errore.Wrap(err)
+	}
+	return file.Close()
+}
+
 func (t *Topic) findCurrentIndexLogBlockPosition() (*common.LogBlockPosition, bool, error) {
-	indexBlockHead, hasBlock := t.indexBlockHead()
+	indexBlockHead, hasBlock := t.snapshot().indexBlockHead()
 	if !hasBlock {
 		return nil, false, nil
 	}
@@ -440,24 +842,28 @@ func (t *Topic) findCurrentIndexLogBlockPosition() (*common.LogBlockPosition, bo
 	}, true, nil
 }
 
-func (t *Topic) findByteOffsetInLogBlockFile(offset common.Offset) (int64, int, error) {
-	indexBlock, foundIndexBlock := t.indexBlockContaining(offset)
-	if offset >= t.NextOffset {
+func (t *Topic) findByteOffsetInLogBlockFile(blocks *blockSnapshot, offset common.Offset, highWatermark common.Offset) (int64, int, error) {
+	indexBlock, foundIndexBlock := blocks.indexBlockContaining(offset)
+	if offset >= highWatermark {
 		return 0, 0, errors.New("offset out of bounds")
 	}
-	logBlock, logBlockFound := t.logBlockContaining(offset)
+	logBlock, logBlockFound := blocks.logBlockContaining(offset, highWatermark)
 	if !logBlockFound {
 		return 0, 0, errors.New("no log block containing offset found")
 	}
 	if uint64(logBlock) == uint64(offset) {
 		return 0, 0, nil
 	}
-	logBlockFileName, err := t.logBlockFileName(logBlock)
+	logBlockFileName, err := t.readableLogBlockFileName(logBlock)
 	if err != nil {
 		return 0, 0, errore.Wrap(err)
 	}
-	if !foundIndexBlock {
-		return ibsLog.FindByteOffsetFromAndIncludingOffset(t.Afs, logBlockFileName, 0, offset)
+	// the nearest index block is for an earlier log block if this block is not indexed yet
+	if !foundIndexBlock || indexBlock != common.IndexBlock(logBlock) {
+		if common.IsCompressedBlock(logBlockFileName) {
+			return 0, 0, nil
+		}
+		return ibsLog.FindByteOffsetFromAndIncludingOffset(t.Afs, logBlockFileName, 0, offset, t.CorruptionPolicy)
 	}
 	idx, err := t.getIndexFromIndexBlock(indexBlock)
 	if err != nil {
@@ -467,34 +873,50 @@ func (t *Topic) findByteOffsetInLogBlockFile(offset common.Offset) (int64, int,
 	if indexOffset.Offset > offset {
 		return 0, 0, errore.NewF("found larger offset than upper bound")
 	}
-	if indexOffset.Offset == offset {
+	// the index of a compressed block points at frames, which are read from the start
+	if indexOffset.Offset == offset || common.IsCompressedBlock(logBlockFileName) {
 		return indexOffset.ByteOffset, 0, nil
 	}
 
-	return ibsLog.FindByteOffsetFromAndIncludingOffset(t.Afs, logBlockFileName, indexOffset.ByteOffset, offset)
+	return ibsLog.FindByteOffsetFromAndIncludingOffset(t.Afs, logBlockFileName, indexOffset.ByteOffset, offset, t.CorruptionPolicy)
 }
 
+// getIndexFromIndexBlock returns the index from the index cache, the index block file is only read
+// when the index is not cached
 func (t *Topic) getIndexFromIndexBlock(block common.IndexBlock) (*index.Index, error) {
 	indexBlockFileName, err := t.indexBlockFileName(block)
 	if err != nil {
 		return nil, errore.Wrap(err)
 	}
-	exists, err := t.Afs.Exists(indexBlockFileName)
+	idx, err := t.indexCache.GetOrLoad(block, func() ([]byte, error) {
+		return t.Afs.ReadFile(indexBlockFileName)
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
 	if err != nil {
-		return nil, err
+		return nil, errore.Wrap(err)
 	}
-	if !exists {
-		return nil, nil
+	return idx, nil
+}
+
+func (t *Topic) getTimeIndexFromIndexBlock(block common.IndexBlock) (*index.TimeIndex, error) {
+	timeIndexBlockFileName, err := t.timeIndexBlockFileName(block)
+	if err != nil {
+		return nil, errore.Wrap(err)
 	}
-	bytes, err := t.Afs.ReadFile(indexBlockFileName)
+	exists, err := t.Afs.Exists(timeIndexBlockFileName)
 	if err != nil {
 		return nil, errore.Wrap(err)
 	}
-	idx := index.NewIndex(bytes)
+	if !exists {
+		return nil, nil
+	}
+	bytes, err := t.Afs.ReadFile(timeIndexBlockFileName)
 	if err != nil {
 		return nil, errore.Wrap(err)
 	}
-	return idx, nil
+	return index.NewTimeIndex(bytes), nil
 }
 
 func (t *Topic) incrementOffset(n int) {
@@ -509,95 +931,34 @@ func (t *Topic) resetHeadBlockSize() {
 	t.HeadBlockSize = 0
 }
 
-func (t *Topic) addNewLogBlock() {
-	t.LogBlockList = append(t.LogBlockList, common.LogBlock(t.NextOffset))
-}
-
-func (t *Topic) addNewIndexBlock(logBlock common.LogBlock) {
-	t.IndexBlockList = append(t.IndexBlockList, common.IndexBlock(logBlock))
-}
-
-func (t *Topic) logBlockHead() (common.LogBlock, bool) {
-	if t.logBlockIsEmpty() {
-		return 0, false
-	}
-	return t.LogBlockList[len(t.LogBlockList)-1], true
-}
-
-func (t *Topic) indexBlockHead() (common.IndexBlock, bool) {
-	if t.logBlockIsEmpty() {
-		return 0, false
-	}
-	if len(t.IndexBlockList) == 0 {
-		return 0, false
-	}
-	return t.IndexBlockList[len(t.IndexBlockList)-1], true
-}
-
-func (t *Topic) logBlockIsEmpty() bool {
-	return len(t.LogBlockList) == 0
-}
-
-func (t *Topic) getLogBlock(index int) common.LogBlock {
-	return t.LogBlockList[index]
-}
-
-func (t *Topic) logSize() int {
-	return len(t.LogBlockList)
+func (t *Topic) addLogBlock(block common.LogBlock) {
+	t.updateBlocks(func(logBlocks []common.LogBlock, indexBlocks []common.IndexBlock) ([]common.LogBlock, []common.IndexBlock) {
+		return append(logBlocks, block), indexBlocks
+	})
 }
 
-func (t *Topic) indexSize() int {
-	return len(t.IndexBlockList)
+func (t *Topic) addIndexBlock(logBlock common.LogBlock) {
+	t.updateBlocks(func(logBlocks []common.LogBlock, indexBlocks []common.IndexBlock) ([]common.LogBlock, []common.IndexBlock) {
+		return logBlocks, append(indexBlocks, common.IndexBlock(logBlock))
+	})
 }
 
 func (t *Topic) findBlocksToIndex() ([]common.LogBlock, error) {
-	if t.logSize() == 0 {
+	blocks := t.snapshot()
+	if blocks.isEmpty() {
 		return nil, common.NoBlocksFound
 	}
-	logStartPos := t.indexSize() - 1
-	if logStartPos < 0 {
-		logStartPos = 0
+	indexHead, hasIndex := blocks.indexBlockHead()
+	if !hasIndex {
+		return blocks.logBlocks, nil
 	}
-	return t.LogBlockList[logStartPos:], nil
-}
-
-func (t *Topic) indexBlockContaining(offset common.Offset) (common.IndexBlock, bool) {
-	if t.indexSize() == 0 {
-		return 0, false
-	}
-	for i := t.indexSize() - 1; i >= 0; i-- {
-		if offset >= common.Offset(t.IndexBlockList[i]) {
-			return t.IndexBlockList[i], true
-		}
-	}
-	return 0, false
-}
-
-func (t *Topic) logBlockContaining(offset common.Offset) (common.LogBlock, bool) {
-	if t.logSize() == 0 {
-		return 0, false
-	}
-	if t.NextOffset <= offset {
-		return 0, false
-	}
-	if t.logSize() == 1 {
-		return t.LogBlockList[0], true
-	}
-	for i := t.logSize() - 1; i >= 0; i-- {
-		if offset >= common.Offset(t.LogBlockList[i]) {
-			return t.LogBlockList[i], true
-		}
-	}
-	return 0, false
-}
-
-func (t *Topic) findBlockArrayIndex(block common.LogBlock) (bool, int) {
-	for i, b := range t.LogBlockList {
-		if b == block {
-			return true, i
+	// continue indexing from the last indexed block, blocks may have been removed by retention
+	for i, block := range blocks.logBlocks {
+		if common.IndexBlock(block) >= indexHead {
+			return blocks.logBlocks[i:], nil
 		}
 	}
-	return false, 0
+	return blocks.logBlocks, nil
 }
 
 func (t *Topic) debugLogIndexLookup(from common.Offset, byteOffset int64, scanCount int) {
@@ -1,6 +1,7 @@
 package access
 
 import (
+	"errors"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/tcw/ibsen/access/common"
@@ -8,6 +9,7 @@ import (
 	"strconv"
 	"sync"
 	"testing"
+	"time"
 )
 
 func init() {
@@ -23,11 +25,15 @@ func TestTopic_Write(t *testing.T) {
 		TopicName:    "topic1",
 		MaxBlockSize: 1024 * 1024,
 	})
-	err := topic.Write(createInputEntries(10))
+	firstOffset, err := topic.Write(createInputEntries(10), nil)
 	assert.Nil(t, err)
+	assert.Equal(t, common.Offset(0), firstOffset)
+	firstOffset, err = topic.Write(createInputEntries(10), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, common.Offset(10), firstOffset)
 	lastOffset, _, err := ibsLog.BlockInfo(afs, "tmp/topic1/00000000000000000000.log")
 	assert.Nil(t, err)
-	assert.Equal(t, common.Offset(9), lastOffset)
+	assert.Equal(t, common.Offset(19), lastOffset)
 }
 
 func TestTopic_Load(t *testing.T) {
@@ -39,12 +45,122 @@ func TestTopic_Load(t *testing.T) {
 		TopicName:    "topic1",
 		MaxBlockSize: 2000,
 	})
-	err := topic.Write(createInputEntries(10))
+	_, err := topic.Write(createInputEntries(10), nil)
 	assert.Nil(t, err)
 	err = topic.LoadOrCreate()
 	assert.Nil(t, err)
 	assert.Equal(t, common.Offset(10), topic.NextOffset)
-	assert.Len(t, topic.LogBlockList, 1)
+	assert.Len(t, topic.LogBlocks(), 1)
+}
+
+func TestTopic_Load_truncates_partial_write(t *testing.T) {
+	afs := common.MemAfs()
+	params := common.TopicParams{
+		Afs:          afs,
+		RootPath:     "tmp",
+		TopicName:    "topic1",
+		MaxBlockSize: 1024 * 1024,
+	}
+	topic := NewLogTopic(params)
+	_, err := topic.Write(createInputEntries(100), nil)
+	assert.Nil(t, err)
+	_, err = topic.UpdateIndex()
+	assert.Nil(t, err)
+	validSize := topic.HeadBlockSize
+	blockFileName := "tmp/topic1/00000000000000000000.log"
+	file, err := common.OpenFileForWrite(afs, blockFileName)
+	assert.Nil(t, err)
+	_, err = file.Write(common.CreateByteEntry([]byte("torn write"), 100)[:15])
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+
+	readOnlyParams := params
+	readOnlyParams.ReadOnly = true
+	readOnly := NewLogTopic(readOnlyParams)
+	err = readOnly.LoadOrCreate()
+	assert.Nil(t, err)
+	assert.Equal(t, common.Offset(100), readOnly.HighWatermark())
+	size, err := afs.Stat(blockFileName)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(validSize+15), size.Size())
+
+	recovered := NewLogTopic(params)
+	err = recovered.LoadOrCreate()
+	assert.Nil(t, err)
+	assert.Equal(t, common.Offset(100), recovered.NextOffset)
+	assert.Equal(t, validSize, recovered.HeadBlockSize)
+	size, err = afs.Stat(blockFileName)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(validSize), size.Size())
+
+	_, err = recovered.Write(createInputEntries(1), nil)
+	assert.Nil(t, err)
+	lastOffset, _, err := ibsLog.BlockInfo(afs, blockFileName)
+	assert.Nil(t, err)
+	assert.Equal(t, common.Offset(100), lastOffset)
+}
+
+func TestTopic_Load_keeps_head_block_with_corrupt_size(t *testing.T) {
+	afs := common.MemAfs()
+	params := common.TopicParams{
+		Afs:          afs,
+		RootPath:     "tmp",
+		TopicName:    "topic1",
+		MaxBlockSize: 1024 * 1024,
+	}
+	topic := NewLogTopic(params)
+	_, err := topic.Write(createInputEntries(100), nil)
+	assert.Nil(t, err)
+	assert.Nil(t, topic.Close())
+	blockFileName := "tmp/topic1/00000000000000000000.log"
+	byteOffset, _, err := ibsLog.FindByteOffsetFromAndIncludingOffset(afs, blockFileName, 0, 50, common.FailOnCorruption)
+	assert.Nil(t, err)
+	logBytes, err := afs.ReadFile(blockFileName)
+	assert.Nil(t, err)
+	// an unknown entry format in the most significant byte of the size field
+	logBytes[byteOffset+11] = 0xEE
+	assert.Nil(t, afs.WriteFile(blockFileName, logBytes, 0600))
+
+	failing := NewLogTopic(params)
+	err = failing.LoadOrCreate()
+	var corruption *common.CorruptEntryError
+	assert.True(t, errors.As(err, &corruption))
+	assert.Equal(t, common.Offset(50), corruption.Offset)
+
+	params.CorruptionPolicy = common.StopAtCorruption
+	recovered := NewLogTopic(params)
+	err = recovered.LoadOrCreate()
+	assert.Nil(t, err)
+	assert.Equal(t, common.Offset(100), recovered.NextOffset)
+	size, err := afs.Stat(blockFileName)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(logBytes)), size.Size())
+
+	firstOffset, err := recovered.Write(createInputEntries(1), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, common.Offset(100), firstOffset)
+	assert.Equal(t, []common.LogBlock{0, 100}, recovered.LogBlocks())
+	size, err = afs.Stat(blockFileName)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(logBytes)), size.Size())
+}
+
+func TestTopic_Load_empty_head_block(t *testing.T) {
+	afs := common.MemAfs()
+	err := afs.MkdirAll("tmp/topic1", 0744)
+	assert.Nil(t, err)
+	err = afs.WriteFile("tmp/topic1/00000000000000000000.log", []byte{}, 0600)
+	assert.Nil(t, err)
+	topic := NewLogTopic(common.TopicParams{
+		Afs:          afs,
+		RootPath:     "tmp",
+		TopicName:    "topic1",
+		MaxBlockSize: 1024 * 1024,
+	})
+	err = topic.LoadOrCreate()
+	assert.Nil(t, err)
+	assert.Equal(t, common.Offset(0), topic.NextOffset)
+	assert.Equal(t, 0, topic.HeadBlockSize)
 }
 
 func TestTopic_Read_one_batch(t *testing.T) {
@@ -55,14 +171,14 @@ func TestTopic_Read_one_batch(t *testing.T) {
 		TopicName:    "topic1",
 		MaxBlockSize: 2000,
 	})
-	err := topic.Write(createInputEntries(10))
+	_, err := topic.Write(createInputEntries(10), nil)
 	assert.Nil(t, err)
 	err = topic.LoadOrCreate()
 	assert.Nil(t, err)
-	logChan := make(chan *[]common.LogEntry)
+	logChan := make(chan *common.EntryBatch)
 	var wg sync.WaitGroup
 	go func() {
-		err := topic.Read(common.ReadLogParams{
+		_, err := topic.Read(common.ReadLogParams{
 			LogChan:   logChan,
 			Wg:        &wg,
 			From:      0,
@@ -73,7 +189,7 @@ func TestTopic_Read_one_batch(t *testing.T) {
 	}()
 	wg.Wait()
 	logEntry := <-logChan
-	for i, l := range *logEntry {
+	for i, l := range logEntry.Entries {
 		assert.Equal(t, uint64(i), l.Offset)
 		assert.Equal(t, "dummy"+strconv.Itoa(i), string(l.Entry))
 	}
@@ -87,18 +203,18 @@ func TestTopic_Read_multiple_batches(t *testing.T) {
 		TopicName:    "topic1",
 		MaxBlockSize: 2000,
 	})
-	err := topic.Write(createInputEntries(1000))
+	_, err := topic.Write(createInputEntries(1000), nil)
 	assert.Nil(t, err)
-	err = topic.Write(createInputEntries(1000))
+	_, err = topic.Write(createInputEntries(1000), nil)
 	assert.Nil(t, err)
-	err = topic.Write(createInputEntries(1000))
+	_, err = topic.Write(createInputEntries(1000), nil)
 	assert.Nil(t, err)
 	err = topic.LoadOrCreate()
 	assert.Nil(t, err)
-	logChan := make(chan *[]common.LogEntry)
+	logChan := make(chan *common.EntryBatch)
 	var wg sync.WaitGroup
 	go func() {
-		err := topic.Read(common.ReadLogParams{
+		_, err := topic.Read(common.ReadLogParams{
 			LogChan:   logChan,
 			Wg:        &wg,
 			From:      0,
@@ -109,7 +225,7 @@ func TestTopic_Read_multiple_batches(t *testing.T) {
 	}()
 	wg.Wait()
 	logEntry := <-logChan
-	for i, l := range *logEntry {
+	for i, l := range logEntry.Entries {
 		assert.Equal(t, uint64(i), l.Offset)
 		assert.Equal(t, "dummy"+strconv.Itoa(i), string(l.Entry))
 	}
@@ -123,18 +239,17 @@ func TestTopic_UpdateIndex_sigle_block(t *testing.T) {
 		TopicName:    "topic1",
 		MaxBlockSize: 20000,
 	})
-	err := topic.Write(createInputEntries(100))
+	_, err := topic.Write(createInputEntries(100), nil)
 	assert.Nil(t, err)
 	err = topic.LoadOrCreate()
 	assert.Nil(t, err)
-	err = topic.Write(createInputEntries(100))
+	_, err = topic.Write(createInputEntries(100), nil)
 	assert.Nil(t, err)
-	topic.indexWg.Wait()
 	updatedIndex, err := topic.UpdateIndex()
 	assert.Nil(t, err)
 	assert.True(t, updatedIndex)
-	head, hasHead := topic.indexBlockHead()
-	topic.indexBlockHead()
+	head, hasHead := topic.snapshot().indexBlockHead()
+	topic.snapshot().indexBlockHead()
 	assert.True(t, hasHead)
 	index, err := topic.getIndexFromIndexBlock(head)
 	assert.Nil(t, err)
@@ -149,26 +264,123 @@ func TestTopic_UpdateIndex_multiple_blocks(t *testing.T) {
 		TopicName:    "topic1",
 		MaxBlockSize: 2000,
 	})
-	err := topic.Write(createInputEntries(1000))
+	_, err := topic.Write(createInputEntries(1000), nil)
 	assert.Nil(t, err)
 	err = topic.LoadOrCreate()
 	assert.Nil(t, err)
-	err = topic.Write(createInputEntries(1000))
+	_, err = topic.Write(createInputEntries(1000), nil)
 	assert.Nil(t, err)
-	err = topic.Write(createInputEntries(1000))
+	_, err = topic.Write(createInputEntries(1000), nil)
 	assert.Nil(t, err)
-	topic.indexWg.Wait()
 	updatedIndex, err := topic.UpdateIndex()
 	assert.Nil(t, err)
 	assert.True(t, updatedIndex)
-	head, hasHead := topic.indexBlockHead()
-	topic.indexBlockHead()
+	head, hasHead := topic.snapshot().indexBlockHead()
+	topic.snapshot().indexBlockHead()
 	assert.True(t, hasHead)
 	index, err := topic.getIndexFromIndexBlock(head)
 	assert.Nil(t, err)
 	assert.Equal(t, common.Offset(2990), index.Head().Offset)
 }
 
+func TestTopic_UpdateIndex_updates_index_cache(t *testing.T) {
+	afs := common.MemAfs()
+	topic := NewLogTopic(common.TopicParams{
+		Afs:            afs,
+		RootPath:       "tmp",
+		TopicName:      "topic1",
+		MaxBlockSize:   20000,
+		IndexCacheSize: 1024,
+	})
+	_, err := topic.Write(createInputEntries(100), nil)
+	assert.Nil(t, err)
+	_, err = topic.UpdateIndex()
+	assert.Nil(t, err)
+	cached, err := topic.getIndexFromIndexBlock(0)
+	assert.Nil(t, err)
+	assert.Equal(t, common.Offset(90), cached.Head().Offset)
+
+	_, err = topic.Write(createInputEntries(100), nil)
+	assert.Nil(t, err)
+	_, err = topic.UpdateIndex()
+	assert.Nil(t, err)
+	// reads are served from the cache and do not read the index block file
+	err = afs.Remove("tmp/topic1/00000000000000000000.idx")
+	assert.Nil(t, err)
+	updated, err := topic.getIndexFromIndexBlock(0)
+	assert.Nil(t, err)
+	assert.Equal(t, common.Offset(190), updated.Head().Offset)
+	assert.Equal(t, common.Offset(90), cached.Head().Offset)
+	entries := readAllFrom(t, topic, 155)
+	assert.Equal(t, 45, len(entries))
+	assert.Equal(t, uint64(155), entries[0].Offset)
+}
+
+func TestTopic_WriteWithMetadata(t *testing.T) {
+	afs := common.MemAfs()
+	topic := NewLogTopic(common.TopicParams{
+		Afs:          afs,
+		RootPath:     "tmp",
+		TopicName:    "topic1",
+		MaxBlockSize: 10000,
+	})
+	metadata := []common.EntryMetadata{
+		{Key: []byte("a")},
+		{},
+		{Key: []byte("b"), Headers: map[string]string{"h1": "v1"}},
+	}
+	_, err := topic.Write(createInputEntries(3), metadata)
+	assert.Nil(t, err)
+	_, err = topic.Write(createInputEntries(2), metadata)
+	assert.NotNil(t, err)
+
+	logChan := make(chan *common.EntryBatch)
+	var wg sync.WaitGroup
+	go func() {
+		_, err := topic.Read(common.ReadLogParams{
+			LogChan:   logChan,
+			Wg:        &wg,
+			From:      0,
+			BatchSize: 10,
+		})
+		assert.Nil(t, err)
+	}()
+	batch := (<-logChan).Entries
+	wg.Done()
+	assert.Equal(t, 3, len(batch))
+	assert.Equal(t, "a", string(batch[0].Key))
+	assert.Nil(t, batch[1].Key)
+	assert.Equal(t, "b", string(batch[2].Key))
+	assert.Equal(t, map[string]string{"h1": "v1"}, batch[2].Headers)
+}
+
+func TestTopic_FindOffsetForTimestamp(t *testing.T) {
+	afs := common.MemAfs()
+	topic := NewLogTopic(common.TopicParams{
+		Afs:          afs,
+		RootPath:     "tmp",
+		TopicName:    "topic1",
+		MaxBlockSize: 2000,
+	})
+	var batchStarts []int64
+	for i := 0; i < 5; i++ {
+		time.Sleep(time.Millisecond)
+		batchStarts = append(batchStarts, time.Now().UnixNano())
+		_, err := topic.Write(createInputEntries(100), nil)
+		assert.Nil(t, err)
+	}
+	_, err := topic.UpdateIndex()
+	assert.Nil(t, err)
+	for i, start := range batchStarts {
+		offset, err := topic.FindOffsetForTimestamp(start)
+		assert.Nil(t, err)
+		assert.Equal(t, common.Offset(i*100), offset)
+	}
+	offset, err := topic.FindOffsetForTimestamp(time.Now().UnixNano())
+	assert.Nil(t, err)
+	assert.Equal(t, common.Offset(500), offset)
+}
+
 func createInputEntries(numberOfEntries int) *[][]byte {
 	var tmpBytes = make([][]byte, 0)
 	for i := 0; i < numberOfEntries; i++ {
@@ -176,3 +388,170 @@ func createInputEntries(numberOfEntries int) *[][]byte {
 	}
 	return &tmpBytes
 }
+
+func TestTopic_concurrent_reads_and_writes(t *testing.T) {
+	topic := NewLogTopic(common.TopicParams{
+		Afs:            common.MemAfs(),
+		RootPath:       "tmp",
+		TopicName:      "topic1",
+		MaxBlockSize:   500,
+		IndexCacheSize: 1024 * 1024,
+	})
+	err := topic.LoadOrCreate()
+	assert.Nil(t, err)
+	const batches = 200
+	const batchSize = 10
+	var readers sync.WaitGroup
+	for r := 0; r < 8; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			var next common.Offset = 0
+			for next < batches*batchSize {
+				entries, err := readAvailableFrom(topic, next)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				for _, entry := range entries {
+					if common.Offset(entry.Offset) != next {
+						t.Errorf("expected offset %d, got %d", next, entry.Offset)
+						return
+					}
+					next = next + 1
+				}
+			}
+		}()
+	}
+	for i := 0; i < batches; i++ {
+		_, err := topic.Write(createInputEntries(batchSize), nil)
+		assert.Nil(t, err)
+		// indexing runs next to the readers, as it does in the index workers
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			_, err := topic.IndexNewEntries(nil)
+			assert.Nil(t, err)
+		}()
+	}
+	readers.Wait()
+	assert.Equal(t, common.Offset(batches*batchSize), topic.HighWatermark())
+}
+
+// readAvailableFrom reads the entries written so far, from and including offset
+func readAvailableFrom(topic *Topic, from common.Offset) ([]common.LogEntry, error) {
+	logChan := make(chan *common.EntryBatch)
+	done := make(chan error)
+	var wg sync.WaitGroup
+	go func() {
+		_, err := topic.Read(common.ReadLogParams{
+			LogChan:   logChan,
+			Wg:        &wg,
+			From:      from,
+			BatchSize: 100,
+		})
+		done <- err
+	}()
+	var entries []common.LogEntry
+	for {
+		select {
+		case batch := <-logChan:
+			entries = append(entries, batch.Entries...)
+			wg.Done()
+		case err := <-done:
+			if err == common.NoEntriesFound {
+				return nil, nil
+			}
+			return entries, err
+		}
+	}
+}
+
+func TestTopic_Read_bounded_across_blocks(t *testing.T) {
+	topic := NewLogTopic(common.TopicParams{
+		Afs:          common.MemAfs(),
+		RootPath:     "tmp",
+		TopicName:    "topic1",
+		MaxBlockSize: 2000,
+	})
+	err := topic.LoadOrCreate()
+	assert.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = topic.Write(createInputEntries(1000), nil)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 3, len(topic.LogBlocks()))
+
+	entries := readBounded(t, topic, common.ReadLogParams{From: 500, EndOffset: 2500})
+	assert.Equal(t, 2000, len(entries))
+	assert.Equal(t, uint64(500), entries[0].Offset)
+	assert.Equal(t, uint64(2499), entries[len(entries)-1].Offset)
+
+	entries = readBounded(t, topic, common.ReadLogParams{From: 0, MaxEntries: 1200})
+	assert.Equal(t, 1200, len(entries))
+	assert.Equal(t, uint64(1199), entries[len(entries)-1].Offset)
+
+	// dummy0 to dummy9 are 6 bytes each, the entry reaching max bytes is the last one read
+	entries = readBounded(t, topic, common.ReadLogParams{From: 0, MaxBytes: 57})
+	assert.Equal(t, 10, len(entries))
+}
+
+func readBounded(t *testing.T, topic *Topic, params common.ReadLogParams) []common.LogEntry {
+	logChan := make(chan *common.EntryBatch)
+	done := make(chan error)
+	var wg sync.WaitGroup
+	params.LogChan = logChan
+	params.Wg = &wg
+	params.BatchSize = 100
+	go func() {
+		_, err := topic.Read(params)
+		done <- err
+	}()
+	var entries []common.LogEntry
+	for {
+		select {
+		case batch := <-logChan:
+			entries = append(entries, batch.Entries...)
+			wg.Done()
+		case err := <-done:
+			assert.Nil(t, err)
+			return entries
+		}
+	}
+}
+
+func TestTopic_Read_returns_offset_scanned_to(t *testing.T) {
+	topic := NewLogTopic(common.TopicParams{
+		Afs:          common.MemAfs(),
+		RootPath:     "tmp",
+		TopicName:    "topic1",
+		MaxBlockSize: 2000,
+	})
+	err := topic.LoadOrCreate()
+	assert.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = topic.Write(createInputEntries(1000), nil)
+		assert.Nil(t, err)
+	}
+	logChan := make(chan *common.EntryBatch, 100)
+	var wg sync.WaitGroup
+	scannedTo, err := topic.Read(common.ReadLogParams{
+		LogChan:    logChan,
+		Wg:         &wg,
+		From:       500,
+		BatchSize:  100,
+		MaxEntries: 1200,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, common.Offset(1700), scannedTo)
+
+	scannedTo, err = topic.Read(common.ReadLogParams{
+		LogChan:   logChan,
+		Wg:        &wg,
+		From:      500,
+		BatchSize: 100,
+		Filter:    func(payload []byte) bool { return false },
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, topic.HighWatermark(), scannedTo)
+}
@@ -0,0 +1,254 @@
+package access
+
+import (
+	"bufio"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
+	"github.com/tcw/ibsen/access/common"
+	"github.com/tcw/ibsen/access/index"
+	ibsLog "github.com/tcw/ibsen/access/log"
+	"github.com/tcw/ibsen/errore"
+	"os"
+	"time"
+)
+
+const compactingSuffix = ".compacting"
+
+// Compact rewrites sealed log blocks so only the latest entry for each key is kept, and removes
+// tombstones older than the grace period. Offsets are kept, so compacted blocks have gaps in offsets.
+// A rewritten block and its index blocks replace the old files while no reader is looking up a
+// position in the block, readers with the old block open continue reading the old block.
+// Compaction runs concurrently with Write, it only reads entries below the high-watermark and only
+// rewrites sealed blocks.
+func (t *Topic) Compact(now time.Time) ([]common.LogBlock, error) {
+	// entries written while compacting are not scanned, they only make more entries obsolete
+	endOffset := t.HighWatermark()
+	blocks := t.snapshot()
+	if !t.Compaction.Enabled || blocks.logSize() < 2 {
+		return nil, nil
+	}
+	// compaction and indexing both write index blocks, try again on next run if indexing
+	if !t.indexLock.TryLock() {
+		return nil, nil
+	}
+	defer t.indexLock.Unlock()
+	if t.IndexPosition == nil {
+		return nil, nil
+	}
+
+	// only sealed blocks the indexer is done with are compacted, so the index position stays valid
+	var compactable []common.LogBlock
+	for _, block := range blocks.logBlocks[:blocks.logSize()-1] {
+		if block >= t.IndexPosition.Block || common.Offset(block) >= endOffset {
+			break
+		}
+		compactable = append(compactable, block)
+	}
+	if len(compactable) == 0 {
+		return nil, nil
+	}
+	latest, err := t.latestOffsetForKeys(blocks, endOffset)
+	if err != nil {
+		return nil, errore.Wrap(err)
+	}
+	var compacted []common.LogBlock
+	removedEntries := 0
+	for _, block := range compactable {
+		removed, err := t.compactBlock(block, latest, endOffset, now)
+		if err != nil {
+			return compacted, errore.Wrap(err)
+		}
+		if removed > 0 {
+			compacted = append(compacted, block)
+			removedEntries = removedEntries + removed
+		}
+	}
+	if len(compacted) > 0 {
+		log.Info().Str("topic", t.TopicName).
+			Int("compactedBlocks", len(compacted)).
+			Int("removedEntries", removedEntries).
+			Msg("compacted log blocks")
+	}
+	return compacted, nil
+}
+
+func (t *Topic) latestOffsetForKeys(blocks *blockSnapshot, endOffset common.Offset) (map[string]common.Offset, error) {
+	latest := make(map[string]common.Offset)
+	for _, block := range blocks.logBlocks {
+		if common.Offset(block) >= endOffset {
+			break
+		}
+		fileName, err := t.readableLogBlockFileName(block)
+		if err != nil {
+			return nil, errore.Wrap(err)
+		}
+		err = ibsLog.ScanBlock(t.Afs, fileName, endOffset, func(entry common.LogEntry, entryBytes []byte) {
+			if len(entry.Key) > 0 {
+				latest[string(entry.Key)] = common.Offset(entry.Offset)
+			}
+		})
+		if err != nil {
+			return nil, t.annotateCorruption(err, block)
+		}
+	}
+	return latest, nil
+}
+
+func (t *Topic) isCompactedAway(entry common.LogEntry, latest map[string]common.Offset, now time.Time) bool {
+	if len(entry.Key) == 0 {
+		return false
+	}
+	if latest[string(entry.Key)] != common.Offset(entry.Offset) {
+		return true
+	}
+	isTombstone := len(entry.Entry) == 0
+	return isTombstone && now.Sub(time.Unix(0, entry.Timestamp)) > t.Compaction.TombstoneGracePeriod
+}
+
+// compactBlock writes the remaining entries and their index blocks to temporary files, and then
+// renames them over the block files. Returns the number of entries removed.
+func (t *Topic) compactBlock(block common.LogBlock, latest map[string]common.Offset, endOffset common.Offset, now time.Time) (int, error) {
+	logFileName, err := t.logBlockFileName(block)
+	if err != nil {
+		return 0, errore.Wrap(err)
+	}
+	readableFileName, err := t.readableLogBlockFileName(block)
+	if err != nil {
+		return 0, errore.Wrap(err)
+	}
+	removed, keptSize, err := t.writeKeptEntries(block, readableFileName, logFileName+compactingSuffix, latest, endOffset, now)
+	if err != nil {
+		return 0, err
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	indexFileName, err := t.indexBlockFileName(common.IndexBlock(block))
+	if err != nil {
+		return 0, errore.Wrap(err)
+	}
+	timeIndexFileName, err := t.timeIndexBlockFileName(common.IndexBlock(block))
+	if err != nil {
+		return 0, errore.Wrap(err)
+	}
+	// indices are created from the uncompressed entries, a compressed block is indexed by frames
+	indices, _, err := index.CreateBinaryIndicesFromLogFile(t.Afs, logFileName+compactingSuffix, 0, 10, nil)
+	if err != nil {
+		return 0, errore.Wrap(err)
+	}
+	if common.IsCompressedBlock(readableFileName) {
+		compression, err := common.BlockCompression(t.Afs, readableFileName)
+		if err != nil {
+			return 0, errore.Wrap(err)
+		}
+		kept, err := common.OpenFileForRead(t.Afs, logFileName+compactingSuffix)
+		if err != nil {
+			return 0, errore.Wrap(err)
+		}
+		_, indices.Offsets, err = writeCompressedBlock(t.Afs, readableFileName+compactingSuffix, kept, keptSize, compression)
+		closeFile(kept)
+		if err != nil {
+			return 0, errore.Wrap(err)
+		}
+		err = t.Afs.Remove(logFileName + compactingSuffix)
+		if err != nil {
+			return 0, errore.Wrap(err)
+		}
+	}
+	err = writeSyncedFile(t.Afs, indexFileName+compactingSuffix, indices.Offsets)
+	if err != nil {
+		return 0, errore.Wrap(err)
+	}
+	err = writeSyncedFile(t.Afs, timeIndexFileName+compactingSuffix, indices.Timestamps)
+	if err != nil {
+		return 0, errore.Wrap(err)
+	}
+
+	t.blockSwapLock.Lock()
+	err = commitTemporaryFiles(t.Afs, compactingSuffix, readableFileName, indexFileName, timeIndexFileName)
+	t.indexCache.Remove(common.IndexBlock(block))
+	t.blockSwapLock.Unlock()
+	if err != nil {
+		return 0, errore.Wrap(err)
+	}
+	err = common.SyncDirectory(t.Afs, t.RootPath+common.Sep+t.TopicName)
+	if err != nil {
+		return 0, errore.Wrap(err)
+	}
+	return removed, nil
+}
+
+// writeKeptEntries streams the entries of the block that are not compacted away to fileName, through a
+// buffered writer, so a block is never held in memory. Returns the number of entries removed and the
+// bytes kept, the file is removed if no entries are removed.
+func (t *Topic) writeKeptEntries(block common.LogBlock, readableFileName string, fileName string,
+	latest map[string]common.Offset, endOffset common.Offset, now time.Time) (int, int64, error) {
+
+	file, err := t.Afs.OpenFile(fileName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, 0, errore.Wrap(err)
+	}
+	writer := bufio.NewWriter(file)
+	removed := 0
+	var keptSize int64 = 0
+	var writeErr error
+	err = ibsLog.ScanBlock(t.Afs, readableFileName, endOffset, func(entry common.LogEntry, entryBytes []byte) {
+		if t.isCompactedAway(entry, latest, now) {
+			removed = removed + 1
+			return
+		}
+		if writeErr != nil {
+			return
+		}
+		_, writeErr = writer.Write(entryBytes)
+		keptSize = keptSize + int64(len(entryBytes))
+	})
+	if err != nil {
+		err = t.annotateCorruption(err, block)
+	} else {
+		err = writeErr
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil && removed > 0 {
+		err = file.Sync()
+	}
+	if err != nil {
+		ioErr := file.Close()
+		if ioErr != nil {
+			return 0, 0, errore.WrapError(ioErr, err)
+		}
+		return 0, 0, err
+	}
+	err = file.Close()
+	if err != nil {
+		return 0, 0, errore.Wrap(err)
+	}
+	if removed == 0 {
+		err = t.Afs.Remove(fileName)
+		if err != nil {
+			return 0, 0, errore.Wrap(err)
+		}
+	}
+	return removed, keptSize, nil
+}
+
+func writeSyncedFile(afs *afero.Afero, fileName string, bytes []byte) error {
+	file, err := afs.OpenFile(fileName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	_, err = file.Write(bytes)
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		ioErr := file.Close()
+		if ioErr != nil {
+			return errore.WrapError(ioErr, err)
+		}
+		return errore.Wrap(err)
+	}
+	return file.Close()
+}
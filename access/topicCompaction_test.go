@@ -0,0 +1,229 @@
+package access
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/tcw/ibsen/access/common"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTopic_Compact(t *testing.T) {
+	topic := createCompactedTopic(t)
+	now := time.Now()
+
+	compacted, err := topic.Compact(now)
+	assert.Nil(t, err)
+	assert.Equal(t, []common.LogBlock{0, 200}, compacted)
+
+	entries := readAllFrom(t, topic, 0)
+	assert.Equal(t, 210, len(entries))
+	assert.Equal(t, uint64(100), entries[0].Offset)
+	assert.Equal(t, uint64(199), entries[99].Offset)
+	assert.Equal(t, uint64(290), entries[100].Offset)
+	assert.Equal(t, "key0", string(entries[100].Key))
+	assert.Equal(t, uint64(299), entries[109].Offset)
+	assert.Equal(t, 0, len(entries[109].Entry))
+	assert.Equal(t, uint64(300), entries[110].Offset)
+
+	fromGap := readAllFrom(t, topic, 250)
+	assert.Equal(t, 110, len(fromGap))
+	assert.Equal(t, uint64(290), fromGap[0].Offset)
+	fromEmptyBlock := readAllFrom(t, topic, 50)
+	assert.Equal(t, uint64(100), fromEmptyBlock[0].Offset)
+
+	// nothing left to compact until the tombstone is past its grace period
+	compacted, err = topic.Compact(now)
+	assert.Nil(t, err)
+	assert.Empty(t, compacted)
+	compacted, err = topic.Compact(now.Add(2 * time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, []common.LogBlock{200}, compacted)
+	entries = readAllFrom(t, topic, 200)
+	assert.Equal(t, 109, len(entries))
+	assert.Equal(t, uint64(298), entries[8].Offset)
+	assert.Equal(t, uint64(300), entries[9].Offset)
+}
+
+func TestTopic_Compact_compressed_blocks(t *testing.T) {
+	topic := createCompactedTopic(t)
+	topic.Compression = common.CompressionGzip
+	_, err := topic.UpdateIndex()
+	assert.Nil(t, err)
+	exists, err := topic.Afs.Exists("tmp/topic1/00000000000000000200.logz")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+
+	compacted, err := topic.Compact(time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, []common.LogBlock{0, 200}, compacted)
+	for _, fileName := range []string{"tmp/topic1/00000000000000000200.log", "tmp/topic1/00000000000000000200.log" + compactingSuffix} {
+		exists, err = topic.Afs.Exists(fileName)
+		assert.Nil(t, err)
+		assert.False(t, exists)
+	}
+	entries := readAllFrom(t, topic, 0)
+	assert.Equal(t, 210, len(entries))
+	assert.Equal(t, uint64(290), entries[100].Offset)
+	fromGap := readAllFrom(t, topic, 250)
+	assert.Equal(t, uint64(290), fromGap[0].Offset)
+}
+
+func TestTopic_Compact_concurrent_with_writes(t *testing.T) {
+	topic := createCompactedTopic(t)
+	written := make(chan error)
+	go func() {
+		for i := 0; i < 20; i++ {
+			entries, metadata := createKeyedEntries(10)
+			_, err := topic.Write(entries, metadata)
+			if err != nil {
+				written <- err
+				return
+			}
+		}
+		written <- nil
+	}()
+	compacted, err := topic.Compact(time.Now())
+	assert.Nil(t, err)
+	assert.Contains(t, compacted, common.LogBlock(0))
+	assert.Nil(t, <-written)
+
+	assert.Equal(t, common.Offset(600), topic.HighWatermark())
+	entries := readAllFrom(t, topic, 300)
+	assert.Equal(t, 300, len(entries))
+	assert.Equal(t, uint64(599), entries[299].Offset)
+}
+
+func TestTopic_Compact_recovers_interrupted_compaction(t *testing.T) {
+	topic := createCompactedTopic(t)
+	_, err := topic.Compact(time.Now())
+	assert.Nil(t, err)
+	indexFileName, err := topic.indexBlockFileName(200)
+	assert.Nil(t, err)
+	logFileName, err := topic.logBlockFileName(200)
+	assert.Nil(t, err)
+	compactedIndex, err := topic.Afs.ReadFile(indexFileName)
+	assert.Nil(t, err)
+	garbageIndex := common.Uint64ArrayToBytes([]uint64{210, 9000})
+
+	// stopped after the log block was replaced, but before the index block was
+	err = topic.Afs.WriteFile(indexFileName, garbageIndex, 0600)
+	assert.Nil(t, err)
+	err = topic.Afs.WriteFile(indexFileName+compactingSuffix, compactedIndex, 0600)
+	assert.Nil(t, err)
+	reloaded := reloadTopic(t, topic)
+	entries := readAllFrom(t, reloaded, 295)
+	assert.Equal(t, uint64(295), entries[0].Offset)
+	assert.Equal(t, 105, len(entries))
+
+	// stopped before the log block was replaced
+	err = topic.Afs.WriteFile(logFileName+compactingSuffix, []byte("partial"), 0600)
+	assert.Nil(t, err)
+	err = topic.Afs.WriteFile(indexFileName+compactingSuffix, garbageIndex, 0600)
+	assert.Nil(t, err)
+	// a read only instance leaves the files to the writing instance
+	readOnly := NewLogTopic(common.TopicParams{
+		ReadOnly:     true,
+		Afs:          topic.Afs,
+		RootPath:     topic.RootPath,
+		TopicName:    topic.TopicName,
+		MaxBlockSize: topic.MaxBlockSize,
+	})
+	err = readOnly.LoadOrCreate()
+	assert.Nil(t, err)
+	for _, fileName := range []string{logFileName + compactingSuffix, indexFileName + compactingSuffix} {
+		exists, err := topic.Afs.Exists(fileName)
+		assert.Nil(t, err)
+		assert.True(t, exists)
+	}
+	reloaded = reloadTopic(t, topic)
+	for _, fileName := range []string{logFileName + compactingSuffix, indexFileName + compactingSuffix} {
+		exists, err := topic.Afs.Exists(fileName)
+		assert.Nil(t, err)
+		assert.False(t, exists)
+	}
+	entries = readAllFrom(t, reloaded, 295)
+	assert.Equal(t, 105, len(entries))
+}
+
+func reloadTopic(t *testing.T, topic *Topic) *Topic {
+	reloaded := NewLogTopic(common.TopicParams{
+		Afs:            topic.Afs,
+		RootPath:       topic.RootPath,
+		TopicName:      topic.TopicName,
+		MaxBlockSize:   topic.MaxBlockSize,
+		Compression:    topic.Compression,
+		IndexCacheSize: 1024 * 1024,
+	})
+	err := reloaded.LoadOrCreate()
+	assert.Nil(t, err)
+	return reloaded
+}
+
+// createCompactedTopic writes one block with keys, one without keys, one with the same keys ending
+// with a tombstone for key9, and a head block without keys
+func createCompactedTopic(t *testing.T) *Topic {
+	topic := NewLogTopic(common.TopicParams{
+		Afs:            common.MemAfs(),
+		RootPath:       "tmp",
+		TopicName:      "topic1",
+		MaxBlockSize:   2000,
+		IndexCacheSize: 1024 * 1024,
+		Compaction: common.CompactionPolicy{
+			Enabled:              true,
+			TombstoneGracePeriod: time.Hour,
+		},
+	})
+	entries, metadata := createKeyedEntries(100)
+	_, err := topic.Write(entries, metadata)
+	assert.Nil(t, err)
+	_, err = topic.Write(createInputEntries(100), nil)
+	assert.Nil(t, err)
+	entries, metadata = createKeyedEntries(100)
+	(*entries)[99] = []byte{}
+	_, err = topic.Write(entries, metadata)
+	assert.Nil(t, err)
+	_, err = topic.Write(createInputEntries(100), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []common.LogBlock{0, 100, 200, 300}, topic.LogBlocks())
+	_, err = topic.UpdateIndex()
+	assert.Nil(t, err)
+	return topic
+}
+
+func createKeyedEntries(numberOfEntries int) (*[][]byte, []common.EntryMetadata) {
+	entries := make([][]byte, numberOfEntries)
+	metadata := make([]common.EntryMetadata, numberOfEntries)
+	for i := 0; i < numberOfEntries; i++ {
+		entries[i] = []byte(fmt.Sprintf("dummy%d", i))
+		metadata[i] = common.EntryMetadata{Key: []byte(fmt.Sprintf("key%d", i%10))}
+	}
+	return &entries, metadata
+}
+
+func readAllFrom(t *testing.T, topic *Topic, from common.Offset) []common.LogEntry {
+	logChan := make(chan *common.EntryBatch)
+	done := make(chan error)
+	var wg sync.WaitGroup
+	go func() {
+		_, err := topic.Read(common.ReadLogParams{
+			LogChan:   logChan,
+			Wg:        &wg,
+			From:      from,
+			BatchSize: 1000,
+		})
+		done <- err
+	}()
+	var entries []common.LogEntry
+	for {
+		select {
+		case batch := <-logChan:
+			entries = append(entries, batch.Entries...)
+			wg.Done()
+		case err := <-done:
+			assert.Nil(t, err)
+			return entries
+		}
+	}
+}
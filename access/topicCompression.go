@@ -0,0 +1,239 @@
+package access
+
+import (
+	"bufio"
+	"errors"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/afero"
+	"github.com/tcw/ibsen/access/common"
+	"github.com/tcw/ibsen/errore"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	compressingSuffix = ".compressing"
+	// frames are at least this many uncompressed bytes, except the last frame in a block
+	compressionFrameSize = 256 * 1024
+)
+
+// compressSealedBlocks compresses sealed blocks the indexer is done with, must only be
+// called while holding the index lock
+func (t *Topic) compressSealedBlocks() error {
+	if t.Compression == common.CompressionNone || t.IndexPosition == nil {
+		return nil
+	}
+	for _, block := range t.snapshot().logBlocks {
+		if block >= t.IndexPosition.Block {
+			break
+		}
+		if block < t.compressedUpTo {
+			continue
+		}
+		err := t.compressBlock(block)
+		var corruption *common.CorruptEntryError
+		if errors.As(err, &corruption) {
+			log.Warn().Str("topic", t.TopicName).
+				Uint64("logBlock", uint64(block)).
+				Err(err).
+				Msg("leaving log block with corrupt entries uncompressed")
+		} else if err != nil {
+			return errore.Wrap(err)
+		}
+		t.compressedUpTo = block + 1
+	}
+	return nil
+}
+
+// compressBlock writes the compressed block and its frame index to temporary files, and then renames
+// them over the block files before the uncompressed block is removed
+func (t *Topic) compressBlock(block common.LogBlock) error {
+	logFileName, err := t.logBlockFileName(block)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	compressedFileName, err := t.compressedLogBlockFileName(block)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	indexFileName, err := t.indexBlockFileName(common.IndexBlock(block))
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	isUncompressed, err := t.Afs.Exists(logFileName)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	isCompressed, err := t.Afs.Exists(compressedFileName)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	if !isUncompressed || isCompressed {
+		return nil
+	}
+	file, err := common.OpenFileForRead(t.Afs, logFileName)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	defer closeFile(file)
+	info, err := file.Stat()
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	compressedSize, frameIndex, err := writeCompressedBlock(t.Afs, compressedFileName+compressingSuffix, bufio.NewReader(file), info.Size(), t.Compression)
+	if err != nil {
+		return t.annotateCorruption(err, block)
+	}
+	err = writeSyncedFile(t.Afs, indexFileName+compressingSuffix, frameIndex)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+
+	t.blockSwapLock.Lock()
+	err = commitTemporaryFiles(t.Afs, compressingSuffix, compressedFileName, indexFileName)
+	t.indexCache.Remove(common.IndexBlock(block))
+	if err == nil {
+		err = t.Afs.Remove(logFileName)
+	}
+	t.blockSwapLock.Unlock()
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	err = common.SyncDirectory(t.Afs, t.RootPath+common.Sep+t.TopicName)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	log.Debug().Str("topic", t.TopicName).
+		Uint64("logBlock", uint64(block)).
+		Int64("size", info.Size()).
+		Int64("compressedSize", compressedSize).
+		Msg("compressed log block")
+	return nil
+}
+
+func writeCompressedBlock(afs *afero.Afero, fileName string, block io.Reader, blockSize int64, compression common.Compression) (int64, []byte, error) {
+	file, err := afs.OpenFile(fileName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, nil, errore.Wrap(err)
+	}
+	writer := bufio.NewWriter(file)
+	frameIndex, err := common.CompressBlock(block, blockSize, writer, compression, 10, compressionFrameSize)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		ioErr := file.Close()
+		if ioErr != nil {
+			return 0, nil, errore.WrapError(ioErr, err)
+		}
+		return 0, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		closeFile(file)
+		return 0, nil, errore.Wrap(err)
+	}
+	return info.Size(), frameIndex, file.Close()
+}
+
+// commitTemporaryFiles renames temporary files over the files in order, the log block goes first so
+// recoverTemporaryFiles knows the change was committed if the log block has no temporary file
+func commitTemporaryFiles(afs *afero.Afero, suffix string, fileNames ...string) error {
+	for _, fileName := range fileNames {
+		err := afs.Rename(fileName+suffix, fileName)
+		if err != nil {
+			return errore.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// recoverTemporaryFiles finishes compaction and compression that was committed, and rolls back changes
+// that were not, when ibsen was stopped while changing a sealed block
+func (t *Topic) recoverTemporaryFiles() error {
+	topicPath := t.RootPath + common.Sep + t.TopicName
+	files, err := t.Afs.ReadDir(topicPath)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	fileNames := make(map[string]bool)
+	temporaryFiles := make(map[common.LogBlock][]string)
+	uncommitted := make(map[common.LogBlock]bool)
+	for _, file := range files {
+		fileNames[file.Name()] = true
+		target := strings.TrimSuffix(strings.TrimSuffix(file.Name(), compactingSuffix), compressingSuffix)
+		if target == file.Name() {
+			continue
+		}
+		block, err := strconv.ParseUint(strings.Split(file.Name(), ".")[0], 10, 64)
+		if err != nil {
+			return errore.Wrap(err)
+		}
+		temporaryFiles[common.LogBlock(block)] = append(temporaryFiles[common.LogBlock(block)], file.Name())
+		extension := filepath.Ext(target)
+		if extension == ".log" || extension == common.CompressedBlockExtension {
+			uncommitted[common.LogBlock(block)] = true
+		}
+	}
+	for block, names := range temporaryFiles {
+		for _, name := range names {
+			fileName := topicPath + common.Sep + name
+			if uncommitted[block] {
+				err = t.Afs.Remove(fileName)
+			} else {
+				target := strings.TrimSuffix(strings.TrimSuffix(name, compactingSuffix), compressingSuffix)
+				err = t.Afs.Rename(fileName, topicPath+common.Sep+target)
+			}
+			if err != nil {
+				return errore.Wrap(err)
+			}
+		}
+		log.Warn().Str("topic", t.TopicName).
+			Uint64("logBlock", uint64(block)).
+			Bool("committed", !uncommitted[block]).
+			Msg("recovered interrupted change of sealed log block")
+	}
+	// the compressed block is complete when it exists, the uncompressed block is removed last
+	for name := range fileNames {
+		if !common.IsCompressedBlock(name) {
+			continue
+		}
+		uncompressedName := strings.TrimSuffix(name, common.CompressedBlockExtension) + ".log"
+		if fileNames[uncompressedName] {
+			err = t.Afs.Remove(topicPath + common.Sep + uncompressedName)
+			if err != nil {
+				return errore.Wrap(err)
+			}
+		}
+	}
+	return nil
+}
+
+// readableLogBlockFileName is the compressed log block file if the block has been compressed
+func (t *Topic) readableLogBlockFileName(block common.LogBlock) (string, error) {
+	compressedFileName, err := t.compressedLogBlockFileName(block)
+	if err != nil {
+		return "", errore.Wrap(err)
+	}
+	isCompressed, err := t.Afs.Exists(compressedFileName)
+	if err != nil {
+		return "", errore.Wrap(err)
+	}
+	if isCompressed {
+		return compressedFileName, nil
+	}
+	return t.logBlockFileName(block)
+}
+
+func (t *Topic) compressedLogBlockFileName(block common.LogBlock) (string, error) {
+	if t.snapshot().isEmpty() {
+		return "", common.NoBlocksFound
+	}
+	return t.blockFileName(uint64(block), strings.TrimPrefix(common.CompressedBlockExtension, ".")), nil
+}
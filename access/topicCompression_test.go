@@ -0,0 +1,110 @@
+package access
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/tcw/ibsen/access/common"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTopic_UpdateIndex_compresses_sealed_blocks(t *testing.T) {
+	for _, compression := range []common.Compression{common.CompressionGzip, common.CompressionZlib, common.CompressionFlate} {
+		topic := createCompressedTopic(t, compression)
+
+		for _, block := range []common.LogBlock{0, 600} {
+			exists, err := topic.Afs.Exists(fmt.Sprintf("tmp/topic1/%020d.log", block))
+			assert.Nil(t, err)
+			assert.False(t, exists)
+			info, err := topic.Afs.Stat(fmt.Sprintf("tmp/topic1/%020d.logz", block))
+			assert.Nil(t, err)
+			assert.Less(t, info.Size(), int64(100_000))
+		}
+		exists, err := topic.Afs.Exists("tmp/topic1/00000000000000001200.log")
+		assert.Nil(t, err)
+		assert.True(t, exists)
+		frameIndex, err := common.FrameIndex(topic.Afs, "tmp/topic1/00000000000000000000.logz")
+		assert.Nil(t, err)
+		assert.Equal(t, 3*16, len(frameIndex))
+
+		entries := readAllFrom(t, topic, 0)
+		assert.Equal(t, 1800, len(entries))
+		for i, entry := range entries {
+			assert.Equal(t, uint64(i), entry.Offset)
+		}
+		assert.Equal(t, compressibleEntry(599), string(entries[599].Entry))
+		fromMiddle := readAllFrom(t, topic, 905)
+		assert.Equal(t, uint64(905), fromMiddle[0].Offset)
+		assert.Equal(t, 895, len(fromMiddle))
+
+		offset, err := topic.FindOffsetForTimestamp(time.Now().Add(-time.Hour).UnixNano())
+		assert.Nil(t, err)
+		assert.Equal(t, common.Offset(0), offset)
+	}
+}
+
+func TestTopic_Load_compressed_blocks(t *testing.T) {
+	topic := createCompressedTopic(t, common.CompressionGzip)
+	// stopped after the compressed block was committed, but before the uncompressed block was removed
+	err := topic.Afs.WriteFile("tmp/topic1/00000000000000000600.log", []byte("stale"), 0600)
+	assert.Nil(t, err)
+	// stopped before the compressed block was committed
+	err = topic.Afs.WriteFile("tmp/topic1/00000000000000001200.logz"+compressingSuffix, []byte("partial"), 0600)
+	assert.Nil(t, err)
+
+	reloaded := reloadTopic(t, topic)
+	assert.Equal(t, []common.LogBlock{0, 600, 1200}, reloaded.LogBlocks())
+	files, err := topic.Afs.ReadDir("tmp/topic1")
+	assert.Nil(t, err)
+	for _, file := range files {
+		assert.False(t, strings.HasSuffix(file.Name(), compressingSuffix), file.Name())
+		assert.NotEqual(t, "00000000000000000600.log", file.Name())
+	}
+	fromMiddle := readAllFrom(t, reloaded, 1190)
+	assert.Equal(t, uint64(1190), fromMiddle[0].Offset)
+	assert.Equal(t, 610, len(fromMiddle))
+}
+
+func TestTopic_ApplyRetention_compressed_blocks(t *testing.T) {
+	topic := createCompressedTopic(t, common.CompressionGzip)
+	topic.Retention = common.RetentionPolicy{MaxBlocks: 1}
+
+	deleted, err := topic.ApplyRetention(time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, []common.LogBlock{0, 600}, deleted)
+	files, err := topic.Afs.ReadDir("tmp/topic1")
+	assert.Nil(t, err)
+	for _, file := range files {
+		assert.True(t, strings.HasPrefix(file.Name(), "00000000000000001200"), file.Name())
+	}
+}
+
+// createCompressedTopic writes two sealed blocks of 600 entries, each spanning three frames, and a head
+// block, and lets the indexer compress the sealed blocks
+func createCompressedTopic(t *testing.T, compression common.Compression) *Topic {
+	topic := NewLogTopic(common.TopicParams{
+		Afs:            common.MemAfs(),
+		RootPath:       "tmp",
+		TopicName:      "topic1",
+		MaxBlockSize:   500_000,
+		Compression:    compression,
+		IndexCacheSize: 1024 * 1024,
+	})
+	for batch := 0; batch < 3; batch++ {
+		entries := make([][]byte, 600)
+		for i := range entries {
+			entries[i] = []byte(compressibleEntry(batch*600 + i))
+		}
+		_, err := topic.Write(&entries, nil)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, []common.LogBlock{0, 600, 1200}, topic.LogBlocks())
+	_, err := topic.UpdateIndex()
+	assert.Nil(t, err)
+	return topic
+}
+
+func compressibleEntry(i int) string {
+	return fmt.Sprintf(`{"id":%d,"description":"%s"}`, i, strings.Repeat("json lines compress well ", 40))
+}
@@ -0,0 +1,75 @@
+package access
+
+import (
+	"github.com/tcw/ibsen/access/common"
+	"github.com/tcw/ibsen/errore"
+	"os"
+	"strings"
+	"sync"
+)
+
+// TopicDescription is a view of a topic at one point in time
+type TopicDescription struct {
+	FirstOffset   common.Offset
+	NextOffset    common.Offset
+	Blocks        []BlockDescription
+	HeadBlockSize int
+	// IndexPosition is the end of the indexed part of the log, nil if nothing is indexed
+	IndexPosition *common.LogBlockPosition
+	// LastWriteTime is the unix time in nanoseconds of the last entry written, 0 if there are none
+	LastWriteTime int64
+}
+
+type BlockDescription struct {
+	Block      common.LogBlock
+	ByteSize   int64
+	Compressed bool
+}
+
+// Describe returns a description of the topic. writeLock is the lock serializing writes to the topic,
+// it is only held while the writer state is read. The index position is the last one published by the
+// indexer, so Describe does not wait for indexing or compression to finish.
+func (t *Topic) Describe(writeLock sync.Locker) (TopicDescription, error) {
+	indexPosition := t.publishedIndexPosition()
+
+	writeLock.Lock()
+	headBlockSize := t.HeadBlockSize
+	lastWriteTime := t.lastTimestamp
+	writeLock.Unlock()
+
+	nextOffset := t.HighWatermark()
+	blocks := t.snapshot()
+	description := TopicDescription{
+		FirstOffset:   nextOffset,
+		NextOffset:    nextOffset,
+		Blocks:        make([]BlockDescription, 0, blocks.logSize()),
+		HeadBlockSize: headBlockSize,
+		IndexPosition: indexPosition,
+		LastWriteTime: lastWriteTime,
+	}
+	if blocks.logSize() > 0 {
+		description.FirstOffset = common.Offset(blocks.logBlocks[0])
+	}
+	t.blockSwapLock.RLock()
+	defer t.blockSwapLock.RUnlock()
+	for _, block := range blocks.logBlocks {
+		fileName, err := t.readableLogBlockFileName(block)
+		if err != nil {
+			return TopicDescription{}, errore.Wrap(err)
+		}
+		info, err := t.Afs.Stat(fileName)
+		if os.IsNotExist(err) {
+			// removed by retention after the snapshot was taken
+			continue
+		}
+		if err != nil {
+			return TopicDescription{}, errore.Wrap(err)
+		}
+		description.Blocks = append(description.Blocks, BlockDescription{
+			Block:      block,
+			ByteSize:   info.Size(),
+			Compressed: strings.HasSuffix(fileName, common.CompressedBlockExtension),
+		})
+	}
+	return description, nil
+}
@@ -0,0 +1,51 @@
+package access
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/tcw/ibsen/access/common"
+	"sync"
+	"testing"
+)
+
+func TestTopic_Describe(t *testing.T) {
+	afs := common.MemAfs()
+	topic := NewLogTopic(common.TopicParams{
+		Afs:          afs,
+		RootPath:     "tmp",
+		TopicName:    "topic1",
+		MaxBlockSize: 2000,
+	})
+	err := topic.LoadOrCreate()
+	assert.Nil(t, err)
+	description, err := topic.Describe(&sync.Mutex{})
+	assert.Nil(t, err)
+	assert.Equal(t, common.Offset(0), description.FirstOffset)
+	assert.Equal(t, common.Offset(0), description.NextOffset)
+	assert.Empty(t, description.Blocks)
+	assert.Nil(t, description.IndexPosition)
+	assert.Equal(t, int64(0), description.LastWriteTime)
+
+	for i := 0; i < 3; i++ {
+		_, err = topic.Write(createInputEntries(1000), nil)
+		assert.Nil(t, err)
+	}
+	_, err = topic.IndexNewEntries(nil)
+	assert.Nil(t, err)
+	description, err = topic.Describe(&sync.Mutex{})
+	assert.Nil(t, err)
+	assert.Equal(t, common.Offset(0), description.FirstOffset)
+	assert.Equal(t, common.Offset(3000), description.NextOffset)
+	assert.Equal(t, 3, len(description.Blocks))
+	assert.Equal(t, common.LogBlock(2000), description.Blocks[2].Block)
+	assert.Equal(t, int64(topic.HeadBlockSize), description.Blocks[2].ByteSize)
+	assert.Equal(t, topic.HeadBlockSize, description.HeadBlockSize)
+	assert.Equal(t, common.LogBlock(2000), description.IndexPosition.Block)
+	assert.Equal(t, topic.lastTimestamp, description.LastWriteTime)
+
+	// the indexer holds the index lock while it compresses blocks
+	topic.indexLock.Lock()
+	description, err = topic.Describe(&sync.Mutex{})
+	topic.indexLock.Unlock()
+	assert.Nil(t, err)
+	assert.Equal(t, common.LogBlock(2000), description.IndexPosition.Block)
+}
@@ -0,0 +1,101 @@
+package access
+
+import (
+	"github.com/spf13/afero"
+	"github.com/tcw/ibsen/access/common"
+	"github.com/tcw/ibsen/errore"
+	"sync"
+)
+
+// headBlock is the append only handle of the head log block, kept open by the writer between writes
+// and replaced when the head block rolls over
+type headBlock struct {
+	block common.LogBlock
+	file  afero.File
+}
+
+// writeBatch holds the entry headers and trailing offsets of a batch, and the buffers written for it:
+// header, payload and trailing offset for each entry. Batches are reused, so payloads are released
+// after the write.
+type writeBatch struct {
+	frames  []byte
+	buffers [][]byte
+}
+
+var writeBatches = sync.Pool{
+	New: func() any {
+		return &writeBatch{}
+	},
+}
+
+// maxPooledFrameBytes keeps a single huge batch from pinning memory in the pool
+const maxPooledFrameBytes = 1024 * 1024
+
+func (b *writeBatch) release() {
+	for i := range b.buffers {
+		b.buffers[i] = nil
+	}
+	if cap(b.frames) > maxPooledFrameBytes {
+		return
+	}
+	writeBatches.Put(b)
+}
+
+// openHeadBlock returns the handle of the head block, the previous head block is closed on rollover
+func (t *Topic) openHeadBlock(block common.LogBlock) (afero.File, error) {
+	if t.head != nil && t.head.block == block {
+		return t.head.file, nil
+	}
+	err := t.closeHeadBlock()
+	if err != nil {
+		return nil, errore.Wrap(err)
+	}
+	file, err := common.OpenFileForWrite(t.Afs, t.blockFileName(uint64(block), "log"))
+	if err != nil {
+		return nil, errore.Wrap(err)
+	}
+	t.head = &headBlock{
+		block: block,
+		file:  file,
+	}
+	return file, nil
+}
+
+func (t *Topic) closeHeadBlock() error {
+	if t.head == nil {
+		return nil
+	}
+	file := t.head.file
+	t.head = nil
+	return file.Close()
+}
+
+// Close releases the head block handle, the topic can still be written to and will open it again
+func (t *Topic) Close() error {
+	return t.closeHeadBlock()
+}
+
+// encodeBatch creates the buffers for writing entries starting at NextOffset, payloads are not copied
+func (t *Topic) encodeBatch(entries common.EntriesPtr, metadata []common.EntryMetadata) *writeBatch {
+	batch := writeBatches.Get().(*writeBatch)
+	frameBytes := 0
+	for i, entry := range *entries {
+		frameBytes = frameBytes + common.EntryFrameSize(entry, entryMetadata(metadata, i))
+	}
+	// buffers slice into frames, so frames must not be reallocated while appending
+	if cap(batch.frames) < frameBytes {
+		batch.frames = make([]byte, 0, frameBytes)
+	}
+	frames := batch.frames[:0]
+	buffers := batch.buffers[:0]
+	timestamp := t.nextTimestamp()
+	for i, entry := range *entries {
+		start := len(frames)
+		var headerSize int
+		frames, headerSize = common.AppendEntryFrame(frames, entry, t.NextOffset+common.Offset(i), timestamp, entryMetadata(metadata, i))
+		buffers = append(buffers, frames[start:start+headerSize], entry, frames[start+headerSize:])
+	}
+	batch.frames = frames
+	batch.buffers = buffers
+	return batch
+}
@@ -0,0 +1,166 @@
+package access
+
+import (
+	"fmt"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/tcw/ibsen/access/common"
+	"testing"
+	"time"
+)
+
+func TestTopic_Write_vectored_entries_match_byte_entries(t *testing.T) {
+	afs := &afero.Afero{Fs: afero.NewOsFs()}
+	rootPath := t.TempDir()
+	topic := NewLogTopic(common.TopicParams{
+		Afs:          afs,
+		RootPath:     rootPath,
+		TopicName:    "topic1",
+		MaxBlockSize: 1024 * 1024,
+	})
+	err := topic.LoadOrCreate()
+	assert.Nil(t, err)
+	// keeps the timestamp fixed, so the expected entries can be created
+	timestamp := time.Now().Add(time.Hour).UnixNano()
+	topic.lastTimestamp = timestamp
+	metadata := []common.EntryMetadata{{}, {Key: []byte("key"), Headers: map[string]string{"b": "2", "a": "1"}}, {}}
+	entries := [][]byte{[]byte("first"), []byte("second"), {}}
+	_, err = topic.Write(&entries, metadata)
+	assert.Nil(t, err)
+	_, err = topic.Write(&entries, nil)
+	assert.Nil(t, err)
+	err = topic.Close()
+	assert.Nil(t, err)
+
+	var expected []byte
+	for i, entry := range entries {
+		expected = append(expected, common.CreateByteEntryWithMetadata(entry, common.Offset(i), timestamp, metadata[i])...)
+	}
+	for i, entry := range entries {
+		expected = append(expected, common.CreateTimestampedByteEntry(entry, common.Offset(3+i), timestamp)...)
+	}
+	written, err := afs.ReadFile(fmt.Sprintf("%s/topic1/%020d.log", rootPath, 0))
+	assert.Nil(t, err)
+	assert.Equal(t, expected, written)
+	assert.Equal(t, len(expected), topic.HeadBlockSize)
+}
+
+func TestTopic_Write_rotates_head_block_handle(t *testing.T) {
+	topic := NewLogTopic(common.TopicParams{
+		Afs:          common.MemAfs(),
+		RootPath:     "tmp",
+		TopicName:    "topic1",
+		MaxBlockSize: 500,
+	})
+	err := topic.LoadOrCreate()
+	assert.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		_, err = topic.Write(createInputEntries(10), nil)
+		assert.Nil(t, err)
+		head, _ := topic.snapshot().logBlockHead()
+		assert.Equal(t, head, topic.head.block)
+	}
+	assert.Equal(t, []common.LogBlock{0, 20, 40, 60, 80}, topic.LogBlocks())
+	err = topic.Close()
+	assert.Nil(t, err)
+	assert.Nil(t, topic.head)
+
+	// writes after close open the head block again
+	_, err = topic.Write(createInputEntries(10), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 110, len(readAllFrom(t, topic, 0)))
+}
+
+func BenchmarkTopic_Write(b *testing.B) {
+	for _, entrySize := range []int{100, 1000} {
+		b.Run(fmt.Sprintf("vectored/%dB", entrySize), func(b *testing.B) {
+			topic := createBenchmarkTopic(b)
+			entries := createBenchmarkEntries(100, entrySize)
+			b.SetBytes(int64(100 * entrySize))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := topic.Write(entries, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			_ = topic.Close()
+		})
+		b.Run(fmt.Sprintf("reopen-and-copy/%dB", entrySize), func(b *testing.B) {
+			topic := createBenchmarkTopic(b)
+			entries := createBenchmarkEntries(100, entrySize)
+			b.SetBytes(int64(100 * entrySize))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				err := writeReopeningHeadBlock(topic, entries)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// writeReopeningHeadBlock is the write path before the head block handle was kept open: every entry is
+// created and copied into a batch buffer, written after opening the head block, which is closed again
+func writeReopeningHeadBlock(topic *Topic, entries common.EntriesPtr) error {
+	head, hasBlockHead := topic.snapshot().logBlockHead()
+	if !hasBlockHead || topic.HeadBlockSize > topic.MaxBlockSize {
+		head = common.LogBlock(topic.NextOffset)
+		topic.resetHeadBlockSize()
+		topic.addLogBlock(head)
+	}
+	neededAllocation := 0
+	for _, entry := range *entries {
+		neededAllocation = neededAllocation + common.ByteEntrySize(entry, common.EntryMetadata{})
+	}
+	bytes := make([]byte, neededAllocation)
+	start := 0
+	timestamp := topic.nextTimestamp()
+	for i, entry := range *entries {
+		byteEntry := common.CreateByteEntryWithMetadata(entry, topic.NextOffset+common.Offset(i), timestamp, common.EntryMetadata{})
+		copy(bytes[start:], byteEntry)
+		start = start + len(byteEntry)
+	}
+	file, err := common.OpenFileForWrite(topic.Afs, topic.blockFileName(uint64(head), "log"))
+	if err != nil {
+		return err
+	}
+	n, err := file.Write(bytes)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	topic.incrementOffset(len(*entries))
+	topic.incrementHeadBlockSize(n)
+	topic.publishHighWatermark()
+	return file.Close()
+}
+
+func createBenchmarkTopic(b *testing.B) *Topic {
+	topic := NewLogTopic(common.TopicParams{
+		Afs:          &afero.Afero{Fs: afero.NewOsFs()},
+		RootPath:     b.TempDir(),
+		TopicName:    "topic1",
+		MaxBlockSize: 100 * 1024 * 1024,
+	})
+	err := topic.LoadOrCreate()
+	if err != nil {
+		b.Fatal(err)
+	}
+	return topic
+}
+
+func createBenchmarkEntries(numberOfEntries int, entrySize int) *[][]byte {
+	entries := make([][]byte, numberOfEntries)
+	for i := range entries {
+		entries[i] = make([]byte, entrySize)
+		for j := range entries[i] {
+			entries[i][j] = byte('a' + (i+j)%26)
+		}
+	}
+	return &entries
+}
@@ -0,0 +1,247 @@
+package access
+
+import (
+	"encoding/binary"
+	"github.com/tcw/ibsen/access/common"
+	"github.com/tcw/ibsen/errore"
+	"hash/crc32"
+	"time"
+)
+
+// Idempotent producers
+//
+// The last batch written by each producer is kept in memory, and appended to the producer state file of
+// the topic after the batch is written to the log. A retried batch, with a sequence the producer has
+// written already, is acknowledged without being written again. The producer state file is rewritten
+// with only the last batch of each producer when it has grown too large, and when the topic is loaded
+// with records to drop.
+// Batches in the state file past the end of the recovered log were lost with the log tail, and are
+// forgotten so retries write them again. Producers that have not written for ProducerExpiry are
+// forgotten, and dropped from the state file when it is rewritten.
+
+const producerStateFileName = "producers.state"
+
+const rewritingSuffix = ".rewriting"
+
+// maxProducerRecords is the number of records the producer state file can grow to before it is rewritten
+const maxProducerRecords = 10000
+
+// producerRecordHeaderSize is checksum, producer id size, sequence, first offset, number of entries and
+// time of the last write
+const producerRecordHeaderSize = 4 + 4 + 8 + 8 + 4 + 8
+
+var producerChecksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// producerState is the last batch written by a producer
+type producerState struct {
+	sequence    uint64
+	firstOffset common.Offset
+	entries     int
+	// lastWrite is the unix time in nanoseconds the batch was written
+	lastWrite int64
+}
+
+// ProducerWrite is the result of writing a batch from an idempotent producer
+type ProducerWrite struct {
+	FirstOffset common.Offset
+	// Entries is the number of entries written from FirstOffset, 0 if the batch is a retry of an older
+	// batch than the last one written by the producer
+	Entries int
+	// Duplicate is true if the batch was written before and not written again
+	Duplicate bool
+}
+
+// ProducerRetry returns the result of the earlier write if the producer has written the batch already
+func (t *Topic) ProducerRetry(producer common.ProducerBatch) (ProducerWrite, bool) {
+	last, ok := t.producers[producer.ProducerId]
+	if !ok || producer.Sequence > last.sequence {
+		return ProducerWrite{}, false
+	}
+	if producer.Sequence < last.sequence {
+		return ProducerWrite{Duplicate: true}, true
+	}
+	return ProducerWrite{
+		FirstOffset: last.firstOffset,
+		Entries:     last.entries,
+		Duplicate:   true,
+	}, true
+}
+
+// WriteFromProducer writes a batch from an idempotent producer, unless the producer has written it
+// already. Must be serialized with Write.
+func (t *Topic) WriteFromProducer(entries common.EntriesPtr, metadata []common.EntryMetadata, producer common.ProducerBatch) (ProducerWrite, error) {
+	retry, isRetry := t.ProducerRetry(producer)
+	if isRetry {
+		return retry, nil
+	}
+	firstOffset, err := t.Write(entries, metadata)
+	if err != nil {
+		return ProducerWrite{}, errore.Wrap(err)
+	}
+	written := ProducerWrite{
+		FirstOffset: firstOffset,
+		Entries:     len(*entries),
+	}
+	state := producerState{
+		sequence:    producer.Sequence,
+		firstOffset: firstOffset,
+		entries:     len(*entries),
+		lastWrite:   t.lastTimestamp,
+	}
+	// the batch is in the log, so a retry is acknowledged even if the state is not persisted
+	t.producers[producer.ProducerId] = state
+	if t.producerRecords >= maxProducerRecords && t.producerRecords > 2*len(t.producers) {
+		err = t.rewriteProducerState()
+	} else {
+		err = t.appendProducerState(producer.ProducerId, state)
+	}
+	if err != nil {
+		return written, errore.Wrap(err)
+	}
+	return written, nil
+}
+
+func (t *Topic) appendProducerState(producerId string, state producerState) error {
+	file, err := common.OpenFileForWrite(t.Afs, t.producerStateFileName())
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	_, err = file.Write(encodeProducerRecord(producerId, state))
+	if err == nil && t.Durability == common.FsyncPerWrite {
+		err = file.Sync()
+	}
+	if err != nil {
+		ioErr := file.Close()
+		if ioErr != nil {
+			return errore.WrapError(ioErr, err)
+		}
+		return errore.Wrap(err)
+	}
+	t.producerRecords = t.producerRecords + 1
+	return file.Close()
+}
+
+// ExpireProducers forgets producers that have not written for ProducerExpiry, and rewrites the producer
+// state file without them. Must be serialized with Write.
+func (t *Topic) ExpireProducers(now time.Time) (int, error) {
+	expired := t.expireProducers(now)
+	if expired == 0 {
+		return 0, nil
+	}
+	err := t.rewriteProducerState()
+	if err != nil {
+		return expired, errore.Wrap(err)
+	}
+	return expired, nil
+}
+
+func (t *Topic) expireProducers(now time.Time) int {
+	if t.ProducerExpiry <= 0 {
+		return 0
+	}
+	expired := 0
+	for producerId, state := range t.producers {
+		if now.Sub(time.Unix(0, state.lastWrite)) > t.ProducerExpiry {
+			delete(t.producers, producerId)
+			expired = expired + 1
+		}
+	}
+	return expired
+}
+
+// loadProducerState recovers the last batch of each producer, must be called after NextOffset is recovered
+func (t *Topic) loadProducerState() error {
+	t.producers = map[string]producerState{}
+	t.producerRecords = 0
+	fileName := t.producerStateFileName()
+	exists, err := t.Afs.Exists(fileName)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	if !exists {
+		return nil
+	}
+	content, err := t.Afs.ReadFile(fileName)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	dropped := false
+	for len(content) > 0 {
+		producerId, state, size, ok := decodeProducerRecord(content)
+		if !ok {
+			// the tail of a record written when ibsen was stopped
+			dropped = true
+			break
+		}
+		content = content[size:]
+		t.producerRecords = t.producerRecords + 1
+		if state.firstOffset+common.Offset(state.entries) > t.NextOffset {
+			dropped = true
+			continue
+		}
+		t.producers[producerId] = state
+	}
+	expired := t.expireProducers(time.Now())
+	// records of newer batches are appended after a torn record or a lost batch, so they are dropped
+	// from the file before the topic is written to
+	if t.ReadOnly || !dropped && expired == 0 {
+		return nil
+	}
+	return t.rewriteProducerState()
+}
+
+// rewriteProducerState replaces the producer state file with one holding the last batch of each producer
+func (t *Topic) rewriteProducerState() error {
+	var content []byte
+	for producerId, state := range t.producers {
+		content = append(content, encodeProducerRecord(producerId, state)...)
+	}
+	fileName := t.producerStateFileName()
+	err := writeSyncedFile(t.Afs, fileName+rewritingSuffix, content)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	err = commitTemporaryFiles(t.Afs, rewritingSuffix, fileName)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	t.producerRecords = len(t.producers)
+	return nil
+}
+
+func (t *Topic) producerStateFileName() string {
+	return t.RootPath + common.Sep + t.TopicName + common.Sep + producerStateFileName
+}
+
+func encodeProducerRecord(producerId string, state producerState) []byte {
+	record := make([]byte, producerRecordHeaderSize+len(producerId))
+	binary.LittleEndian.PutUint32(record[4:], uint32(len(producerId)))
+	binary.LittleEndian.PutUint64(record[8:], state.sequence)
+	binary.LittleEndian.PutUint64(record[16:], uint64(state.firstOffset))
+	binary.LittleEndian.PutUint32(record[24:], uint32(state.entries))
+	binary.LittleEndian.PutUint64(record[28:], uint64(state.lastWrite))
+	copy(record[producerRecordHeaderSize:], producerId)
+	binary.LittleEndian.PutUint32(record, crc32.Checksum(record[4:], producerChecksumTable))
+	return record
+}
+
+// decodeProducerRecord returns the record at the start of content and its size, false if it is incomplete or corrupt
+func decodeProducerRecord(content []byte) (string, producerState, int, bool) {
+	if len(content) < producerRecordHeaderSize {
+		return "", producerState{}, 0, false
+	}
+	size := producerRecordHeaderSize + int(binary.LittleEndian.Uint32(content[4:]))
+	if size < producerRecordHeaderSize || len(content) < size {
+		return "", producerState{}, 0, false
+	}
+	if crc32.Checksum(content[4:size], producerChecksumTable) != binary.LittleEndian.Uint32(content) {
+		return "", producerState{}, 0, false
+	}
+	state := producerState{
+		sequence:    binary.LittleEndian.Uint64(content[8:]),
+		firstOffset: common.Offset(binary.LittleEndian.Uint64(content[16:])),
+		entries:     int(binary.LittleEndian.Uint32(content[24:])),
+		lastWrite:   int64(binary.LittleEndian.Uint64(content[28:])),
+	}
+	return string(content[producerRecordHeaderSize:size]), state, size, true
+}
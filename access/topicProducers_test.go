@@ -0,0 +1,164 @@
+package access
+
+import (
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/tcw/ibsen/access/common"
+	"testing"
+	"time"
+)
+
+func TestTopic_WriteFromProducer_acknowledges_retries(t *testing.T) {
+	topic := NewLogTopic(common.TopicParams{
+		Afs:          common.MemAfs(),
+		RootPath:     "tmp",
+		TopicName:    "topic1",
+		MaxBlockSize: 1024 * 1024,
+	})
+	err := topic.LoadOrCreate()
+	assert.Nil(t, err)
+	written, err := topic.WriteFromProducer(createInputEntries(10), nil, common.ProducerBatch{ProducerId: "p1", Sequence: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, ProducerWrite{FirstOffset: 0, Entries: 10}, written)
+	written, err = topic.WriteFromProducer(createInputEntries(10), nil, common.ProducerBatch{ProducerId: "p1", Sequence: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, ProducerWrite{FirstOffset: 10, Entries: 10}, written)
+
+	written, err = topic.WriteFromProducer(createInputEntries(10), nil, common.ProducerBatch{ProducerId: "p1", Sequence: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, ProducerWrite{FirstOffset: 10, Entries: 10, Duplicate: true}, written)
+	written, err = topic.WriteFromProducer(createInputEntries(10), nil, common.ProducerBatch{ProducerId: "p1", Sequence: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, ProducerWrite{Duplicate: true}, written)
+	written, err = topic.WriteFromProducer(createInputEntries(10), nil, common.ProducerBatch{ProducerId: "p2", Sequence: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, ProducerWrite{FirstOffset: 20, Entries: 10}, written)
+	assert.Equal(t, common.Offset(30), topic.NextOffset)
+}
+
+func TestTopic_LoadOrCreate_recovers_producer_state(t *testing.T) {
+	afs := common.MemAfs()
+	params := common.TopicParams{
+		Afs:          afs,
+		RootPath:     "tmp",
+		TopicName:    "topic1",
+		MaxBlockSize: 1024 * 1024,
+	}
+	topic := NewLogTopic(params)
+	err := topic.LoadOrCreate()
+	assert.Nil(t, err)
+	for i := 1; i <= 3; i++ {
+		_, err = topic.WriteFromProducer(createInputEntries(10), nil, common.ProducerBatch{ProducerId: "p1", Sequence: uint64(i)})
+		assert.Nil(t, err)
+	}
+	// the batch was lost with the log tail
+	err = topic.appendProducerState("p2", producerState{sequence: 1, firstOffset: 30, entries: 10})
+	assert.Nil(t, err)
+	err = topic.Close()
+	assert.Nil(t, err)
+
+	reloaded := NewLogTopic(params)
+	err = reloaded.LoadOrCreate()
+	assert.Nil(t, err)
+	written, err := reloaded.WriteFromProducer(createInputEntries(10), nil, common.ProducerBatch{ProducerId: "p1", Sequence: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, ProducerWrite{FirstOffset: 20, Entries: 10, Duplicate: true}, written)
+	written, err = reloaded.WriteFromProducer(createInputEntries(10), nil, common.ProducerBatch{ProducerId: "p2", Sequence: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, ProducerWrite{FirstOffset: 30, Entries: 10}, written)
+	assert.Equal(t, 2, reloaded.producerRecords)
+	assert.Equal(t, []common.LogBlock{0}, reloaded.LogBlocks())
+}
+
+func TestTopic_ExpireProducers(t *testing.T) {
+	params := common.TopicParams{
+		Afs:            common.MemAfs(),
+		RootPath:       "tmp",
+		TopicName:      "topic1",
+		MaxBlockSize:   1024 * 1024,
+		ProducerExpiry: time.Hour,
+	}
+	topic := NewLogTopic(params)
+	err := topic.LoadOrCreate()
+	assert.Nil(t, err)
+	for _, producerId := range []string{"p1", "p2"} {
+		_, err = topic.WriteFromProducer(createInputEntries(10), nil, common.ProducerBatch{ProducerId: producerId, Sequence: 1})
+		assert.Nil(t, err)
+	}
+	idle := topic.producers["p1"]
+	idle.lastWrite = time.Now().Add(-2 * time.Hour).UnixNano()
+	topic.producers["p1"] = idle
+
+	expired, err := topic.ExpireProducers(time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, expired)
+	assert.Equal(t, 1, topic.producerRecords)
+	written, err := topic.WriteFromProducer(createInputEntries(10), nil, common.ProducerBatch{ProducerId: "p1", Sequence: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, ProducerWrite{FirstOffset: 20, Entries: 10}, written)
+	err = topic.Close()
+	assert.Nil(t, err)
+
+	reloaded := NewLogTopic(params)
+	err = reloaded.LoadOrCreate()
+	assert.Nil(t, err)
+	written, err = reloaded.WriteFromProducer(createInputEntries(10), nil, common.ProducerBatch{ProducerId: "p2", Sequence: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, ProducerWrite{FirstOffset: 10, Entries: 10, Duplicate: true}, written)
+	assert.Equal(t, 2, len(reloaded.producers))
+}
+
+func TestTopic_LoadOrCreate_read_only_keeps_producer_state_file(t *testing.T) {
+	afs := common.MemAfs()
+	params := common.TopicParams{
+		Afs:            afs,
+		RootPath:       "tmp",
+		TopicName:      "topic1",
+		MaxBlockSize:   1024 * 1024,
+		ProducerExpiry: time.Hour,
+	}
+	topic := NewLogTopic(params)
+	err := topic.LoadOrCreate()
+	assert.Nil(t, err)
+	_, err = topic.WriteFromProducer(createInputEntries(10), nil, common.ProducerBatch{ProducerId: "p1", Sequence: 1})
+	assert.Nil(t, err)
+	_, err = topic.WriteFromProducer(createInputEntries(10), nil, common.ProducerBatch{ProducerId: "p2", Sequence: 1})
+	assert.Nil(t, err)
+	// p1 has expired, and the batch of p3 was lost with the log tail
+	idle := topic.producers["p1"]
+	idle.lastWrite = time.Now().Add(-2 * time.Hour).UnixNano()
+	err = topic.appendProducerState("p1", idle)
+	assert.Nil(t, err)
+	err = topic.appendProducerState("p3", producerState{sequence: 1, firstOffset: 20, entries: 10, lastWrite: time.Now().UnixNano()})
+	assert.Nil(t, err)
+	err = topic.Close()
+	assert.Nil(t, err)
+	stateFile := "tmp/topic1/" + producerStateFileName
+	before, err := afs.ReadFile(stateFile)
+	assert.Nil(t, err)
+
+	params.ReadOnly = true
+	params.Afs = &afero.Afero{Fs: afero.NewReadOnlyFs(afs.Fs)}
+	reloaded := NewLogTopic(params)
+	err = reloaded.LoadOrCreate()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"p2"}, producerIds(reloaded))
+	after, err := afs.ReadFile(stateFile)
+	assert.Nil(t, err)
+	assert.Equal(t, before, after)
+
+	params.ReadOnly = false
+	params.Afs = afs
+	writable := NewLogTopic(params)
+	err = writable.LoadOrCreate()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, writable.producerRecords)
+}
+
+func producerIds(topic *Topic) []string {
+	var ids []string
+	for producerId := range topic.producers {
+		ids = append(ids, producerId)
+	}
+	return ids
+}
@@ -0,0 +1,153 @@
+package access
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/tcw/ibsen/access/common"
+	ibsLog "github.com/tcw/ibsen/access/log"
+	"github.com/tcw/ibsen/errore"
+	"time"
+)
+
+// ApplyRetention deletes sealed log blocks, and their index blocks, from the start of the log
+// until the topics retention policy is satisfied. The head block is never deleted. The age of a block
+// is the age of its last entry, so rewriting or copying block files does not keep old entries. Must not
+// be called concurrently with Write.
+func (t *Topic) ApplyRetention(now time.Time) ([]common.LogBlock, error) {
+	blocks := t.snapshot()
+	if !t.Retention.IsEnabled() || blocks.logSize() < 2 {
+		return nil, nil
+	}
+	// retention and indexing both change the index block list, try again on next run if indexing
+	if !t.indexLock.TryLock() {
+		return nil, nil
+	}
+	defer t.indexLock.Unlock()
+
+	sealed := blocks.logBlocks[:blocks.logSize()-1]
+	sizes := make([]int64, len(sealed))
+	fileNames := make([]string, len(sealed))
+	modified := make([]time.Time, len(sealed))
+	totalBytes := int64(t.HeadBlockSize)
+	for i, block := range sealed {
+		fileName, err := t.readableLogBlockFileName(block)
+		if err != nil {
+			return nil, errore.Wrap(err)
+		}
+		info, err := t.Afs.Stat(fileName)
+		if err != nil {
+			return nil, errore.Wrap(err)
+		}
+		sizes[i] = info.Size()
+		fileNames[i] = fileName
+		modified[i] = info.ModTime()
+		totalBytes = totalBytes + info.Size()
+	}
+
+	expired := 0
+	for i, block := range sealed {
+		remainingBlocks := blocks.logSize() - expired
+		isTooOld := false
+		if t.Retention.MaxAge > 0 {
+			lastWrite, err := t.lastWriteTime(blocks, block, fileNames[i], modified[i])
+			if err != nil {
+				return nil, errore.Wrap(err)
+			}
+			isTooOld = now.Sub(lastWrite) > t.Retention.MaxAge
+		}
+		isTooLarge := t.Retention.MaxBytes > 0 && totalBytes > t.Retention.MaxBytes
+		hasTooManyBlocks := t.Retention.MaxBlocks > 0 && remainingBlocks > t.Retention.MaxBlocks
+		if !isTooOld && !isTooLarge && !hasTooManyBlocks {
+			break
+		}
+		totalBytes = totalBytes - sizes[i]
+		expired = expired + 1
+	}
+	if expired == 0 {
+		return nil, nil
+	}
+
+	// readers pick up the shortened block lists before any file is removed
+	deleted := append([]common.LogBlock{}, sealed[:expired]...)
+	logStart := blocks.logBlocks[expired]
+	t.updateBlocks(func(logBlocks []common.LogBlock, indexBlocks []common.IndexBlock) ([]common.LogBlock, []common.IndexBlock) {
+		var remainingIndexBlocks []common.IndexBlock
+		for _, block := range indexBlocks {
+			if block >= common.IndexBlock(logStart) {
+				remainingIndexBlocks = append(remainingIndexBlocks, block)
+			}
+		}
+		return logBlocks[expired:], remainingIndexBlocks
+	})
+
+	for _, block := range deleted {
+		err := t.removeBlockFiles(block)
+		if err != nil {
+			return deleted, errore.Wrap(err)
+		}
+	}
+	log.Info().Str("topic", t.TopicName).
+		Int("deletedBlocks", len(deleted)).
+		Uint64("logStart", uint64(logStart)).
+		Msg("retention deleted expired log blocks")
+	return deleted, nil
+}
+
+// lastWriteTime is the time the last entry of a sealed block was written. The block is scanned from the
+// last entry in its time index, blocks written before entries had timestamps use the time the block
+// file was modified.
+func (t *Topic) lastWriteTime(blocks *blockSnapshot, block common.LogBlock, fileName string, modified time.Time) (time.Time, error) {
+	var byteOffset int64 = 0
+	timeIndex, err := t.getTimeIndexFromIndexBlock(common.IndexBlock(block))
+	if err != nil {
+		return time.Time{}, errore.Wrap(err)
+	}
+	if timeIndex != nil && !timeIndex.IsEmpty() {
+		last := timeIndex.TimeOffsets[len(timeIndex.TimeOffsets)-1]
+		byteOffset, _, err = t.findByteOffsetInLogBlockFile(blocks, last.Offset, t.HighWatermark())
+		if err != nil {
+			return time.Time{}, t.annotateCorruption(err, block)
+		}
+	}
+	timestamp, found, err := ibsLog.LastTimestamp(t.Afs, fileName, byteOffset, t.CorruptionPolicy)
+	if err != nil {
+		return time.Time{}, t.annotateCorruption(err, block)
+	}
+	if !found {
+		return modified, nil
+	}
+	return time.Unix(0, timestamp), nil
+}
+
+func (t *Topic) removeBlockFiles(block common.LogBlock) error {
+	indexFileName, err := t.indexBlockFileName(common.IndexBlock(block))
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	timeIndexFileName, err := t.timeIndexBlockFileName(common.IndexBlock(block))
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	logFileName, err := t.logBlockFileName(block)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	compressedFileName, err := t.compressedLogBlockFileName(block)
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	for _, fileName := range []string{indexFileName, timeIndexFileName, logFileName, compressedFileName} {
+		exists, err := t.Afs.Exists(fileName)
+		if err != nil {
+			return errore.Wrap(err)
+		}
+		if exists {
+			err = t.Afs.Remove(fileName)
+			if err != nil {
+				return errore.Wrap(err)
+			}
+		}
+	}
+	// after the index block file is gone, so a reader can not cache the index again
+	t.indexCache.Remove(common.IndexBlock(block))
+	return nil
+}
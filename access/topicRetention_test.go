@@ -0,0 +1,168 @@
+package access
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/tcw/ibsen/access/common"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTopic_ApplyRetention(t *testing.T) {
+	tests := []struct {
+		name              string
+		retention         common.RetentionPolicy
+		now               time.Time
+		expectedRemaining int
+	}{
+		{
+			name:              "max blocks",
+			retention:         common.RetentionPolicy{MaxBlocks: 2},
+			now:               time.Now(),
+			expectedRemaining: 2,
+		},
+		{
+			name:              "max bytes",
+			retention:         common.RetentionPolicy{MaxBytes: 7500},
+			now:               time.Now(),
+			expectedRemaining: 2,
+		},
+		{
+			name:              "max age keeps fresh blocks",
+			retention:         common.RetentionPolicy{MaxAge: time.Hour},
+			now:               time.Now(),
+			expectedRemaining: 5,
+		},
+		{
+			name:              "max age never deletes head block",
+			retention:         common.RetentionPolicy{MaxAge: time.Hour},
+			now:               time.Now().Add(2 * time.Hour),
+			expectedRemaining: 1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			topic := createTopicWithBlocks(t, test.retention)
+			assert.Len(t, topic.LogBlocks(), 5)
+			deleted, err := topic.ApplyRetention(test.now)
+			assert.Nil(t, err)
+			assert.Len(t, deleted, 5-test.expectedRemaining)
+			assert.Len(t, topic.LogBlocks(), test.expectedRemaining)
+			for _, block := range deleted {
+				exists, err := topic.Afs.Exists(fmt.Sprintf("tmp/topic1/%020d.log", block))
+				assert.Nil(t, err)
+				assert.False(t, exists)
+			}
+			for _, indexBlock := range topic.IndexBlocks() {
+				assert.GreaterOrEqual(t, uint64(indexBlock), uint64(topic.LogBlocks()[0]))
+			}
+		})
+	}
+}
+
+func TestTopic_ApplyRetention_max_age_of_rewritten_blocks(t *testing.T) {
+	topic := createTopicWithBlocks(t, common.RetentionPolicy{MaxAge: time.Hour})
+	// compaction, compression and copies give old blocks a new modification time
+	later := time.Now().Add(2 * time.Hour)
+	for _, block := range topic.LogBlocks() {
+		fileName, err := topic.logBlockFileName(block)
+		assert.Nil(t, err)
+		err = topic.Afs.Chtimes(fileName, later, later)
+		assert.Nil(t, err)
+	}
+	deleted, err := topic.ApplyRetention(later)
+	assert.Nil(t, err)
+	assert.Len(t, deleted, 4)
+	assert.Len(t, topic.LogBlocks(), 1)
+}
+
+func TestTopic_Read_before_log_start(t *testing.T) {
+	topic := createTopicWithBlocks(t, common.RetentionPolicy{MaxBlocks: 2})
+	_, err := topic.ApplyRetention(time.Now())
+	assert.Nil(t, err)
+	logStart := common.Offset(topic.LogBlocks()[0])
+
+	logChan := make(chan *common.EntryBatch, 100)
+	var wg sync.WaitGroup
+	_, err = topic.Read(common.ReadLogParams{
+		LogChan:   logChan,
+		Wg:        &wg,
+		From:      0,
+		BatchSize: 100,
+	})
+	assert.True(t, errors.Is(err, common.OffsetBeforeLogStart))
+
+	_, err = topic.Read(common.ReadLogParams{
+		LogChan:   logChan,
+		Wg:        &wg,
+		From:      logStart,
+		BatchSize: 1000,
+	})
+	assert.Nil(t, err)
+	close(logChan)
+	expected := logStart
+	for batch := range logChan {
+		for _, entry := range batch.Entries {
+			assert.Equal(t, uint64(expected), entry.Offset)
+			expected = expected + 1
+		}
+	}
+	assert.Equal(t, topic.NextOffset, expected)
+
+	reloaded := NewLogTopic(common.TopicParams{
+		Afs:          topic.Afs,
+		RootPath:     topic.RootPath,
+		TopicName:    topic.TopicName,
+		MaxBlockSize: topic.MaxBlockSize,
+	})
+	err = reloaded.LoadOrCreate()
+	assert.Nil(t, err)
+	assert.Equal(t, topic.LogBlocks(), reloaded.LogBlocks())
+	assert.Equal(t, topic.NextOffset, reloaded.NextOffset)
+}
+
+func createTopicWithBlocks(t *testing.T, retention common.RetentionPolicy) *Topic {
+	topic := NewLogTopic(common.TopicParams{
+		Afs:          common.MemAfs(),
+		RootPath:     "tmp",
+		TopicName:    "topic1",
+		MaxBlockSize: 2000,
+		Retention:    retention,
+	})
+	for i := 0; i < 5; i++ {
+		_, err := topic.Write(createInputEntries(100), nil)
+		assert.Nil(t, err)
+	}
+	_, err := topic.UpdateIndex()
+	assert.Nil(t, err)
+	return topic
+}
+
+func TestTopic_Read_block_removed_while_read(t *testing.T) {
+	topic := createTopicWithBlocks(t, common.RetentionPolicy{})
+	removed := topic.LogBlocks()[2]
+	// retention deleting the block after the reader took its snapshot
+	fileName, err := topic.logBlockFileName(removed)
+	assert.Nil(t, err)
+	err = topic.Afs.Remove(fileName)
+	assert.Nil(t, err)
+
+	logChan := make(chan *common.EntryBatch, 100)
+	var wg sync.WaitGroup
+	scannedTo, err := topic.Read(common.ReadLogParams{
+		LogChan:   logChan,
+		Wg:        &wg,
+		From:      0,
+		BatchSize: 100,
+	})
+	assert.True(t, errors.Is(err, common.OffsetBeforeLogStart))
+	assert.Equal(t, common.Offset(removed), scannedTo)
+	close(logChan)
+	var last uint64
+	for batch := range logChan {
+		last = batch.Entries[len(batch.Entries)-1].Offset
+	}
+	assert.Equal(t, uint64(removed)-1, last)
+}
@@ -0,0 +1,159 @@
+package access
+
+import (
+	"github.com/tcw/ibsen/access/common"
+)
+
+// Concurrency model of a topic
+//
+// Write and ApplyRetention are serialized by the caller (the topic write lock in the manager), and own
+// NextOffset, HeadBlockSize and lastTimestamp. Compact runs concurrently with Write, it only reads
+// entries below the high-watermark and rewrites sealed blocks. UpdateIndex, ApplyRetention and Compact are
+// serialized by indexLock, and own IndexPosition and compressedUpTo. Changes of IndexPosition are
+// published for Describe, so describing a topic does not wait for indexing or compression.
+//
+// Readers never touch writer or indexer state. A write publishes the committed high-watermark after
+// the entries are written, and every change of the block lists publishes a new immutable block
+// snapshot. Readers load the high-watermark first and then the snapshot, so the snapshot always holds
+// the blocks with entries below the high-watermark. Replacing a block file (compaction and compression)
+// is done under blockSwapLock, see Read.
+
+// blockSnapshot is an immutable view of the log and index blocks of a topic
+type blockSnapshot struct {
+	logBlocks   []common.LogBlock
+	indexBlocks []common.IndexBlock
+}
+
+var emptySnapshot = &blockSnapshot{
+	logBlocks:   []common.LogBlock{},
+	indexBlocks: []common.IndexBlock{},
+}
+
+// HighWatermark is the offset after the last entry written, entries below it can be read
+func (t *Topic) HighWatermark() common.Offset {
+	return common.Offset(t.highWatermark.Load())
+}
+
+// LogBlocks is the current log block list, it must not be modified
+func (t *Topic) LogBlocks() []common.LogBlock {
+	return t.blocks.Load().logBlocks
+}
+
+// IndexBlocks is the current index block list, it must not be modified
+func (t *Topic) IndexBlocks() []common.IndexBlock {
+	return t.blocks.Load().indexBlocks
+}
+
+func (t *Topic) snapshot() *blockSnapshot {
+	return t.blocks.Load()
+}
+
+// updateBlocks publishes a new snapshot built from the current one, the lists are copied before
+// they are changed so readers of the old snapshot are unaffected
+func (t *Topic) updateBlocks(update func(logBlocks []common.LogBlock, indexBlocks []common.IndexBlock) ([]common.LogBlock, []common.IndexBlock)) {
+	t.blocksLock.Lock()
+	defer t.blocksLock.Unlock()
+	current := t.blocks.Load()
+	logBlocks, indexBlocks := update(
+		append([]common.LogBlock{}, current.logBlocks...),
+		append([]common.IndexBlock{}, current.indexBlocks...),
+	)
+	t.blocks.Store(&blockSnapshot{
+		logBlocks:   logBlocks,
+		indexBlocks: indexBlocks,
+	})
+}
+
+func (t *Topic) publishHighWatermark() {
+	t.highWatermark.Store(uint64(t.NextOffset))
+}
+
+// setIndexPosition sets IndexPosition and publishes a copy of it, it must hold indexLock
+func (t *Topic) setIndexPosition(position *common.LogBlockPosition) {
+	t.IndexPosition = position
+	if position == nil {
+		t.indexPosition.Store(nil)
+		return
+	}
+	published := *position
+	t.indexPosition.Store(&published)
+}
+
+// publishedIndexPosition is a copy of the last published IndexPosition, nil if nothing is indexed
+func (t *Topic) publishedIndexPosition() *common.LogBlockPosition {
+	position := t.indexPosition.Load()
+	if position == nil {
+		return nil
+	}
+	published := *position
+	return &published
+}
+
+func (s *blockSnapshot) isEmpty() bool {
+	return len(s.logBlocks) == 0
+}
+
+func (s *blockSnapshot) logSize() int {
+	return len(s.logBlocks)
+}
+
+func (s *blockSnapshot) logBlockHead() (common.LogBlock, bool) {
+	if s.isEmpty() {
+		return 0, false
+	}
+	return s.logBlocks[len(s.logBlocks)-1], true
+}
+
+func (s *blockSnapshot) indexBlockHead() (common.IndexBlock, bool) {
+	if s.isEmpty() || len(s.indexBlocks) == 0 {
+		return 0, false
+	}
+	return s.indexBlocks[len(s.indexBlocks)-1], true
+}
+
+func (s *blockSnapshot) logStartOffset() (common.Offset, bool) {
+	if s.isEmpty() {
+		return 0, false
+	}
+	return common.Offset(s.logBlocks[0]), true
+}
+
+func (s *blockSnapshot) hasIndexBlock(block common.IndexBlock) bool {
+	for _, b := range s.indexBlocks {
+		if b == block {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *blockSnapshot) indexBlockContaining(offset common.Offset) (common.IndexBlock, bool) {
+	for i := len(s.indexBlocks) - 1; i >= 0; i-- {
+		if offset >= common.Offset(s.indexBlocks[i]) {
+			return s.indexBlocks[i], true
+		}
+	}
+	return 0, false
+}
+
+// logBlockContaining finds the block of an offset below the high-watermark the snapshot was taken at
+func (s *blockSnapshot) logBlockContaining(offset common.Offset, highWatermark common.Offset) (common.LogBlock, bool) {
+	if s.isEmpty() || highWatermark <= offset || offset < common.Offset(s.logBlocks[0]) {
+		return 0, false
+	}
+	for i := len(s.logBlocks) - 1; i >= 0; i-- {
+		if offset >= common.Offset(s.logBlocks[i]) {
+			return s.logBlocks[i], true
+		}
+	}
+	return 0, false
+}
+
+func (s *blockSnapshot) findBlockArrayIndex(block common.LogBlock) (bool, int) {
+	for i, b := range s.logBlocks {
+		if b == block {
+			return true, i
+		}
+	}
+	return false, 0
+}
@@ -2,7 +2,9 @@ package grpcApi
 
 import (
 	"context"
+	"errors"
 	"github.com/rs/zerolog/log"
+	"github.com/tcw/ibsen/access"
 	"github.com/tcw/ibsen/access/common"
 	"github.com/tcw/ibsen/errore"
 	"github.com/tcw/ibsen/manager"
@@ -13,8 +15,11 @@ import (
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/testdata"
+	"io"
 	"math"
 	"net"
+	"path"
+	"strings"
 	"sync"
 	"time"
 
@@ -24,48 +29,49 @@ import (
 var tracer = otel.Tracer("ibsen-server")
 
 type server struct {
-	manager          manager.LogManager
-	CheckForNewEvery time.Duration
-	TTL              time.Duration
+	manager           manager.LogManager
+	TTL               time.Duration
+	writeStreamWindow int
 }
 
+// DefaultWriteStreamWindow is the number of batches received ahead of the batch being written in a write stream
+const DefaultWriteStreamWindow = 16
+
 type GRPCSecurity struct {
 	CertKeyFile   string
 	PrivteKeyFile string
 }
 
 type IbsenGrpcServer struct {
-	GRPCSecurity     GRPCSecurity
-	UseTLS           bool
-	ConnectionTTL    time.Duration
-	CheckForNewEvery time.Duration
-	IbsenServer      *grpc.Server
-	Manager          manager.LogManager
+	GRPCSecurity      GRPCSecurity
+	UseTLS            bool
+	ConnectionTTL     time.Duration
+	WriteStreamWindow int
+	IbsenServer       *grpc.Server
+	Manager           manager.LogManager
 }
 
 func NewUnsecureIbsenGrpcServer(
 	manager manager.LogManager,
-	TTL time.Duration,
-	checkForNewEvery time.Duration) *IbsenGrpcServer {
+	TTL time.Duration) *IbsenGrpcServer {
 	return &IbsenGrpcServer{
-		UseTLS:           false,
-		Manager:          manager,
-		CheckForNewEvery: checkForNewEvery,
-		ConnectionTTL:    TTL,
+		UseTLS:            false,
+		Manager:           manager,
+		ConnectionTTL:     TTL,
+		WriteStreamWindow: DefaultWriteStreamWindow,
 	}
 }
 
 func NewSecureIbsenGrpcServer(
 	manager manager.LogManager,
 	grpcSec GRPCSecurity,
-	TTL time.Duration,
-	checkForNewEvery time.Duration) *IbsenGrpcServer {
+	TTL time.Duration) *IbsenGrpcServer {
 	return &IbsenGrpcServer{
-		GRPCSecurity:     grpcSec,
-		UseTLS:           true,
-		Manager:          manager,
-		CheckForNewEvery: checkForNewEvery,
-		ConnectionTTL:    TTL,
+		GRPCSecurity:      grpcSec,
+		UseTLS:            true,
+		Manager:           manager,
+		ConnectionTTL:     TTL,
+		WriteStreamWindow: DefaultWriteStreamWindow,
 	}
 }
 
@@ -94,14 +100,19 @@ func (igs *IbsenGrpcServer) StartGRPC(listener net.Listener, wg *sync.WaitGroup,
 
 	igs.IbsenServer = grpcServer
 
-	RegisterIbsenServer(grpcServer, &server{
-		manager:          igs.Manager,
-		TTL:              igs.ConnectionTTL,
-		CheckForNewEvery: igs.CheckForNewEvery,
-	})
+	RegisterIbsenServer(grpcServer, igs.Service())
 	return grpcServer.Serve(listener)
 }
 
+// Service is the implementation of the Ibsen service, for serving it with other protocols than gRPC
+func (igs *IbsenGrpcServer) Service() IbsenServer {
+	return &server{
+		manager:           igs.Manager,
+		TTL:               igs.ConnectionTTL,
+		writeStreamWindow: igs.WriteStreamWindow,
+	}
+}
+
 func (igs *IbsenGrpcServer) Shutdown() {
 	igs.IbsenServer.Stop()
 }
@@ -119,46 +130,209 @@ func (s server) List(ctx context.Context, empty *EmptyArgs) (*TopicList, error)
 }
 
 func (s server) Write(ctx context.Context, entries *InputEntries) (*WriteStatus, error) {
-	err := s.manager.Write(common.TopicName(entries.Topic), &entries.Entries)
+	return s.writeBatch(entries)
+}
+
+// WriteStream writes batches in the order they are received, and acknowledges each batch in the same
+// order. Up to writeStreamWindow batches are received ahead of the batch being written, after that the
+// client is held back by flow control. The stream ends with the error of the first batch that fails,
+// batches sent after it are not written.
+func (s server) WriteStream(stream Ibsen_WriteStreamServer) error {
+	window := s.writeStreamWindow
+	if window < 1 {
+		window = 1
+	}
+	inFlight := make(chan *InputEntries, window)
+	received := make(chan error, 1)
+	go func() {
+		defer close(inFlight)
+		for {
+			batch, err := stream.Recv()
+			if err == io.EOF {
+				received <- nil
+				return
+			}
+			if err != nil {
+				received <- err
+				return
+			}
+			select {
+			case inFlight <- batch:
+			case <-stream.Context().Done():
+				received <- stream.Context().Err()
+				return
+			}
+		}
+	}()
+	var batchNumber uint64 = 0
+	for batch := range inFlight {
+		written, err := s.writeBatch(batch)
+		if err != nil {
+			return err
+		}
+		err = stream.Send(&WriteAck{
+			Batch:  batchNumber,
+			Status: written,
+		})
+		if err != nil {
+			return err
+		}
+		batchNumber = batchNumber + 1
+	}
+	return <-received
+}
+
+func (s server) writeBatch(entries *InputEntries) (*WriteStatus, error) {
+	if strings.HasPrefix(entries.Topic, ".") {
+		return nil, status.Errorf(codes.InvalidArgument, "topic %s is reserved, topics starting with '.' are internal", entries.Topic)
+	}
+	if len(entries.Entries) > 0 && len(entries.Records) > 0 {
+		return nil, status.Error(codes.InvalidArgument, "entries and records can not be combined in one batch")
+	}
+	payloads, metadata := convertInput(entries)
+	topicName := common.TopicName(entries.Topic)
+	var firstOffset common.Offset
+	var err error
+	wrote := len(payloads)
+	duplicate := false
+	var expectedNextOffset *common.Offset
+	if entries.ExpectedNextOffset != nil {
+		expected := common.Offset(*entries.ExpectedNextOffset)
+		expectedNextOffset = &expected
+	}
+	switch {
+	case entries.ProducerId != "":
+		producer := common.ProducerBatch{
+			ProducerId: entries.ProducerId,
+			Sequence:   entries.Sequence,
+		}
+		var written access.ProducerWrite
+		written, err = s.manager.WriteFromProducer(topicName, &payloads, metadata, producer, expectedNextOffset)
+		firstOffset, wrote, duplicate = written.FirstOffset, written.Entries, written.Duplicate
+	case expectedNextOffset != nil:
+		firstOffset, err = s.manager.WriteIfNextOffset(topicName, &payloads, metadata, *expectedNextOffset)
+	default:
+		firstOffset, err = s.manager.Write(topicName, &payloads, metadata)
+	}
+	var mismatch *manager.OffsetMismatchError
+	if errors.As(err, &mismatch) {
+		return nil, offsetMismatchStatus(mismatch)
+	}
 	if err != nil {
 		log.Error().Str("stack", errore.SprintStackTraceBd(err)).Err(errore.RootCause(err)).Msgf("write api failed")
 		return nil, status.Error(codes.Unknown, "error writing batch")
 	}
+	if wrote == 0 {
+		return &WriteStatus{Duplicate: duplicate}, nil
+	}
 	return &WriteStatus{
-		Wrote: int64(len(entries.Entries)),
+		Wrote:       int64(wrote),
+		FirstOffset: uint64(firstOffset),
+		LastOffset:  uint64(firstOffset) + uint64(wrote) - 1,
+		Duplicate:   duplicate,
 	}, nil
 }
 
+// offsetMismatchStatus is FAILED_PRECONDITION with the actual next offset of the topic as detail
+func offsetMismatchStatus(mismatch *manager.OffsetMismatchError) error {
+	mismatchStatus, err := status.New(codes.FailedPrecondition, mismatch.Error()).WithDetails(&OffsetMismatch{
+		ExpectedNextOffset: uint64(mismatch.Expected),
+		ActualNextOffset:   uint64(mismatch.Actual),
+	})
+	if err != nil {
+		return status.Error(codes.FailedPrecondition, mismatch.Error())
+	}
+	return mismatchStatus.Err()
+}
+
 func (s server) Read(params *ReadParams, readServer Ibsen_ReadServer) error {
 	readTTL := time.Now().Add(s.TTL)
-	var nextOffset = common.Offset(params.Offset)
-	for time.Until(readTTL) > 0 {
-		logChan := make(chan *[]common.LogEntry)
+	topicName := common.TopicName(params.Topic)
+	nextOffset, err := s.startOffset(topicName, params)
+	if err != nil {
+		return err
+	}
+	filter, err := entryFilter(params.Filters)
+	if err != nil {
+		return err
+	}
+	if params.Group != "" {
+		offset, committed, err := s.manager.FetchCommittedOffset(params.Group, topicName)
+		if errors.Is(err, manager.InvalidConsumerGroup) {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+		if err != nil {
+			log.Error().Str("stack", errore.SprintStackTraceBd(err)).Err(errore.RootCause(err)).Msgf("read api failed fetching committed offset")
+			return status.Error(codes.Unknown, "error fetching committed offset")
+		}
+		if committed {
+			nextOffset = offset
+		}
+	}
+	limits := readLimits{
+		endOffset:  common.Offset(params.EndOffset),
+		maxEntries: params.MaxEntries,
+		maxBytes:   params.MaxBytes,
+	}
+	for time.Until(readTTL) > 0 && !limits.reached(nextOffset) {
+		// taken before reading, so a write after the read wakes up the reader
+		written := s.manager.AwaitWrite(topicName)
+		logChan := make(chan *common.EntryBatch)
 		terminate := make(chan bool)
-		lastOffset := make(chan common.Offset)
+		progress := make(chan readProgress)
 		// starts a go routine for sending messages over grpc async
 		var wg sync.WaitGroup
-		go sendGRPCMessage(logChan, &wg, readServer, terminate, lastOffset)
-		topicName := common.TopicName(params.Topic)
+		go sendGRPCMessage(logChan, &wg, readServer.Send, terminate, progress)
 		// start reading entries passed to go routine for sending
-		err := s.manager.Read(manager.ReadParams{
-			TopicName: topicName,
-			From:      nextOffset,
-			BatchSize: params.BatchSize,
-			LogChan:   logChan,
-			Wg:        &wg,
+		scannedTo, err := s.manager.Read(manager.ReadParams{
+			TopicName:  topicName,
+			From:       nextOffset,
+			BatchSize:  params.BatchSize,
+			LogChan:    logChan,
+			Wg:         &wg,
+			EndOffset:  limits.endOffset,
+			MaxEntries: remainingLimit(limits.maxEntries, limits.entries),
+			MaxBytes:   remainingLimit(limits.maxBytes, limits.bytes),
+			Filter:     filter,
 		})
 		if err == manager.TopicNotFound {
 			terminate <- true
+			<-progress
 			return status.Errorf(codes.NotFound, "Topic %s not found", topicName)
 		}
+		if errors.Is(err, common.OffsetBeforeLogStart) {
+			// a block removed by retention while it was read, deliver the entries read before it
+			wg.Wait()
+			terminate <- true
+			<-progress
+			return status.Errorf(codes.OutOfRange, "offset %d is before log start of topic %s", scannedTo, topicName)
+		}
 		if err == common.NoEntriesFound {
 			terminate <- true
-			time.Sleep(s.CheckForNewEvery)
+			<-progress
+			if !awaitWrite(readServer.Context(), written, readTTL) {
+				return nil
+			}
 			continue
 		}
+		var corruption *common.CorruptEntryError
+		if errors.As(err, &corruption) {
+			if corruption.Stopped {
+				// deliver everything up to the last good entry before ending the stream
+				wg.Wait()
+			}
+			terminate <- true
+			<-progress
+			log.Error().Str("stack", errore.SprintStackTraceBd(err)).Err(corruption).Msgf("read api found corrupt entry")
+			if corruption.Stopped {
+				return nil
+			}
+			return status.Errorf(codes.DataLoss, "corrupt entry in topic %s block %d at offset %d",
+				corruption.Topic, corruption.Block, corruption.Offset)
+		}
 		if err != nil {
 			terminate <- true
+			<-progress
 			log.Error().Str("stack", errore.SprintStackTraceBd(err)).Err(errore.RootCause(err)).Msgf("read api failed")
 			return status.Error(codes.Unknown, "error reading streaming")
 		}
@@ -166,47 +340,464 @@ func (s server) Read(params *ReadParams, readServer Ibsen_ReadServer) error {
 		wg.Wait()
 		// destroy routine
 		terminate <- true
-		nextOffset = <-lastOffset + 1
+		sent := <-progress
+		if sent.err != nil {
+			return sent.err
+		}
 		// refresh ttl
 		readTTL = time.Now().Add(s.TTL)
 		if params.StopOnCompletion {
 			return nil
 		}
+		if sent.entries > 0 {
+			limits.add(sent)
+		}
+		// entries below the offset the read scanned to that are not sent are filtered out, or removed by
+		// compaction
+		nextOffset = scannedTo
+		if limits.reached(nextOffset) {
+			return nil
+		}
+		if sent.entries == 0 {
+			if !awaitWrite(readServer.Context(), written, readTTL) {
+				return nil
+			}
+			continue
+		}
+	}
+	return nil
+}
+
+// entryFilter is the filter matching all filters of a read, nil if there are none
+func entryFilter(filters []*EntryFilter) (common.EntryFilter, error) {
+	entryFilters := make([]common.EntryFilter, 0, len(filters))
+	for _, filter := range filters {
+		switch f := filter.GetFilter().(type) {
+		case *EntryFilter_Prefix:
+			entryFilters = append(entryFilters, common.PrefixFilter(f.Prefix))
+		case *EntryFilter_Contains:
+			entryFilters = append(entryFilters, common.ContainsFilter(f.Contains))
+		case *EntryFilter_Regex:
+			regexFilter, err := common.RegexFilter(f.Regex)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "invalid regex filter: %s", err)
+			}
+			entryFilters = append(entryFilters, regexFilter)
+		case *EntryFilter_JsonField:
+			if f.JsonField.GetField() == "" {
+				return nil, status.Error(codes.InvalidArgument, "json field filter needs a field")
+			}
+			entryFilters = append(entryFilters, common.JSONFieldFilter(f.JsonField.Field, f.JsonField.Value))
+		default:
+			return nil, status.Error(codes.InvalidArgument, "filter has no condition")
+		}
+	}
+	return common.AllFilters(entryFilters...), nil
+}
+
+// startOffset resolves the start position of a read, relative positions against the end of the topic
+func (s server) startOffset(topicName common.TopicName, params *ReadParams) (common.Offset, error) {
+	switch params.StartPosition {
+	case StartPosition_OFFSET:
+		if params.FromTimestamp == 0 {
+			return common.Offset(params.Offset), nil
+		}
+		offset, err := s.manager.OffsetForTimestamp(topicName, params.FromTimestamp)
+		if err != nil {
+			log.Error().Str("stack", errore.SprintStackTraceBd(err)).Err(errore.RootCause(err)).Msgf("read api failed finding offset from timestamp")
+			return 0, status.Error(codes.Unknown, "error finding offset from timestamp")
+		}
+		return offset, nil
+	case StartPosition_EARLIEST:
+		first, _ := s.manager.OffsetRange(topicName)
+		return first, nil
+	case StartPosition_LATEST:
+		_, next := s.manager.OffsetRange(topicName)
+		return next, nil
+	case StartPosition_LATEST_MINUS_N:
+		first, next := s.manager.OffsetRange(topicName)
+		if uint64(next-first) < params.Offset {
+			return first, nil
+		}
+		return next - common.Offset(params.Offset), nil
+	default:
+		return 0, status.Errorf(codes.InvalidArgument, "unknown start position %d", params.StartPosition)
+	}
+}
+
+// readProgress is what a sender has sent when it is terminated
+type readProgress struct {
+	lastOffset common.Offset
+	entries    uint64
+	bytes      uint64
+	// err is the error of the first failed send, batches after it are not sent
+	err error
+}
+
+// readLimits are the bounds of a read stream, and what is sent so far. Zero is no limit.
+type readLimits struct {
+	endOffset  common.Offset
+	maxEntries uint64
+	maxBytes   uint64
+	entries    uint64
+	bytes      uint64
+}
+
+func (r *readLimits) add(sent readProgress) {
+	r.entries = r.entries + sent.entries
+	r.bytes = r.bytes + sent.bytes
+}
+
+func (r *readLimits) reached(nextOffset common.Offset) bool {
+	return (r.endOffset > 0 && nextOffset >= r.endOffset) ||
+		(r.maxEntries > 0 && r.entries >= r.maxEntries) ||
+		(r.maxBytes > 0 && r.bytes >= r.maxBytes)
+}
+
+// remainingLimit is what is left of a limit not yet reached, zero is still no limit
+func remainingLimit(limit uint64, used uint64) uint64 {
+	if limit == 0 {
+		return 0
+	}
+	return limit - used
+}
+
+// subscribeEntriesPerPass bounds the entries read from one topic before the other topics of a
+// subscription are read
+const subscribeEntriesPerPass = 10000
+
+// Subscribe reads the topics of a subscription in one stream. Every pass reads the entries written
+// since the last pass from each topic, and waits for a write to any topic when there were none. The
+// topics are matched on every pass, so topics created after the stream started are read as well.
+func (s server) Subscribe(params *SubscribeParams, subscribeServer Ibsen_SubscribeServer) error {
+	topics, err := newSubscription(params)
+	if err != nil {
+		return err
+	}
+	readTTL := time.Now().Add(s.TTL)
+	startPosition := params.StartPosition
+	if startPosition == StartPosition_OFFSET {
+		startPosition = StartPosition_EARLIEST
+	}
+	nextOffsets := map[common.TopicName]common.Offset{}
+	for _, topicName := range topics.matching(s.manager.List()) {
+		nextOffsets[topicName], err = s.startOffset(topicName, &ReadParams{
+			StartPosition: startPosition,
+			Offset:        params.Offset,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for time.Until(readTTL) > 0 {
+		// taken before listing and reading, so a write or a new topic after the pass wakes up the subscriber
+		written := s.manager.AwaitAnyWrite()
+		var sent uint64
+		for _, topicName := range topics.matching(s.manager.List()) {
+			nextOffset, subscribed := nextOffsets[topicName]
+			if !subscribed {
+				// created after the subscription started
+				nextOffset, _ = s.manager.OffsetRange(topicName)
+			}
+			nextOffset, entries, stopped, err := s.sendTopicEntries(topicName, nextOffset, params.BatchSize, subscribeServer)
+			if err != nil || stopped {
+				return err
+			}
+			nextOffsets[topicName] = nextOffset
+			sent = sent + entries
+		}
+		if sent == 0 {
+			if !awaitWrite(subscribeServer.Context(), written, readTTL) {
+				return nil
+			}
+			continue
+		}
+		readTTL = time.Now().Add(s.TTL)
 	}
 	return nil
 }
 
-func sendGRPCMessage(logChan chan *[]common.LogEntry,
+// sendTopicEntries sends the entries of a topic from offset, tagged with the topic name. Returns the
+// offset the next pass reads from, the entries sent, and true if the read stopped at a corrupt entry,
+// and the stream should end.
+func (s server) sendTopicEntries(topicName common.TopicName, from common.Offset, batchSize uint32,
+	subscribeServer Ibsen_SubscribeServer) (common.Offset, uint64, bool, error) {
+
+	first, _ := s.manager.OffsetRange(topicName)
+	if from < first {
+		// entries not read yet are removed by retention
+		from = first
+	}
+	logChan := make(chan *common.EntryBatch)
+	terminate := make(chan bool)
+	progress := make(chan readProgress)
+	var wg sync.WaitGroup
+	go sendGRPCMessage(logChan, &wg, func(output *OutputEntries) error {
+		return subscribeServer.Send(&TopicEntries{
+			Topic:   string(topicName),
+			Entries: output.Entries,
+		})
+	}, terminate, progress)
+	scannedTo, err := s.manager.Read(manager.ReadParams{
+		TopicName:  topicName,
+		From:       from,
+		BatchSize:  batchSize,
+		LogChan:    logChan,
+		Wg:         &wg,
+		MaxEntries: subscribeEntriesPerPass,
+	})
+	if err == common.NoEntriesFound {
+		terminate <- true
+		sent := <-progress
+		return from, sent.entries, false, nil
+	}
+	if errors.Is(err, common.OffsetBeforeLogStart) {
+		// removed by retention while it was read, the next pass restarts from the first offset left
+		wg.Wait()
+		terminate <- true
+		sent := <-progress
+		return scannedTo, sent.entries, false, sent.err
+	}
+	var corruption *common.CorruptEntryError
+	if errors.As(err, &corruption) {
+		if corruption.Stopped {
+			// deliver everything up to the last good entry before ending the stream
+			wg.Wait()
+		}
+		terminate <- true
+		sent := <-progress
+		log.Error().Str("stack", errore.SprintStackTraceBd(err)).Err(corruption).Msgf("subscribe api found corrupt entry")
+		if corruption.Stopped {
+			return scannedTo, sent.entries, true, nil
+		}
+		return scannedTo, sent.entries, false, status.Errorf(codes.DataLoss, "corrupt entry in topic %s block %d at offset %d",
+			corruption.Topic, corruption.Block, corruption.Offset)
+	}
+	if err != nil {
+		terminate <- true
+		<-progress
+		log.Error().Str("stack", errore.SprintStackTraceBd(err)).Err(errore.RootCause(err)).Msgf("subscribe api failed")
+		return from, 0, false, status.Error(codes.Unknown, "error reading subscription")
+	}
+	wg.Wait()
+	terminate <- true
+	sent := <-progress
+	return scannedTo, sent.entries, false, sent.err
+}
+
+// subscription matches topic names against the topics and pattern of a subscribe request
+type subscription struct {
+	topics  map[common.TopicName]bool
+	pattern string
+}
+
+func newSubscription(params *SubscribeParams) (subscription, error) {
+	if len(params.Topics) == 0 && params.Pattern == "" {
+		return subscription{}, status.Error(codes.InvalidArgument, "subscribe needs topics or a pattern")
+	}
+	if params.Pattern != "" {
+		_, err := path.Match(params.Pattern, "")
+		if err != nil {
+			return subscription{}, status.Errorf(codes.InvalidArgument, "invalid topic pattern %q", params.Pattern)
+		}
+	}
+	topics := make(map[common.TopicName]bool, len(params.Topics))
+	for _, topic := range params.Topics {
+		topics[common.TopicName(topic)] = true
+	}
+	return subscription{
+		topics:  topics,
+		pattern: params.Pattern,
+	}, nil
+}
+
+// matching returns the topics in the subscription, topics hidden from list are never matched
+func (s subscription) matching(topics []common.TopicName) []common.TopicName {
+	var matching []common.TopicName
+	for _, topic := range topics {
+		if s.matches(topic) {
+			matching = append(matching, topic)
+		}
+	}
+	return matching
+}
+
+func (s subscription) matches(topic common.TopicName) bool {
+	if s.topics[topic] {
+		return true
+	}
+	if s.pattern == "" {
+		return false
+	}
+	matched, _ := path.Match(s.pattern, string(topic))
+	return matched
+}
+
+func (s server) CommitOffset(ctx context.Context, params *CommitParams) (*CommitStatus, error) {
+	err := s.manager.CommitOffset(params.Group, common.TopicName(params.Topic), common.Offset(params.Offset))
+	if errors.Is(err, manager.InvalidConsumerGroup) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		log.Error().Str("stack", errore.SprintStackTraceBd(err)).Err(errore.RootCause(err)).Msgf("commit offset api failed")
+		return nil, status.Error(codes.Unknown, "error committing offset")
+	}
+	return &CommitStatus{}, nil
+}
+
+func (s server) FetchCommittedOffset(ctx context.Context, params *FetchOffsetParams) (*CommittedOffset, error) {
+	offset, committed, err := s.manager.FetchCommittedOffset(params.Group, common.TopicName(params.Topic))
+	if errors.Is(err, manager.InvalidConsumerGroup) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err != nil {
+		log.Error().Str("stack", errore.SprintStackTraceBd(err)).Err(errore.RootCause(err)).Msgf("fetch committed offset api failed")
+		return nil, status.Error(codes.Unknown, "error fetching committed offset")
+	}
+	return &CommittedOffset{
+		Offset:    uint64(offset),
+		Committed: committed,
+	}, nil
+}
+
+func (s server) DescribeTopic(ctx context.Context, params *DescribeParams) (*TopicDescription, error) {
+	topicName := common.TopicName(params.Topic)
+	description, err := s.manager.DescribeTopic(topicName)
+	if err == manager.TopicNotFound {
+		return nil, status.Errorf(codes.NotFound, "Topic %s not found", topicName)
+	}
+	if err != nil {
+		log.Error().Str("stack", errore.SprintStackTraceBd(err)).Err(errore.RootCause(err)).Msgf("describe topic api failed")
+		return nil, status.Error(codes.Unknown, "error describing topic")
+	}
+	blocks := make([]*BlockDescription, len(description.Blocks))
+	for i, block := range description.Blocks {
+		blocks[i] = &BlockDescription{
+			Block:      uint64(block.Block),
+			ByteSize:   block.ByteSize,
+			Compressed: block.Compressed,
+		}
+	}
+	var indexPosition *LogPosition
+	if description.IndexPosition != nil {
+		indexPosition = &LogPosition{
+			Block:      uint64(description.IndexPosition.Block),
+			ByteOffset: description.IndexPosition.ByteOffset,
+		}
+	}
+	return &TopicDescription{
+		Topic:         params.Topic,
+		FirstOffset:   uint64(description.FirstOffset),
+		NextOffset:    uint64(description.NextOffset),
+		Blocks:        blocks,
+		HeadBlockSize: int64(description.HeadBlockSize),
+		IndexPosition: indexPosition,
+		LastWriteTime: description.LastWriteTime,
+	}, nil
+}
+
+// awaitWrite waits until there is a new write, returns false if the read ttl expires or the client
+// is gone first
+func awaitWrite(ctx context.Context, written <-chan struct{}, readTTL time.Time) bool {
+	timeout := time.NewTimer(time.Until(readTTL))
+	defer timeout.Stop()
+	select {
+	case <-written:
+		return true
+	case <-timeout.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func sendGRPCMessage(logChan chan *common.EntryBatch,
 	wg *sync.WaitGroup,
-	outStream Ibsen_ReadServer,
+	send func(output *OutputEntries) error,
 	terminate chan bool,
-	lastOffset chan common.Offset) {
+	progress chan readProgress) {
 
-	var lastReadOffset = common.Offset(0)
+	output := outputBuffers.Get().(*outputBuffer)
+	defer output.release()
+	var sent readProgress
 	for {
 		select {
 		case <-terminate:
 			close(logChan)
-			lastOffset <- lastReadOffset
+			progress <- sent
 			return
 		case entryBatch := <-logChan:
-			batch := *entryBatch
+			batch := entryBatch.Entries
 			if len(batch) == 0 {
+				entryBatch.Release()
 				break
 			}
-			lastReadOffset = common.Offset(batch[len(batch)-1].Offset)
-			err := outStream.Send(&OutputEntries{
-				Entries: convert(entryBatch),
-			})
+			if sent.err != nil {
+				// the rest of the read is dropped, so the reader is not blocked
+				entryBatch.Release()
+				wg.Done()
+				break
+			}
+			sent.lastOffset = common.Offset(batch[len(batch)-1].Offset)
+			sent.entries = sent.entries + uint64(len(batch))
+			for _, entry := range batch {
+				sent.bytes = sent.bytes + uint64(entry.ByteSize)
+			}
+			err := send(output.convert(batch))
+			// the message is marshalled by Send, so the entries can be reused
+			entryBatch.Release()
 			if err != nil {
 				log.Err(err)
-				return
+				sent.err = err
 			}
 			wg.Done()
 		}
 	}
 }
 
+// outputBuffer holds the messages sent for a batch, and is reused for all batches of a read
+type outputBuffer struct {
+	entries  []Entry
+	pointers []*Entry
+	output   OutputEntries
+}
+
+var outputBuffers = sync.Pool{
+	New: func() any {
+		return &outputBuffer{}
+	},
+}
+
+func (b *outputBuffer) convert(entries []common.LogEntry) *OutputEntries {
+	if cap(b.entries) < len(entries) {
+		b.entries = make([]Entry, len(entries))
+		b.pointers = make([]*Entry, len(entries))
+		for i := range b.entries {
+			b.pointers[i] = &b.entries[i]
+		}
+	}
+	for i, entry := range entries {
+		out := b.pointers[i]
+		out.Reset()
+		out.Offset = entry.Offset
+		out.Content = entry.Entry
+		out.Timestamp = entry.Timestamp
+		out.Key = entry.Key
+		out.Headers = entry.Headers
+	}
+	b.output.Entries = b.pointers[:len(entries)]
+	return &b.output
+}
+
+// release drops the references to entries sent, and returns the buffer to the pool
+func (b *outputBuffer) release() {
+	for _, entry := range b.pointers {
+		entry.Reset()
+	}
+	b.output.Reset()
+	outputBuffers.Put(b)
+}
+
 func convertTopics(topics []common.TopicName) []string {
 	var sTopic []string
 	for _, topic := range topics {
@@ -215,13 +806,18 @@ func convertTopics(topics []common.TopicName) []string {
 	return sTopic
 }
 
-func convert(entries *[]common.LogEntry) []*Entry {
-	outEntries := make([]*Entry, len(*entries))
-	for i, entry := range *entries {
-		outEntries[i] = &Entry{
-			Offset:  entry.Offset,
-			Content: entry.Entry,
+func convertInput(entries *InputEntries) ([][]byte, []common.EntryMetadata) {
+	if len(entries.Records) == 0 {
+		return entries.Entries, nil
+	}
+	payloads := make([][]byte, len(entries.Records))
+	metadata := make([]common.EntryMetadata, len(entries.Records))
+	for i, record := range entries.Records {
+		payloads[i] = record.Content
+		metadata[i] = common.EntryMetadata{
+			Key:     record.Key,
+			Headers: record.Headers,
 		}
 	}
-	return outEntries
+	return payloads, metadata
 }
@@ -0,0 +1,65 @@
+package grpcApi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/tcw/ibsen/access/common"
+	"testing"
+)
+
+func TestOutputBuffer_convert_reuses_entries(t *testing.T) {
+	output := outputBuffers.Get().(*outputBuffer)
+	defer output.release()
+	first := output.convert(createLogEntries(10))
+	assert.Equal(t, 10, len(first.Entries))
+	assert.Equal(t, "entry", string(first.Entries[9].Content))
+	firstEntry := first.Entries[0]
+
+	second := output.convert(createLogEntries(5)[1:])
+	assert.Equal(t, 4, len(second.Entries))
+	assert.Equal(t, uint64(1), second.Entries[0].Offset)
+	assert.Same(t, firstEntry, second.Entries[0])
+}
+
+func BenchmarkSendConversion(b *testing.B) {
+	entries := createLogEntries(1000)
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			output := outputBuffers.Get().(*outputBuffer)
+			_ = output.convert(entries)
+			output.release()
+		}
+	})
+	b.Run("allocating", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = &OutputEntries{Entries: convertAllocating(entries)}
+		}
+	})
+}
+
+// convertAllocating is how batches were converted before output buffers were pooled
+func convertAllocating(entries []common.LogEntry) []*Entry {
+	outEntries := make([]*Entry, len(entries))
+	for i, entry := range entries {
+		outEntries[i] = &Entry{
+			Offset:    entry.Offset,
+			Content:   entry.Entry,
+			Timestamp: entry.Timestamp,
+			Key:       entry.Key,
+			Headers:   entry.Headers,
+		}
+	}
+	return outEntries
+}
+
+func createLogEntries(n int) []common.LogEntry {
+	entries := make([]common.LogEntry, n)
+	for i := range entries {
+		entries[i] = common.LogEntry{
+			Offset: uint64(i),
+			Entry:  []byte("entry"),
+		}
+	}
+	return entries
+}
@@ -20,6 +20,62 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type StartPosition int32
+
+const (
+	// start at offset, or fromTimestamp
+	StartPosition_OFFSET StartPosition = 0
+	// start at the first entry in the topic
+	StartPosition_EARLIEST StartPosition = 1
+	// start after the last entry in the topic, only entries written after the read starts are read
+	StartPosition_LATEST StartPosition = 2
+	// start offset entries before the end of the topic, or at the first entry if the topic has fewer
+	StartPosition_LATEST_MINUS_N StartPosition = 3
+)
+
+// Enum value maps for StartPosition.
+var (
+	StartPosition_name = map[int32]string{
+		0: "OFFSET",
+		1: "EARLIEST",
+		2: "LATEST",
+		3: "LATEST_MINUS_N",
+	}
+	StartPosition_value = map[string]int32{
+		"OFFSET":         0,
+		"EARLIEST":       1,
+		"LATEST":         2,
+		"LATEST_MINUS_N": 3,
+	}
+)
+
+func (x StartPosition) Enum() *StartPosition {
+	p := new(StartPosition)
+	*p = x
+	return p
+}
+
+func (x StartPosition) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (StartPosition) Descriptor() protoreflect.EnumDescriptor {
+	return file_ibsen_proto_enumTypes[0].Descriptor()
+}
+
+func (StartPosition) Type() protoreflect.EnumType {
+	return &file_ibsen_proto_enumTypes[0]
+}
+
+func (x StartPosition) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use StartPosition.Descriptor instead.
+func (StartPosition) EnumDescriptor() ([]byte, []int) {
+	return file_ibsen_proto_rawDescGZIP(), []int{0}
+}
+
 type EmptyArgs struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -64,6 +120,13 @@ type WriteStatus struct {
 	unknownFields protoimpl.UnknownFields
 
 	Wrote int64 `protobuf:"varint,1,opt,name=wrote,proto3" json:"wrote,omitempty"`
+	// offsets of the first and last entry in the batch, entries have consecutive offsets. Both are 0
+	// if the batch was empty.
+	FirstOffset uint64 `protobuf:"varint,2,opt,name=firstOffset,proto3" json:"firstOffset,omitempty"`
+	LastOffset  uint64 `protobuf:"varint,3,opt,name=lastOffset,proto3" json:"lastOffset,omitempty"`
+	// the batch is a retry of a batch written before, wrote and offsets are those of the first write, or
+	// not set if the batch is older than the last batch written by the producer
+	Duplicate bool `protobuf:"varint,4,opt,name=duplicate,proto3" json:"duplicate,omitempty"`
 }
 
 func (x *WriteStatus) Reset() {
@@ -105,21 +168,114 @@ func (x *WriteStatus) GetWrote() int64 {
 	return 0
 }
 
+func (x *WriteStatus) GetFirstOffset() uint64 {
+	if x != nil {
+		return x.FirstOffset
+	}
+	return 0
+}
+
+func (x *WriteStatus) GetLastOffset() uint64 {
+	if x != nil {
+		return x.LastOffset
+	}
+	return 0
+}
+
+func (x *WriteStatus) GetDuplicate() bool {
+	if x != nil {
+		return x.Duplicate
+	}
+	return false
+}
+
+type WriteAck struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// the number of the batch in the stream, the first batch sent is 0
+	Batch  uint64       `protobuf:"varint,1,opt,name=batch,proto3" json:"batch,omitempty"`
+	Status *WriteStatus `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+}
+
+func (x *WriteAck) Reset() {
+	*x = WriteAck{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ibsen_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *WriteAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WriteAck) ProtoMessage() {}
+
+func (x *WriteAck) ProtoReflect() protoreflect.Message {
+	mi := &file_ibsen_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WriteAck.ProtoReflect.Descriptor instead.
+func (*WriteAck) Descriptor() ([]byte, []int) {
+	return file_ibsen_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *WriteAck) GetBatch() uint64 {
+	if x != nil {
+		return x.Batch
+	}
+	return 0
+}
+
+func (x *WriteAck) GetStatus() *WriteStatus {
+	if x != nil {
+		return x.Status
+	}
+	return nil
+}
+
 type ReadParams struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Topic            string `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
+	Topic string `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
+	// the offset to start at, or with LATEST_MINUS_N the number of entries before the end
 	Offset           uint64 `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
 	BatchSize        uint32 `protobuf:"varint,3,opt,name=batchSize,proto3" json:"batchSize,omitempty"`
 	StopOnCompletion bool   `protobuf:"varint,4,opt,name=stopOnCompletion,proto3" json:"stopOnCompletion,omitempty"`
+	// start from the first entry written at or after this unix time in nanoseconds, instead of offset
+	FromTimestamp int64 `protobuf:"varint,5,opt,name=fromTimestamp,proto3" json:"fromTimestamp,omitempty"`
+	// resume from the offset committed by this consumer group, offset and fromTimestamp are used if
+	// the group has not committed an offset for the topic
+	Group string `protobuf:"bytes,6,opt,name=group,proto3" json:"group,omitempty"`
+	// stop before this offset, 0 reads to the end (or follows the topic)
+	EndOffset uint64 `protobuf:"varint,7,opt,name=endOffset,proto3" json:"endOffset,omitempty"`
+	// stop after this many entries, 0 is no limit
+	MaxEntries uint64 `protobuf:"varint,8,opt,name=maxEntries,proto3" json:"maxEntries,omitempty"`
+	// stop after the entry reaching this many payload bytes, 0 is no limit
+	MaxBytes uint64 `protobuf:"varint,9,opt,name=maxBytes,proto3" json:"maxBytes,omitempty"`
+	// where the read starts, resolved against the end of the topic when the read starts
+	StartPosition StartPosition `protobuf:"varint,10,opt,name=startPosition,proto3,enum=StartPosition" json:"startPosition,omitempty"`
+	// only entries matching all filters are sent, batchSize, maxEntries and maxBytes count the entries sent
+	Filters []*EntryFilter `protobuf:"bytes,11,rep,name=filters,proto3" json:"filters,omitempty"`
 }
 
 func (x *ReadParams) Reset() {
 	*x = ReadParams{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_ibsen_proto_msgTypes[2]
+		mi := &file_ibsen_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -132,7 +288,7 @@ func (x *ReadParams) String() string {
 func (*ReadParams) ProtoMessage() {}
 
 func (x *ReadParams) ProtoReflect() protoreflect.Message {
-	mi := &file_ibsen_proto_msgTypes[2]
+	mi := &file_ibsen_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -145,7 +301,7 @@ func (x *ReadParams) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ReadParams.ProtoReflect.Descriptor instead.
 func (*ReadParams) Descriptor() ([]byte, []int) {
-	return file_ibsen_proto_rawDescGZIP(), []int{2}
+	return file_ibsen_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *ReadParams) GetTopic() string {
@@ -176,71 +332,71 @@ func (x *ReadParams) GetStopOnCompletion() bool {
 	return false
 }
 
-type InputEntries struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
-	unknownFields protoimpl.UnknownFields
-
-	Topic   string   `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
-	Entries [][]byte `protobuf:"bytes,2,rep,name=entries,proto3" json:"entries,omitempty"`
+func (x *ReadParams) GetFromTimestamp() int64 {
+	if x != nil {
+		return x.FromTimestamp
+	}
+	return 0
 }
 
-func (x *InputEntries) Reset() {
-	*x = InputEntries{}
-	if protoimpl.UnsafeEnabled {
-		mi := &file_ibsen_proto_msgTypes[3]
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		ms.StoreMessageInfo(mi)
+func (x *ReadParams) GetGroup() string {
+	if x != nil {
+		return x.Group
 	}
+	return ""
 }
 
-func (x *InputEntries) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *ReadParams) GetEndOffset() uint64 {
+	if x != nil {
+		return x.EndOffset
+	}
+	return 0
 }
 
-func (*InputEntries) ProtoMessage() {}
-
-func (x *InputEntries) ProtoReflect() protoreflect.Message {
-	mi := &file_ibsen_proto_msgTypes[3]
-	if protoimpl.UnsafeEnabled && x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-		if ms.LoadMessageInfo() == nil {
-			ms.StoreMessageInfo(mi)
-		}
-		return ms
+func (x *ReadParams) GetMaxEntries() uint64 {
+	if x != nil {
+		return x.MaxEntries
 	}
-	return mi.MessageOf(x)
+	return 0
 }
 
-// Deprecated: Use InputEntries.ProtoReflect.Descriptor instead.
-func (*InputEntries) Descriptor() ([]byte, []int) {
-	return file_ibsen_proto_rawDescGZIP(), []int{3}
+func (x *ReadParams) GetMaxBytes() uint64 {
+	if x != nil {
+		return x.MaxBytes
+	}
+	return 0
 }
 
-func (x *InputEntries) GetTopic() string {
+func (x *ReadParams) GetStartPosition() StartPosition {
 	if x != nil {
-		return x.Topic
+		return x.StartPosition
 	}
-	return ""
+	return StartPosition_OFFSET
 }
 
-func (x *InputEntries) GetEntries() [][]byte {
+func (x *ReadParams) GetFilters() []*EntryFilter {
 	if x != nil {
-		return x.Entries
+		return x.Filters
 	}
 	return nil
 }
 
-type TopicList struct {
+// EntryFilter matches the payload of an entry
+type EntryFilter struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Topics []string `protobuf:"bytes,1,rep,name=topics,proto3" json:"topics,omitempty"`
+	// Types that are assignable to Filter:
+	//	*EntryFilter_Prefix
+	//	*EntryFilter_Contains
+	//	*EntryFilter_Regex
+	//	*EntryFilter_JsonField
+	Filter isEntryFilter_Filter `protobuf_oneof:"filter"`
 }
 
-func (x *TopicList) Reset() {
-	*x = TopicList{}
+func (x *EntryFilter) Reset() {
+	*x = EntryFilter{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_ibsen_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -248,13 +404,13 @@ func (x *TopicList) Reset() {
 	}
 }
 
-func (x *TopicList) String() string {
+func (x *EntryFilter) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*TopicList) ProtoMessage() {}
+func (*EntryFilter) ProtoMessage() {}
 
-func (x *TopicList) ProtoReflect() protoreflect.Message {
+func (x *EntryFilter) ProtoReflect() protoreflect.Message {
 	mi := &file_ibsen_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -266,29 +422,89 @@ func (x *TopicList) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use TopicList.ProtoReflect.Descriptor instead.
-func (*TopicList) Descriptor() ([]byte, []int) {
+// Deprecated: Use EntryFilter.ProtoReflect.Descriptor instead.
+func (*EntryFilter) Descriptor() ([]byte, []int) {
 	return file_ibsen_proto_rawDescGZIP(), []int{4}
 }
 
-func (x *TopicList) GetTopics() []string {
-	if x != nil {
-		return x.Topics
+func (m *EntryFilter) GetFilter() isEntryFilter_Filter {
+	if m != nil {
+		return m.Filter
 	}
 	return nil
 }
 
-type Entry struct {
+func (x *EntryFilter) GetPrefix() []byte {
+	if x, ok := x.GetFilter().(*EntryFilter_Prefix); ok {
+		return x.Prefix
+	}
+	return nil
+}
+
+func (x *EntryFilter) GetContains() []byte {
+	if x, ok := x.GetFilter().(*EntryFilter_Contains); ok {
+		return x.Contains
+	}
+	return nil
+}
+
+func (x *EntryFilter) GetRegex() string {
+	if x, ok := x.GetFilter().(*EntryFilter_Regex); ok {
+		return x.Regex
+	}
+	return ""
+}
+
+func (x *EntryFilter) GetJsonField() *JsonFieldFilter {
+	if x, ok := x.GetFilter().(*EntryFilter_JsonField); ok {
+		return x.JsonField
+	}
+	return nil
+}
+
+type isEntryFilter_Filter interface {
+	isEntryFilter_Filter()
+}
+
+type EntryFilter_Prefix struct {
+	Prefix []byte `protobuf:"bytes,1,opt,name=prefix,proto3,oneof"`
+}
+
+type EntryFilter_Contains struct {
+	Contains []byte `protobuf:"bytes,2,opt,name=contains,proto3,oneof"`
+}
+
+type EntryFilter_Regex struct {
+	// regular expression with the syntax of the go regexp package (RE2)
+	Regex string `protobuf:"bytes,3,opt,name=regex,proto3,oneof"`
+}
+
+type EntryFilter_JsonField struct {
+	JsonField *JsonFieldFilter `protobuf:"bytes,4,opt,name=jsonField,proto3,oneof"`
+}
+
+func (*EntryFilter_Prefix) isEntryFilter_Filter() {}
+
+func (*EntryFilter_Contains) isEntryFilter_Filter() {}
+
+func (*EntryFilter_Regex) isEntryFilter_Filter() {}
+
+func (*EntryFilter_JsonField) isEntryFilter_Filter() {}
+
+// JsonFieldFilter matches JSON objects where a field equals a value
+type JsonFieldFilter struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Offset  uint64 `protobuf:"varint,1,opt,name=offset,proto3" json:"offset,omitempty"`
-	Content []byte `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	// name of the field, fields of nested objects are separated with dots, like customer.tenantId
+	Field string `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	// strings are compared with their content, numbers, booleans and null with their JSON text
+	Value string `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
 }
 
-func (x *Entry) Reset() {
-	*x = Entry{}
+func (x *JsonFieldFilter) Reset() {
+	*x = JsonFieldFilter{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_ibsen_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -296,13 +512,13 @@ func (x *Entry) Reset() {
 	}
 }
 
-func (x *Entry) String() string {
+func (x *JsonFieldFilter) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Entry) ProtoMessage() {}
+func (*JsonFieldFilter) ProtoMessage() {}
 
-func (x *Entry) ProtoReflect() protoreflect.Message {
+func (x *JsonFieldFilter) ProtoReflect() protoreflect.Message {
 	mi := &file_ibsen_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -314,35 +530,44 @@ func (x *Entry) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Entry.ProtoReflect.Descriptor instead.
-func (*Entry) Descriptor() ([]byte, []int) {
+// Deprecated: Use JsonFieldFilter.ProtoReflect.Descriptor instead.
+func (*JsonFieldFilter) Descriptor() ([]byte, []int) {
 	return file_ibsen_proto_rawDescGZIP(), []int{5}
 }
 
-func (x *Entry) GetOffset() uint64 {
+func (x *JsonFieldFilter) GetField() string {
 	if x != nil {
-		return x.Offset
+		return x.Field
 	}
-	return 0
+	return ""
 }
 
-func (x *Entry) GetContent() []byte {
+func (x *JsonFieldFilter) GetValue() string {
 	if x != nil {
-		return x.Content
+		return x.Value
 	}
-	return nil
+	return ""
 }
 
-type OutputEntries struct {
+type SubscribeParams struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Entries []*Entry `protobuf:"bytes,2,rep,name=entries,proto3" json:"entries,omitempty"`
+	// topics to read, a topic not created yet is read from its first entry when it is created
+	Topics []string `protobuf:"bytes,1,rep,name=topics,proto3" json:"topics,omitempty"`
+	// also read all topics matching this pattern, with the syntax of path.Match, for example orders.*
+	Pattern   string `protobuf:"bytes,2,opt,name=pattern,proto3" json:"pattern,omitempty"`
+	BatchSize uint32 `protobuf:"varint,3,opt,name=batchSize,proto3" json:"batchSize,omitempty"`
+	// where the read of topics existing when the subscription starts begins, OFFSET is the same as
+	// EARLIEST. Topics created after the subscription starts are read from their first entry.
+	StartPosition StartPosition `protobuf:"varint,4,opt,name=startPosition,proto3,enum=StartPosition" json:"startPosition,omitempty"`
+	// with LATEST_MINUS_N the number of entries before the end of each topic
+	Offset uint64 `protobuf:"varint,5,opt,name=offset,proto3" json:"offset,omitempty"`
 }
 
-func (x *OutputEntries) Reset() {
-	*x = OutputEntries{}
+func (x *SubscribeParams) Reset() {
+	*x = SubscribeParams{}
 	if protoimpl.UnsafeEnabled {
 		mi := &file_ibsen_proto_msgTypes[6]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -350,13 +575,13 @@ func (x *OutputEntries) Reset() {
 	}
 }
 
-func (x *OutputEntries) String() string {
+func (x *SubscribeParams) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*OutputEntries) ProtoMessage() {}
+func (*SubscribeParams) ProtoMessage() {}
 
-func (x *OutputEntries) ProtoReflect() protoreflect.Message {
+func (x *SubscribeParams) ProtoReflect() protoreflect.Message {
 	mi := &file_ibsen_proto_msgTypes[6]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
@@ -368,108 +593,1254 @@ func (x *OutputEntries) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use OutputEntries.ProtoReflect.Descriptor instead.
-func (*OutputEntries) Descriptor() ([]byte, []int) {
+// Deprecated: Use SubscribeParams.ProtoReflect.Descriptor instead.
+func (*SubscribeParams) Descriptor() ([]byte, []int) {
 	return file_ibsen_proto_rawDescGZIP(), []int{6}
 }
 
-func (x *OutputEntries) GetEntries() []*Entry {
+func (x *SubscribeParams) GetTopics() []string {
 	if x != nil {
-		return x.Entries
+		return x.Topics
 	}
 	return nil
 }
 
-var File_ibsen_proto protoreflect.FileDescriptor
-
-var file_ibsen_proto_rawDesc = []byte{
-	0x0a, 0x0b, 0x69, 0x62, 0x73, 0x65, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x0b, 0x0a,
-	0x09, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x41, 0x72, 0x67, 0x73, 0x22, 0x23, 0x0a, 0x0b, 0x57, 0x72,
-	0x69, 0x74, 0x65, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x77, 0x72, 0x6f,
-	0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x77, 0x72, 0x6f, 0x74, 0x65, 0x22,
-	0x84, 0x01, 0x0a, 0x0a, 0x52, 0x65, 0x61, 0x64, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x12, 0x14,
-	0x0a, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74,
-	0x6f, 0x70, 0x69, 0x63, 0x12, 0x16, 0x0a, 0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x12, 0x1c, 0x0a, 0x09,
-	0x62, 0x61, 0x74, 0x63, 0x68, 0x53, 0x69, 0x7a, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52,
-	0x09, 0x62, 0x61, 0x74, 0x63, 0x68, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x2a, 0x0a, 0x10, 0x73, 0x74,
-	0x6f, 0x70, 0x4f, 0x6e, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04,
-	0x20, 0x01, 0x28, 0x08, 0x52, 0x10, 0x73, 0x74, 0x6f, 0x70, 0x4f, 0x6e, 0x43, 0x6f, 0x6d, 0x70,
-	0x6c, 0x65, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x3e, 0x0a, 0x0c, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x45,
-	0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x12, 0x18, 0x0a, 0x07,
-	0x65, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x07, 0x65,
-	0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x22, 0x23, 0x0a, 0x09, 0x54, 0x6f, 0x70, 0x69, 0x63, 0x4c,
-	0x69, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x73, 0x18, 0x01, 0x20,
-	0x03, 0x28, 0x09, 0x52, 0x06, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x73, 0x22, 0x39, 0x0a, 0x05, 0x45,
-	0x6e, 0x74, 0x72, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x12, 0x18, 0x0a, 0x07,
-	0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x63,
-	0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x22, 0x31, 0x0a, 0x0d, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74,
-	0x45, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x12, 0x20, 0x0a, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x69,
-	0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x06, 0x2e, 0x45, 0x6e, 0x74, 0x72, 0x79,
-	0x52, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x32, 0x7a, 0x0a, 0x05, 0x49, 0x62, 0x73,
-	0x65, 0x6e, 0x12, 0x26, 0x0a, 0x05, 0x77, 0x72, 0x69, 0x74, 0x65, 0x12, 0x0d, 0x2e, 0x49, 0x6e,
-	0x70, 0x75, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x1a, 0x0c, 0x2e, 0x57, 0x72, 0x69,
-	0x74, 0x65, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x22, 0x00, 0x12, 0x27, 0x0a, 0x04, 0x72, 0x65,
-	0x61, 0x64, 0x12, 0x0b, 0x2e, 0x52, 0x65, 0x61, 0x64, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x1a,
-	0x0e, 0x2e, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x22,
-	0x00, 0x30, 0x01, 0x12, 0x20, 0x0a, 0x04, 0x6c, 0x69, 0x73, 0x74, 0x12, 0x0a, 0x2e, 0x45, 0x6d,
-	0x70, 0x74, 0x79, 0x41, 0x72, 0x67, 0x73, 0x1a, 0x0a, 0x2e, 0x54, 0x6f, 0x70, 0x69, 0x63, 0x4c,
-	0x69, 0x73, 0x74, 0x22, 0x00, 0x42, 0x41, 0x0a, 0x1b, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e,
-	0x63, 0x6f, 0x6d, 0x2e, 0x74, 0x63, 0x77, 0x2e, 0x69, 0x62, 0x73, 0x65, 0x6e, 0x2e, 0x63, 0x6c,
-	0x69, 0x65, 0x6e, 0x74, 0x42, 0x0a, 0x49, 0x62, 0x73, 0x65, 0x6e, 0x50, 0x72, 0x6f, 0x74, 0x6f,
-	0x50, 0x01, 0x5a, 0x0c, 0x2f, 0x61, 0x70, 0x69, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x41, 0x70, 0x69,
-	0xa2, 0x02, 0x05, 0x49, 0x42, 0x53, 0x45, 0x4e, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+func (x *SubscribeParams) GetPattern() string {
+	if x != nil {
+		return x.Pattern
+	}
+	return ""
 }
 
-var (
-	file_ibsen_proto_rawDescOnce sync.Once
-	file_ibsen_proto_rawDescData = file_ibsen_proto_rawDesc
-)
+func (x *SubscribeParams) GetBatchSize() uint32 {
+	if x != nil {
+		return x.BatchSize
+	}
+	return 0
+}
 
-func file_ibsen_proto_rawDescGZIP() []byte {
-	file_ibsen_proto_rawDescOnce.Do(func() {
-		file_ibsen_proto_rawDescData = protoimpl.X.CompressGZIP(file_ibsen_proto_rawDescData)
-	})
-	return file_ibsen_proto_rawDescData
+func (x *SubscribeParams) GetStartPosition() StartPosition {
+	if x != nil {
+		return x.StartPosition
+	}
+	return StartPosition_OFFSET
 }
 
-var file_ibsen_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
-var file_ibsen_proto_goTypes = []interface{}{
-	(*EmptyArgs)(nil),     // 0: EmptyArgs
-	(*WriteStatus)(nil),   // 1: WriteStatus
-	(*ReadParams)(nil),    // 2: ReadParams
-	(*InputEntries)(nil),  // 3: InputEntries
-	(*TopicList)(nil),     // 4: TopicList
-	(*Entry)(nil),         // 5: Entry
-	(*OutputEntries)(nil), // 6: OutputEntries
+func (x *SubscribeParams) GetOffset() uint64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
 }
-var file_ibsen_proto_depIdxs = []int32{
-	5, // 0: OutputEntries.entries:type_name -> Entry
-	3, // 1: Ibsen.write:input_type -> InputEntries
-	2, // 2: Ibsen.read:input_type -> ReadParams
-	0, // 3: Ibsen.list:input_type -> EmptyArgs
-	1, // 4: Ibsen.write:output_type -> WriteStatus
-	6, // 5: Ibsen.read:output_type -> OutputEntries
-	4, // 6: Ibsen.list:output_type -> TopicList
-	4, // [4:7] is the sub-list for method output_type
-	1, // [1:4] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+
+type InputEntries struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Topic   string   `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
+	Entries [][]byte `protobuf:"bytes,2,rep,name=entries,proto3" json:"entries,omitempty"`
+	// entries with an optional key and headers, can not be combined with entries in the same batch
+	Records []*InputEntry `protobuf:"bytes,3,rep,name=records,proto3" json:"records,omitempty"`
+	// only write the batch if the next offset of the topic is this offset, if it is not the write fails
+	// with FAILED_PRECONDITION and an OffsetMismatch detail holding the actual next offset
+	ExpectedNextOffset *uint64 `protobuf:"varint,4,opt,name=expectedNextOffset,proto3,oneof" json:"expectedNextOffset,omitempty"`
+	// an idempotent producer sends its id and a sequence increasing with every new batch, and the same
+	// sequence when retrying a batch. A batch the producer has written already is not written again.
+	ProducerId string `protobuf:"bytes,5,opt,name=producerId,proto3" json:"producerId,omitempty"`
+	Sequence   uint64 `protobuf:"varint,6,opt,name=sequence,proto3" json:"sequence,omitempty"`
 }
 
-func init() { file_ibsen_proto_init() }
-func file_ibsen_proto_init() {
-	if File_ibsen_proto != nil {
-		return
+func (x *InputEntries) Reset() {
+	*x = InputEntries{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ibsen_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
 	}
-	if !protoimpl.UnsafeEnabled {
-		file_ibsen_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*EmptyArgs); i {
-			case 0:
-				return &v.state
+}
+
+func (x *InputEntries) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InputEntries) ProtoMessage() {}
+
+func (x *InputEntries) ProtoReflect() protoreflect.Message {
+	mi := &file_ibsen_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InputEntries.ProtoReflect.Descriptor instead.
+func (*InputEntries) Descriptor() ([]byte, []int) {
+	return file_ibsen_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *InputEntries) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+func (x *InputEntries) GetEntries() [][]byte {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+func (x *InputEntries) GetRecords() []*InputEntry {
+	if x != nil {
+		return x.Records
+	}
+	return nil
+}
+
+func (x *InputEntries) GetExpectedNextOffset() uint64 {
+	if x != nil && x.ExpectedNextOffset != nil {
+		return *x.ExpectedNextOffset
+	}
+	return 0
+}
+
+func (x *InputEntries) GetProducerId() string {
+	if x != nil {
+		return x.ProducerId
+	}
+	return ""
+}
+
+func (x *InputEntries) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+type OffsetMismatch struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ExpectedNextOffset uint64 `protobuf:"varint,1,opt,name=expectedNextOffset,proto3" json:"expectedNextOffset,omitempty"`
+	ActualNextOffset   uint64 `protobuf:"varint,2,opt,name=actualNextOffset,proto3" json:"actualNextOffset,omitempty"`
+}
+
+func (x *OffsetMismatch) Reset() {
+	*x = OffsetMismatch{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ibsen_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OffsetMismatch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OffsetMismatch) ProtoMessage() {}
+
+func (x *OffsetMismatch) ProtoReflect() protoreflect.Message {
+	mi := &file_ibsen_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OffsetMismatch.ProtoReflect.Descriptor instead.
+func (*OffsetMismatch) Descriptor() ([]byte, []int) {
+	return file_ibsen_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *OffsetMismatch) GetExpectedNextOffset() uint64 {
+	if x != nil {
+		return x.ExpectedNextOffset
+	}
+	return 0
+}
+
+func (x *OffsetMismatch) GetActualNextOffset() uint64 {
+	if x != nil {
+		return x.ActualNextOffset
+	}
+	return 0
+}
+
+type InputEntry struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Content []byte            `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
+	Key     []byte            `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Headers map[string]string `protobuf:"bytes,3,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (x *InputEntry) Reset() {
+	*x = InputEntry{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ibsen_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *InputEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InputEntry) ProtoMessage() {}
+
+func (x *InputEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_ibsen_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InputEntry.ProtoReflect.Descriptor instead.
+func (*InputEntry) Descriptor() ([]byte, []int) {
+	return file_ibsen_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *InputEntry) GetContent() []byte {
+	if x != nil {
+		return x.Content
+	}
+	return nil
+}
+
+func (x *InputEntry) GetKey() []byte {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *InputEntry) GetHeaders() map[string]string {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+type TopicList struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Topics []string `protobuf:"bytes,1,rep,name=topics,proto3" json:"topics,omitempty"`
+}
+
+func (x *TopicList) Reset() {
+	*x = TopicList{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ibsen_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TopicList) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TopicList) ProtoMessage() {}
+
+func (x *TopicList) ProtoReflect() protoreflect.Message {
+	mi := &file_ibsen_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TopicList.ProtoReflect.Descriptor instead.
+func (*TopicList) Descriptor() ([]byte, []int) {
+	return file_ibsen_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *TopicList) GetTopics() []string {
+	if x != nil {
+		return x.Topics
+	}
+	return nil
+}
+
+type Entry struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Offset  uint64 `protobuf:"varint,1,opt,name=offset,proto3" json:"offset,omitempty"`
+	Content []byte `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	// unix time in nanoseconds when the entry was written, 0 for entries written by older versions
+	Timestamp int64             `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Key       []byte            `protobuf:"bytes,4,opt,name=key,proto3" json:"key,omitempty"`
+	Headers   map[string]string `protobuf:"bytes,5,rep,name=headers,proto3" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (x *Entry) Reset() {
+	*x = Entry{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ibsen_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Entry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Entry) ProtoMessage() {}
+
+func (x *Entry) ProtoReflect() protoreflect.Message {
+	mi := &file_ibsen_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Entry.ProtoReflect.Descriptor instead.
+func (*Entry) Descriptor() ([]byte, []int) {
+	return file_ibsen_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Entry) GetOffset() uint64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *Entry) GetContent() []byte {
+	if x != nil {
+		return x.Content
+	}
+	return nil
+}
+
+func (x *Entry) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *Entry) GetKey() []byte {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *Entry) GetHeaders() map[string]string {
+	if x != nil {
+		return x.Headers
+	}
+	return nil
+}
+
+type CommitParams struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Group string `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	Topic string `protobuf:"bytes,2,opt,name=topic,proto3" json:"topic,omitempty"`
+	// the next offset the group will read
+	Offset uint64 `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+}
+
+func (x *CommitParams) Reset() {
+	*x = CommitParams{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ibsen_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CommitParams) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommitParams) ProtoMessage() {}
+
+func (x *CommitParams) ProtoReflect() protoreflect.Message {
+	mi := &file_ibsen_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommitParams.ProtoReflect.Descriptor instead.
+func (*CommitParams) Descriptor() ([]byte, []int) {
+	return file_ibsen_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *CommitParams) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *CommitParams) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+func (x *CommitParams) GetOffset() uint64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+type CommitStatus struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+}
+
+func (x *CommitStatus) Reset() {
+	*x = CommitStatus{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ibsen_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CommitStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommitStatus) ProtoMessage() {}
+
+func (x *CommitStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_ibsen_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommitStatus.ProtoReflect.Descriptor instead.
+func (*CommitStatus) Descriptor() ([]byte, []int) {
+	return file_ibsen_proto_rawDescGZIP(), []int{13}
+}
+
+type FetchOffsetParams struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Group string `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	Topic string `protobuf:"bytes,2,opt,name=topic,proto3" json:"topic,omitempty"`
+}
+
+func (x *FetchOffsetParams) Reset() {
+	*x = FetchOffsetParams{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ibsen_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *FetchOffsetParams) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FetchOffsetParams) ProtoMessage() {}
+
+func (x *FetchOffsetParams) ProtoReflect() protoreflect.Message {
+	mi := &file_ibsen_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FetchOffsetParams.ProtoReflect.Descriptor instead.
+func (*FetchOffsetParams) Descriptor() ([]byte, []int) {
+	return file_ibsen_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *FetchOffsetParams) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *FetchOffsetParams) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+type CommittedOffset struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Offset uint64 `protobuf:"varint,1,opt,name=offset,proto3" json:"offset,omitempty"`
+	// false if the group has not committed an offset for the topic
+	Committed bool `protobuf:"varint,2,opt,name=committed,proto3" json:"committed,omitempty"`
+}
+
+func (x *CommittedOffset) Reset() {
+	*x = CommittedOffset{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ibsen_proto_msgTypes[15]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CommittedOffset) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommittedOffset) ProtoMessage() {}
+
+func (x *CommittedOffset) ProtoReflect() protoreflect.Message {
+	mi := &file_ibsen_proto_msgTypes[15]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommittedOffset.ProtoReflect.Descriptor instead.
+func (*CommittedOffset) Descriptor() ([]byte, []int) {
+	return file_ibsen_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *CommittedOffset) GetOffset() uint64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *CommittedOffset) GetCommitted() bool {
+	if x != nil {
+		return x.Committed
+	}
+	return false
+}
+
+type OutputEntries struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Entries []*Entry `protobuf:"bytes,2,rep,name=entries,proto3" json:"entries,omitempty"`
+}
+
+func (x *OutputEntries) Reset() {
+	*x = OutputEntries{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ibsen_proto_msgTypes[16]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *OutputEntries) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*OutputEntries) ProtoMessage() {}
+
+func (x *OutputEntries) ProtoReflect() protoreflect.Message {
+	mi := &file_ibsen_proto_msgTypes[16]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use OutputEntries.ProtoReflect.Descriptor instead.
+func (*OutputEntries) Descriptor() ([]byte, []int) {
+	return file_ibsen_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *OutputEntries) GetEntries() []*Entry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+type TopicEntries struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Topic   string   `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
+	Entries []*Entry `protobuf:"bytes,2,rep,name=entries,proto3" json:"entries,omitempty"`
+}
+
+func (x *TopicEntries) Reset() {
+	*x = TopicEntries{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ibsen_proto_msgTypes[17]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TopicEntries) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TopicEntries) ProtoMessage() {}
+
+func (x *TopicEntries) ProtoReflect() protoreflect.Message {
+	mi := &file_ibsen_proto_msgTypes[17]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TopicEntries.ProtoReflect.Descriptor instead.
+func (*TopicEntries) Descriptor() ([]byte, []int) {
+	return file_ibsen_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *TopicEntries) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+func (x *TopicEntries) GetEntries() []*Entry {
+	if x != nil {
+		return x.Entries
+	}
+	return nil
+}
+
+type DescribeParams struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Topic string `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
+}
+
+func (x *DescribeParams) Reset() {
+	*x = DescribeParams{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ibsen_proto_msgTypes[18]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DescribeParams) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DescribeParams) ProtoMessage() {}
+
+func (x *DescribeParams) ProtoReflect() protoreflect.Message {
+	mi := &file_ibsen_proto_msgTypes[18]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DescribeParams.ProtoReflect.Descriptor instead.
+func (*DescribeParams) Descriptor() ([]byte, []int) {
+	return file_ibsen_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *DescribeParams) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+type TopicDescription struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Topic string `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
+	// the first offset that can be read, equal to nextOffset if the topic has no entries
+	FirstOffset uint64 `protobuf:"varint,2,opt,name=firstOffset,proto3" json:"firstOffset,omitempty"`
+	// the offset of the next entry written
+	NextOffset    uint64              `protobuf:"varint,3,opt,name=nextOffset,proto3" json:"nextOffset,omitempty"`
+	Blocks        []*BlockDescription `protobuf:"bytes,4,rep,name=blocks,proto3" json:"blocks,omitempty"`
+	HeadBlockSize int64               `protobuf:"varint,5,opt,name=headBlockSize,proto3" json:"headBlockSize,omitempty"`
+	// entries up to this position in the log are indexed, not set if nothing is indexed
+	IndexPosition *LogPosition `protobuf:"bytes,6,opt,name=indexPosition,proto3" json:"indexPosition,omitempty"`
+	// unix time in nanoseconds of the last entry written, 0 if there are none
+	LastWriteTime int64 `protobuf:"varint,7,opt,name=lastWriteTime,proto3" json:"lastWriteTime,omitempty"`
+}
+
+func (x *TopicDescription) Reset() {
+	*x = TopicDescription{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ibsen_proto_msgTypes[19]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TopicDescription) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TopicDescription) ProtoMessage() {}
+
+func (x *TopicDescription) ProtoReflect() protoreflect.Message {
+	mi := &file_ibsen_proto_msgTypes[19]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TopicDescription.ProtoReflect.Descriptor instead.
+func (*TopicDescription) Descriptor() ([]byte, []int) {
+	return file_ibsen_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *TopicDescription) GetTopic() string {
+	if x != nil {
+		return x.Topic
+	}
+	return ""
+}
+
+func (x *TopicDescription) GetFirstOffset() uint64 {
+	if x != nil {
+		return x.FirstOffset
+	}
+	return 0
+}
+
+func (x *TopicDescription) GetNextOffset() uint64 {
+	if x != nil {
+		return x.NextOffset
+	}
+	return 0
+}
+
+func (x *TopicDescription) GetBlocks() []*BlockDescription {
+	if x != nil {
+		return x.Blocks
+	}
+	return nil
+}
+
+func (x *TopicDescription) GetHeadBlockSize() int64 {
+	if x != nil {
+		return x.HeadBlockSize
+	}
+	return 0
+}
+
+func (x *TopicDescription) GetIndexPosition() *LogPosition {
+	if x != nil {
+		return x.IndexPosition
+	}
+	return nil
+}
+
+func (x *TopicDescription) GetLastWriteTime() int64 {
+	if x != nil {
+		return x.LastWriteTime
+	}
+	return 0
+}
+
+type BlockDescription struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// the offset of the first entry in the block
+	Block      uint64 `protobuf:"varint,1,opt,name=block,proto3" json:"block,omitempty"`
+	ByteSize   int64  `protobuf:"varint,2,opt,name=byteSize,proto3" json:"byteSize,omitempty"`
+	Compressed bool   `protobuf:"varint,3,opt,name=compressed,proto3" json:"compressed,omitempty"`
+}
+
+func (x *BlockDescription) Reset() {
+	*x = BlockDescription{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ibsen_proto_msgTypes[20]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BlockDescription) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockDescription) ProtoMessage() {}
+
+func (x *BlockDescription) ProtoReflect() protoreflect.Message {
+	mi := &file_ibsen_proto_msgTypes[20]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockDescription.ProtoReflect.Descriptor instead.
+func (*BlockDescription) Descriptor() ([]byte, []int) {
+	return file_ibsen_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *BlockDescription) GetBlock() uint64 {
+	if x != nil {
+		return x.Block
+	}
+	return 0
+}
+
+func (x *BlockDescription) GetByteSize() int64 {
+	if x != nil {
+		return x.ByteSize
+	}
+	return 0
+}
+
+func (x *BlockDescription) GetCompressed() bool {
+	if x != nil {
+		return x.Compressed
+	}
+	return false
+}
+
+type LogPosition struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Block      uint64 `protobuf:"varint,1,opt,name=block,proto3" json:"block,omitempty"`
+	ByteOffset int64  `protobuf:"varint,2,opt,name=byteOffset,proto3" json:"byteOffset,omitempty"`
+}
+
+func (x *LogPosition) Reset() {
+	*x = LogPosition{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_ibsen_proto_msgTypes[21]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LogPosition) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogPosition) ProtoMessage() {}
+
+func (x *LogPosition) ProtoReflect() protoreflect.Message {
+	mi := &file_ibsen_proto_msgTypes[21]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogPosition.ProtoReflect.Descriptor instead.
+func (*LogPosition) Descriptor() ([]byte, []int) {
+	return file_ibsen_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *LogPosition) GetBlock() uint64 {
+	if x != nil {
+		return x.Block
+	}
+	return 0
+}
+
+func (x *LogPosition) GetByteOffset() int64 {
+	if x != nil {
+		return x.ByteOffset
+	}
+	return 0
+}
+
+var File_ibsen_proto protoreflect.FileDescriptor
+
+var file_ibsen_proto_rawDesc = []byte{
+	0x0a, 0x0b, 0x69, 0x62, 0x73, 0x65, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x0b, 0x0a,
+	0x09, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x41, 0x72, 0x67, 0x73, 0x22, 0x83, 0x01, 0x0a, 0x0b, 0x57,
+	0x72, 0x69, 0x74, 0x65, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x77, 0x72,
+	0x6f, 0x74, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x77, 0x72, 0x6f, 0x74, 0x65,
+	0x12, 0x20, 0x0a, 0x0b, 0x66, 0x69, 0x72, 0x73, 0x74, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0b, 0x66, 0x69, 0x72, 0x73, 0x74, 0x4f, 0x66, 0x66, 0x73,
+	0x65, 0x74, 0x12, 0x1e, 0x0a, 0x0a, 0x6c, 0x61, 0x73, 0x74, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0a, 0x6c, 0x61, 0x73, 0x74, 0x4f, 0x66, 0x66, 0x73,
+	0x65, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x64, 0x75, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x64, 0x75, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65,
+	0x22, 0x46, 0x0a, 0x08, 0x57, 0x72, 0x69, 0x74, 0x65, 0x41, 0x63, 0x6b, 0x12, 0x14, 0x0a, 0x05,
+	0x62, 0x61, 0x74, 0x63, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x62, 0x61, 0x74,
+	0x63, 0x68, 0x12, 0x24, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x57, 0x72, 0x69, 0x74, 0x65, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x22, 0xf8, 0x02, 0x0a, 0x0a, 0x52, 0x65, 0x61,
+	0x64, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x12, 0x16, 0x0a,
+	0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x6f,
+	0x66, 0x66, 0x73, 0x65, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x62, 0x61, 0x74, 0x63, 0x68, 0x53, 0x69,
+	0x7a, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x62, 0x61, 0x74, 0x63, 0x68, 0x53,
+	0x69, 0x7a, 0x65, 0x12, 0x2a, 0x0a, 0x10, 0x73, 0x74, 0x6f, 0x70, 0x4f, 0x6e, 0x43, 0x6f, 0x6d,
+	0x70, 0x6c, 0x65, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x10, 0x73,
+	0x74, 0x6f, 0x70, 0x4f, 0x6e, 0x43, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x69, 0x6f, 0x6e, 0x12,
+	0x24, 0x0a, 0x0d, 0x66, 0x72, 0x6f, 0x6d, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x66, 0x72, 0x6f, 0x6d, 0x54, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x14, 0x0a, 0x05, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x12, 0x1c, 0x0a, 0x09, 0x65,
+	0x6e, 0x64, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09,
+	0x65, 0x6e, 0x64, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x12, 0x1e, 0x0a, 0x0a, 0x6d, 0x61, 0x78,
+	0x45, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x18, 0x08, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0a, 0x6d,
+	0x61, 0x78, 0x45, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x6d, 0x61, 0x78,
+	0x42, 0x79, 0x74, 0x65, 0x73, 0x18, 0x09, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x6d, 0x61, 0x78,
+	0x42, 0x79, 0x74, 0x65, 0x73, 0x12, 0x34, 0x0a, 0x0d, 0x73, 0x74, 0x61, 0x72, 0x74, 0x50, 0x6f,
+	0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0e, 0x2e, 0x53,
+	0x74, 0x61, 0x72, 0x74, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0d, 0x73, 0x74,
+	0x61, 0x72, 0x74, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x26, 0x0a, 0x07, 0x66,
+	0x69, 0x6c, 0x74, 0x65, 0x72, 0x73, 0x18, 0x0b, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x45,
+	0x6e, 0x74, 0x72, 0x79, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x52, 0x07, 0x66, 0x69, 0x6c, 0x74,
+	0x65, 0x72, 0x73, 0x22, 0x99, 0x01, 0x0a, 0x0b, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x46, 0x69, 0x6c,
+	0x74, 0x65, 0x72, 0x12, 0x18, 0x0a, 0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0c, 0x48, 0x00, 0x52, 0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x12, 0x1c, 0x0a,
+	0x08, 0x63, 0x6f, 0x6e, 0x74, 0x61, 0x69, 0x6e, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x48,
+	0x00, 0x52, 0x08, 0x63, 0x6f, 0x6e, 0x74, 0x61, 0x69, 0x6e, 0x73, 0x12, 0x16, 0x0a, 0x05, 0x72,
+	0x65, 0x67, 0x65, 0x78, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x48, 0x00, 0x52, 0x05, 0x72, 0x65,
+	0x67, 0x65, 0x78, 0x12, 0x30, 0x0a, 0x09, 0x6a, 0x73, 0x6f, 0x6e, 0x46, 0x69, 0x65, 0x6c, 0x64,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x4a, 0x73, 0x6f, 0x6e, 0x46, 0x69, 0x65,
+	0x6c, 0x64, 0x46, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x48, 0x00, 0x52, 0x09, 0x6a, 0x73, 0x6f, 0x6e,
+	0x46, 0x69, 0x65, 0x6c, 0x64, 0x42, 0x08, 0x0a, 0x06, 0x66, 0x69, 0x6c, 0x74, 0x65, 0x72, 0x22,
+	0x3d, 0x0a, 0x0f, 0x4a, 0x73, 0x6f, 0x6e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x46, 0x69, 0x6c, 0x74,
+	0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22, 0xaf,
+	0x01, 0x0a, 0x0f, 0x53, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x50, 0x61, 0x72, 0x61,
+	0x6d, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x73, 0x18, 0x01, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x06, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x61,
+	0x74, 0x74, 0x65, 0x72, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x70, 0x61, 0x74,
+	0x74, 0x65, 0x72, 0x6e, 0x12, 0x1c, 0x0a, 0x09, 0x62, 0x61, 0x74, 0x63, 0x68, 0x53, 0x69, 0x7a,
+	0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x09, 0x62, 0x61, 0x74, 0x63, 0x68, 0x53, 0x69,
+	0x7a, 0x65, 0x12, 0x34, 0x0a, 0x0d, 0x73, 0x74, 0x61, 0x72, 0x74, 0x50, 0x6f, 0x73, 0x69, 0x74,
+	0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x0e, 0x2e, 0x53, 0x74, 0x61, 0x72,
+	0x74, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0d, 0x73, 0x74, 0x61, 0x72, 0x74,
+	0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x6f, 0x66, 0x66, 0x73,
+	0x65, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74,
+	0x22, 0xed, 0x01, 0x0a, 0x0c, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x69, 0x65,
+	0x73, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x12, 0x18, 0x0a, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x69,
+	0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x69, 0x65,
+	0x73, 0x12, 0x25, 0x0a, 0x07, 0x72, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x73, 0x18, 0x03, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52,
+	0x07, 0x72, 0x65, 0x63, 0x6f, 0x72, 0x64, 0x73, 0x12, 0x33, 0x0a, 0x12, 0x65, 0x78, 0x70, 0x65,
+	0x63, 0x74, 0x65, 0x64, 0x4e, 0x65, 0x78, 0x74, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x04, 0x48, 0x00, 0x52, 0x12, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64,
+	0x4e, 0x65, 0x78, 0x74, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x88, 0x01, 0x01, 0x12, 0x1e, 0x0a,
+	0x0a, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x65, 0x72, 0x49, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0a, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x65, 0x72, 0x49, 0x64, 0x12, 0x1a, 0x0a,
+	0x08, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x08, 0x73, 0x65, 0x71, 0x75, 0x65, 0x6e, 0x63, 0x65, 0x42, 0x15, 0x0a, 0x13, 0x5f, 0x65, 0x78,
+	0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x4e, 0x65, 0x78, 0x74, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74,
+	0x22, 0x6c, 0x0a, 0x0e, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x4d, 0x69, 0x73, 0x6d, 0x61, 0x74,
+	0x63, 0x68, 0x12, 0x2e, 0x0a, 0x12, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x4e, 0x65,
+	0x78, 0x74, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x12,
+	0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x4e, 0x65, 0x78, 0x74, 0x4f, 0x66, 0x66, 0x73,
+	0x65, 0x74, 0x12, 0x2a, 0x0a, 0x10, 0x61, 0x63, 0x74, 0x75, 0x61, 0x6c, 0x4e, 0x65, 0x78, 0x74,
+	0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x10, 0x61, 0x63,
+	0x74, 0x75, 0x61, 0x6c, 0x4e, 0x65, 0x78, 0x74, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x22, 0xa8,
+	0x01, 0x0a, 0x0a, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x18, 0x0a,
+	0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07,
+	0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x32, 0x0a, 0x07, 0x68, 0x65, 0x61,
+	0x64, 0x65, 0x72, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x49, 0x6e, 0x70,
+	0x75, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x2e, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x45,
+	0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x1a, 0x3a, 0x0a,
+	0x0c, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a,
+	0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12,
+	0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x23, 0x0a, 0x09, 0x54, 0x6f, 0x70,
+	0x69, 0x63, 0x4c, 0x69, 0x73, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x73,
+	0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x06, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x73, 0x22, 0xd4,
+	0x01, 0x0a, 0x05, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x16, 0x0a, 0x06, 0x6f, 0x66, 0x66, 0x73,
+	0x65, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74,
+	0x12, 0x18, 0x0a, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x07, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x74,
+	0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x2d, 0x0a, 0x07, 0x68, 0x65,
+	0x61, 0x64, 0x65, 0x72, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x13, 0x2e, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x2e, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x52, 0x07, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x1a, 0x3a, 0x0a, 0x0c, 0x48, 0x65, 0x61,
+	0x64, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75,
+	0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x52, 0x0a, 0x0c, 0x43, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x50,
+	0x61, 0x72, 0x61, 0x6d, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x12, 0x14, 0x0a, 0x05, 0x74,
+	0x6f, 0x70, 0x69, 0x63, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x6f, 0x70, 0x69,
+	0x63, 0x12, 0x16, 0x0a, 0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x22, 0x0e, 0x0a, 0x0c, 0x43, 0x6f, 0x6d,
+	0x6d, 0x69, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x22, 0x3f, 0x0a, 0x11, 0x46, 0x65, 0x74,
+	0x63, 0x68, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x12, 0x14,
+	0x0a, 0x05, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x67,
+	0x72, 0x6f, 0x75, 0x70, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x22, 0x47, 0x0a, 0x0f, 0x43, 0x6f,
+	0x6d, 0x6d, 0x69, 0x74, 0x74, 0x65, 0x64, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x12, 0x16, 0x0a,
+	0x06, 0x6f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x6f,
+	0x66, 0x66, 0x73, 0x65, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x74,
+	0x65, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74,
+	0x74, 0x65, 0x64, 0x22, 0x31, 0x0a, 0x0d, 0x4f, 0x75, 0x74, 0x70, 0x75, 0x74, 0x45, 0x6e, 0x74,
+	0x72, 0x69, 0x65, 0x73, 0x12, 0x20, 0x0a, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x18,
+	0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x06, 0x2e, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x65,
+	0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x22, 0x46, 0x0a, 0x0c, 0x54, 0x6f, 0x70, 0x69, 0x63, 0x45,
+	0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x12, 0x20, 0x0a, 0x07,
+	0x65, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x06, 0x2e,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x65, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x22, 0x26,
+	0x0a, 0x0e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73,
+	0x12, 0x14, 0x0a, 0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x74, 0x6f, 0x70, 0x69, 0x63, 0x22, 0x95, 0x02, 0x0a, 0x10, 0x54, 0x6f, 0x70, 0x69, 0x63,
+	0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x74,
+	0x6f, 0x70, 0x69, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x74, 0x6f, 0x70, 0x69,
+	0x63, 0x12, 0x20, 0x0a, 0x0b, 0x66, 0x69, 0x72, 0x73, 0x74, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0b, 0x66, 0x69, 0x72, 0x73, 0x74, 0x4f, 0x66, 0x66,
+	0x73, 0x65, 0x74, 0x12, 0x1e, 0x0a, 0x0a, 0x6e, 0x65, 0x78, 0x74, 0x4f, 0x66, 0x66, 0x73, 0x65,
+	0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0a, 0x6e, 0x65, 0x78, 0x74, 0x4f, 0x66, 0x66,
+	0x73, 0x65, 0x74, 0x12, 0x29, 0x0a, 0x06, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x18, 0x04, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x11, 0x2e, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x44, 0x65, 0x73, 0x63, 0x72,
+	0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x06, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x73, 0x12, 0x24,
+	0x0a, 0x0d, 0x68, 0x65, 0x61, 0x64, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x53, 0x69, 0x7a, 0x65, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0d, 0x68, 0x65, 0x61, 0x64, 0x42, 0x6c, 0x6f, 0x63, 0x6b,
+	0x53, 0x69, 0x7a, 0x65, 0x12, 0x32, 0x0a, 0x0d, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x50, 0x6f, 0x73,
+	0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x4c, 0x6f,
+	0x67, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0d, 0x69, 0x6e, 0x64, 0x65, 0x78,
+	0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x24, 0x0a, 0x0d, 0x6c, 0x61, 0x73, 0x74,
+	0x57, 0x72, 0x69, 0x74, 0x65, 0x54, 0x69, 0x6d, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x0d, 0x6c, 0x61, 0x73, 0x74, 0x57, 0x72, 0x69, 0x74, 0x65, 0x54, 0x69, 0x6d, 0x65, 0x22, 0x64,
+	0x0a, 0x10, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69,
+	0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x05, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x1a, 0x0a, 0x08, 0x62, 0x79, 0x74, 0x65,
+	0x53, 0x69, 0x7a, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x62, 0x79, 0x74, 0x65,
+	0x53, 0x69, 0x7a, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65, 0x73, 0x73,
+	0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0a, 0x63, 0x6f, 0x6d, 0x70, 0x72, 0x65,
+	0x73, 0x73, 0x65, 0x64, 0x22, 0x43, 0x0a, 0x0b, 0x4c, 0x6f, 0x67, 0x50, 0x6f, 0x73, 0x69, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x04, 0x52, 0x05, 0x62, 0x6c, 0x6f, 0x63, 0x6b, 0x12, 0x1e, 0x0a, 0x0a, 0x62, 0x79, 0x74,
+	0x65, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x62,
+	0x79, 0x74, 0x65, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x2a, 0x49, 0x0a, 0x0d, 0x53, 0x74, 0x61,
+	0x72, 0x74, 0x50, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x0a, 0x0a, 0x06, 0x4f, 0x46,
+	0x46, 0x53, 0x45, 0x54, 0x10, 0x00, 0x12, 0x0c, 0x0a, 0x08, 0x45, 0x41, 0x52, 0x4c, 0x49, 0x45,
+	0x53, 0x54, 0x10, 0x01, 0x12, 0x0a, 0x0a, 0x06, 0x4c, 0x41, 0x54, 0x45, 0x53, 0x54, 0x10, 0x02,
+	0x12, 0x12, 0x0a, 0x0e, 0x4c, 0x41, 0x54, 0x45, 0x53, 0x54, 0x5f, 0x4d, 0x49, 0x4e, 0x55, 0x53,
+	0x5f, 0x4e, 0x10, 0x03, 0x32, 0x82, 0x03, 0x0a, 0x05, 0x49, 0x62, 0x73, 0x65, 0x6e, 0x12, 0x26,
+	0x0a, 0x05, 0x77, 0x72, 0x69, 0x74, 0x65, 0x12, 0x0d, 0x2e, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x45,
+	0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x1a, 0x0c, 0x2e, 0x57, 0x72, 0x69, 0x74, 0x65, 0x53, 0x74,
+	0x61, 0x74, 0x75, 0x73, 0x22, 0x00, 0x12, 0x2d, 0x0a, 0x0b, 0x77, 0x72, 0x69, 0x74, 0x65, 0x53,
+	0x74, 0x72, 0x65, 0x61, 0x6d, 0x12, 0x0d, 0x2e, 0x49, 0x6e, 0x70, 0x75, 0x74, 0x45, 0x6e, 0x74,
+	0x72, 0x69, 0x65, 0x73, 0x1a, 0x09, 0x2e, 0x57, 0x72, 0x69, 0x74, 0x65, 0x41, 0x63, 0x6b, 0x22,
+	0x00, 0x28, 0x01, 0x30, 0x01, 0x12, 0x27, 0x0a, 0x04, 0x72, 0x65, 0x61, 0x64, 0x12, 0x0b, 0x2e,
+	0x52, 0x65, 0x61, 0x64, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x1a, 0x0e, 0x2e, 0x4f, 0x75, 0x74,
+	0x70, 0x75, 0x74, 0x45, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x22, 0x00, 0x30, 0x01, 0x12, 0x30,
+	0x0a, 0x09, 0x73, 0x75, 0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x12, 0x10, 0x2e, 0x53, 0x75,
+	0x62, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x1a, 0x0d, 0x2e,
+	0x54, 0x6f, 0x70, 0x69, 0x63, 0x45, 0x6e, 0x74, 0x72, 0x69, 0x65, 0x73, 0x22, 0x00, 0x30, 0x01,
+	0x12, 0x20, 0x0a, 0x04, 0x6c, 0x69, 0x73, 0x74, 0x12, 0x0a, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79,
+	0x41, 0x72, 0x67, 0x73, 0x1a, 0x0a, 0x2e, 0x54, 0x6f, 0x70, 0x69, 0x63, 0x4c, 0x69, 0x73, 0x74,
+	0x22, 0x00, 0x12, 0x2e, 0x0a, 0x0c, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x4f, 0x66, 0x66, 0x73,
+	0x65, 0x74, 0x12, 0x0d, 0x2e, 0x43, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x50, 0x61, 0x72, 0x61, 0x6d,
+	0x73, 0x1a, 0x0d, 0x2e, 0x43, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x22, 0x00, 0x12, 0x3e, 0x0a, 0x14, 0x66, 0x65, 0x74, 0x63, 0x68, 0x43, 0x6f, 0x6d, 0x6d, 0x69,
+	0x74, 0x74, 0x65, 0x64, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x12, 0x12, 0x2e, 0x46, 0x65, 0x74,
+	0x63, 0x68, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x1a, 0x10,
+	0x2e, 0x43, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x74, 0x65, 0x64, 0x4f, 0x66, 0x66, 0x73, 0x65, 0x74,
+	0x22, 0x00, 0x12, 0x35, 0x0a, 0x0d, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x54, 0x6f,
+	0x70, 0x69, 0x63, 0x12, 0x0f, 0x2e, 0x44, 0x65, 0x73, 0x63, 0x72, 0x69, 0x62, 0x65, 0x50, 0x61,
+	0x72, 0x61, 0x6d, 0x73, 0x1a, 0x11, 0x2e, 0x54, 0x6f, 0x70, 0x69, 0x63, 0x44, 0x65, 0x73, 0x63,
+	0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x00, 0x42, 0x41, 0x0a, 0x1b, 0x67, 0x69, 0x74,
+	0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2e, 0x74, 0x63, 0x77, 0x2e, 0x69, 0x62, 0x73, 0x65,
+	0x6e, 0x2e, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x42, 0x0a, 0x49, 0x62, 0x73, 0x65, 0x6e, 0x50,
+	0x72, 0x6f, 0x74, 0x6f, 0x50, 0x01, 0x5a, 0x0c, 0x2f, 0x61, 0x70, 0x69, 0x2f, 0x67, 0x72, 0x70,
+	0x63, 0x41, 0x70, 0x69, 0xa2, 0x02, 0x05, 0x49, 0x42, 0x53, 0x45, 0x4e, 0x62, 0x06, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_ibsen_proto_rawDescOnce sync.Once
+	file_ibsen_proto_rawDescData = file_ibsen_proto_rawDesc
+)
+
+func file_ibsen_proto_rawDescGZIP() []byte {
+	file_ibsen_proto_rawDescOnce.Do(func() {
+		file_ibsen_proto_rawDescData = protoimpl.X.CompressGZIP(file_ibsen_proto_rawDescData)
+	})
+	return file_ibsen_proto_rawDescData
+}
+
+var file_ibsen_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_ibsen_proto_msgTypes = make([]protoimpl.MessageInfo, 24)
+var file_ibsen_proto_goTypes = []interface{}{
+	(StartPosition)(0),        // 0: StartPosition
+	(*EmptyArgs)(nil),         // 1: EmptyArgs
+	(*WriteStatus)(nil),       // 2: WriteStatus
+	(*WriteAck)(nil),          // 3: WriteAck
+	(*ReadParams)(nil),        // 4: ReadParams
+	(*EntryFilter)(nil),       // 5: EntryFilter
+	(*JsonFieldFilter)(nil),   // 6: JsonFieldFilter
+	(*SubscribeParams)(nil),   // 7: SubscribeParams
+	(*InputEntries)(nil),      // 8: InputEntries
+	(*OffsetMismatch)(nil),    // 9: OffsetMismatch
+	(*InputEntry)(nil),        // 10: InputEntry
+	(*TopicList)(nil),         // 11: TopicList
+	(*Entry)(nil),             // 12: Entry
+	(*CommitParams)(nil),      // 13: CommitParams
+	(*CommitStatus)(nil),      // 14: CommitStatus
+	(*FetchOffsetParams)(nil), // 15: FetchOffsetParams
+	(*CommittedOffset)(nil),   // 16: CommittedOffset
+	(*OutputEntries)(nil),     // 17: OutputEntries
+	(*TopicEntries)(nil),      // 18: TopicEntries
+	(*DescribeParams)(nil),    // 19: DescribeParams
+	(*TopicDescription)(nil),  // 20: TopicDescription
+	(*BlockDescription)(nil),  // 21: BlockDescription
+	(*LogPosition)(nil),       // 22: LogPosition
+	nil,                       // 23: InputEntry.HeadersEntry
+	nil,                       // 24: Entry.HeadersEntry
+}
+var file_ibsen_proto_depIdxs = []int32{
+	2,  // 0: WriteAck.status:type_name -> WriteStatus
+	0,  // 1: ReadParams.startPosition:type_name -> StartPosition
+	5,  // 2: ReadParams.filters:type_name -> EntryFilter
+	6,  // 3: EntryFilter.jsonField:type_name -> JsonFieldFilter
+	0,  // 4: SubscribeParams.startPosition:type_name -> StartPosition
+	10, // 5: InputEntries.records:type_name -> InputEntry
+	23, // 6: InputEntry.headers:type_name -> InputEntry.HeadersEntry
+	24, // 7: Entry.headers:type_name -> Entry.HeadersEntry
+	12, // 8: OutputEntries.entries:type_name -> Entry
+	12, // 9: TopicEntries.entries:type_name -> Entry
+	21, // 10: TopicDescription.blocks:type_name -> BlockDescription
+	22, // 11: TopicDescription.indexPosition:type_name -> LogPosition
+	8,  // 12: Ibsen.write:input_type -> InputEntries
+	8,  // 13: Ibsen.writeStream:input_type -> InputEntries
+	4,  // 14: Ibsen.read:input_type -> ReadParams
+	7,  // 15: Ibsen.subscribe:input_type -> SubscribeParams
+	1,  // 16: Ibsen.list:input_type -> EmptyArgs
+	13, // 17: Ibsen.commitOffset:input_type -> CommitParams
+	15, // 18: Ibsen.fetchCommittedOffset:input_type -> FetchOffsetParams
+	19, // 19: Ibsen.describeTopic:input_type -> DescribeParams
+	2,  // 20: Ibsen.write:output_type -> WriteStatus
+	3,  // 21: Ibsen.writeStream:output_type -> WriteAck
+	17, // 22: Ibsen.read:output_type -> OutputEntries
+	18, // 23: Ibsen.subscribe:output_type -> TopicEntries
+	11, // 24: Ibsen.list:output_type -> TopicList
+	14, // 25: Ibsen.commitOffset:output_type -> CommitStatus
+	16, // 26: Ibsen.fetchCommittedOffset:output_type -> CommittedOffset
+	20, // 27: Ibsen.describeTopic:output_type -> TopicDescription
+	20, // [20:28] is the sub-list for method output_type
+	12, // [12:20] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
+}
+
+func init() { file_ibsen_proto_init() }
+func file_ibsen_proto_init() {
+	if File_ibsen_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_ibsen_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*EmptyArgs); i {
+			case 0:
+				return &v.state
 			case 1:
 				return &v.sizeCache
 			case 2:
@@ -491,7 +1862,7 @@ func file_ibsen_proto_init() {
 			}
 		}
 		file_ibsen_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*ReadParams); i {
+			switch v := v.(*WriteAck); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -503,7 +1874,7 @@ func file_ibsen_proto_init() {
 			}
 		}
 		file_ibsen_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*InputEntries); i {
+			switch v := v.(*ReadParams); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -515,7 +1886,7 @@ func file_ibsen_proto_init() {
 			}
 		}
 		file_ibsen_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*TopicList); i {
+			switch v := v.(*EntryFilter); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -527,7 +1898,7 @@ func file_ibsen_proto_init() {
 			}
 		}
 		file_ibsen_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Entry); i {
+			switch v := v.(*JsonFieldFilter); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -539,6 +1910,126 @@ func file_ibsen_proto_init() {
 			}
 		}
 		file_ibsen_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SubscribeParams); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ibsen_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*InputEntries); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ibsen_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*OffsetMismatch); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ibsen_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*InputEntry); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ibsen_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TopicList); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ibsen_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Entry); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ibsen_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CommitParams); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ibsen_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CommitStatus); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ibsen_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*FetchOffsetParams); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ibsen_proto_msgTypes[15].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CommittedOffset); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ibsen_proto_msgTypes[16].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*OutputEntries); i {
 			case 0:
 				return &v.state
@@ -550,19 +2041,87 @@ func file_ibsen_proto_init() {
 				return nil
 			}
 		}
+		file_ibsen_proto_msgTypes[17].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TopicEntries); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ibsen_proto_msgTypes[18].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DescribeParams); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ibsen_proto_msgTypes[19].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TopicDescription); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ibsen_proto_msgTypes[20].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BlockDescription); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_ibsen_proto_msgTypes[21].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LogPosition); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_ibsen_proto_msgTypes[4].OneofWrappers = []interface{}{
+		(*EntryFilter_Prefix)(nil),
+		(*EntryFilter_Contains)(nil),
+		(*EntryFilter_Regex)(nil),
+		(*EntryFilter_JsonField)(nil),
 	}
+	file_ibsen_proto_msgTypes[7].OneofWrappers = []interface{}{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_ibsen_proto_rawDesc,
-			NumEnums:      0,
-			NumMessages:   7,
+			NumEnums:      1,
+			NumMessages:   24,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_ibsen_proto_goTypes,
 		DependencyIndexes: file_ibsen_proto_depIdxs,
+		EnumInfos:         file_ibsen_proto_enumTypes,
 		MessageInfos:      file_ibsen_proto_msgTypes,
 	}.Build()
 	File_ibsen_proto = out.File
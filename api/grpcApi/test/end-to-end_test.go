@@ -2,9 +2,12 @@ package test
 
 import (
 	"context"
+	"fmt"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/tcw/ibsen/api/grpcApi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"io"
 	"testing"
 	"time"
@@ -38,6 +41,9 @@ func newMemMapFs() *afero.Afero {
 }
 
 func TestReadWriteLargeObject(t *testing.T) {
+	if raceEnabled {
+		t.Skip("500MB entry does not fit in memory with the race detector")
+	}
 	afs := newMemMapFs()
 	go startGrpcServer(afs, "/tmp/data")
 	numberOfEntries := 1
@@ -69,6 +75,47 @@ func TestReadWriteVerification(t *testing.T) {
 	ibsenServer.Shutdown()
 }
 
+func TestReadFollowsWrites(t *testing.T) {
+	afs := newMemMapFs()
+	go startGrpcServer(afs, "/tmp/data")
+	client, err := newIbsenClient(ibsenTestTarge)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	entryStream, err := client.Client.Read(ctx, &grpcApi.ReadParams{
+		StopOnCompletion: false,
+		Topic:            "test",
+		Offset:           0,
+		BatchSize:        100,
+	})
+	assert.Nil(t, err)
+	received := make(chan []*grpcApi.Entry)
+	go func() {
+		for {
+			in, err := entryStream.Recv()
+			if err != nil {
+				close(received)
+				return
+			}
+			received <- in.Entries
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		// the reader is idle waiting for entries when they are written
+		time.Sleep(50 * time.Millisecond)
+		err = write("test", 10, 100)
+		assert.Nil(t, err)
+		select {
+		case entries := <-received:
+			assert.Equal(t, 10, len(entries))
+			assert.Equal(t, uint64(i*10), entries[0].Offset)
+		case <-time.After(time.Second):
+			t.Fatalf("following reader did not get entries written in batch %d", i)
+		}
+	}
+	ibsenServer.Shutdown()
+}
+
 // Todo: fix
 //func TestReadWriteWithOffsetVerification(t *testing.T) {
 //	afs := newMemMapFs()
@@ -93,7 +140,8 @@ func list() (*grpcApi.TopicList, error) {
 	if err != nil {
 		return nil, err
 	}
-	ctx, _ := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 	if ctx.Err() == context.Canceled {
 		return nil, ctx.Err()
 	}
@@ -104,7 +152,8 @@ func writeLarge(topic string, numberOfEntries int, entryKb int) (int, error) {
 	if err != nil {
 		return 0, err
 	}
-	ctx, _ := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 	if ctx.Err() == context.Canceled {
 		return 0, ctx.Err()
 	}
@@ -118,7 +167,8 @@ func write(topic string, numberOfEntries int, entryByteSize int) error {
 	if err != nil {
 		return err
 	}
-	ctx, _ := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 	entries := createInputEntries(topic, numberOfEntries, entryByteSize)
 	_, err = client.Client.Write(ctx, &entries)
 	if err != nil {
@@ -132,7 +182,8 @@ func read(topic string, offset uint64, batchSize uint32) ([]*grpcApi.Entry, erro
 	if err != nil {
 		return nil, err
 	}
-	ctx, _ := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 	if ctx.Err() == context.Canceled {
 		return nil, ctx.Err()
 	}
@@ -157,3 +208,465 @@ func read(topic string, offset uint64, batchSize uint32) ([]*grpcApi.Entry, erro
 		entries = append(entries, in.Entries...)
 	}
 }
+
+func TestConsumerGroupResumesFromCommittedOffset(t *testing.T) {
+	afs := newMemMapFs()
+	go startGrpcServer(afs, "/tmp/data")
+	err := write("test", 100, 10)
+	assert.Nil(t, err)
+	client, err := newIbsenClient(ibsenTestTarge)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	fetched, err := client.Client.FetchCommittedOffset(ctx, &grpcApi.FetchOffsetParams{Group: "group1", Topic: "test"})
+	assert.Nil(t, err)
+	assert.False(t, fetched.Committed)
+	_, err = client.Client.CommitOffset(ctx, &grpcApi.CommitParams{Group: "group1", Topic: "test", Offset: 40})
+	assert.Nil(t, err)
+	fetched, err = client.Client.FetchCommittedOffset(ctx, &grpcApi.FetchOffsetParams{Group: "group1", Topic: "test"})
+	assert.Nil(t, err)
+	assert.True(t, fetched.Committed)
+	assert.Equal(t, uint64(40), fetched.Offset)
+
+	entryStream, err := client.Client.Read(ctx, &grpcApi.ReadParams{
+		StopOnCompletion: true,
+		Topic:            "test",
+		BatchSize:        100,
+		Group:            "group1",
+	})
+	assert.Nil(t, err)
+	var entries []*grpcApi.Entry
+	for {
+		in, err := entryStream.Recv()
+		if err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+		entries = append(entries, in.Entries...)
+	}
+	assert.Equal(t, 60, len(entries))
+	assert.Equal(t, uint64(40), entries[0].Offset)
+
+	_, err = client.Client.CommitOffset(ctx, &grpcApi.CommitParams{Topic: "test", Offset: 40})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.Client.Write(ctx, &grpcApi.InputEntries{Topic: ".consumer_offsets", Entries: [][]byte{[]byte("forged")}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	topics, err := list()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"test"}, topics.Topics)
+	ibsenServer.Shutdown()
+}
+
+func TestBoundedRead(t *testing.T) {
+	afs := newMemMapFs()
+	go startGrpcServer(afs, "/tmp/data")
+	err := write("test", 100, 10)
+	assert.Nil(t, err)
+
+	entries, err := readBounded(&grpcApi.ReadParams{StopOnCompletion: true, Topic: "test", Offset: 20, EndOffset: 50, BatchSize: 7})
+	assert.Nil(t, err)
+	assert.Equal(t, 30, len(entries))
+	assert.Equal(t, uint64(49), entries[len(entries)-1].Offset)
+	entries, err = readBounded(&grpcApi.ReadParams{StopOnCompletion: true, Topic: "test", Offset: 10, MaxEntries: 25, BatchSize: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, 25, len(entries))
+	assert.Equal(t, uint64(10), entries[0].Offset)
+	entries, err = readBounded(&grpcApi.ReadParams{StopOnCompletion: true, Topic: "test", MaxBytes: 95, BatchSize: 100})
+	assert.Nil(t, err)
+	// the entry reaching max bytes is the last one read, test values can have multibyte characters
+	bytes := 0
+	for _, entry := range entries[:len(entries)-1] {
+		bytes = bytes + len(entry.Content)
+	}
+	assert.Less(t, bytes, 95)
+	assert.GreaterOrEqual(t, bytes+len(entries[len(entries)-1].Content), 95)
+
+	// a following read ends at the end offset, even though more entries are written
+	done := make(chan []*grpcApi.Entry)
+	go func() {
+		entries, err := readBounded(&grpcApi.ReadParams{Topic: "test", Offset: 90, EndOffset: 120, BatchSize: 100})
+		assert.Nil(t, err)
+		done <- entries
+	}()
+	for i := 0; i < 4; i++ {
+		time.Sleep(20 * time.Millisecond)
+		err = write("test", 10, 10)
+		assert.Nil(t, err)
+	}
+	select {
+	case entries := <-done:
+		assert.Equal(t, 30, len(entries))
+		assert.Equal(t, uint64(119), entries[len(entries)-1].Offset)
+	case <-time.After(5 * time.Second):
+		t.Fatal("following read did not end at the end offset")
+	}
+	ibsenServer.Shutdown()
+}
+
+func readBounded(params *grpcApi.ReadParams) ([]*grpcApi.Entry, error) {
+	client, err := newIbsenClient(ibsenTestTarge)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	entryStream, err := client.Client.Read(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	var entries []*grpcApi.Entry
+	for {
+		in, err := entryStream.Recv()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, in.Entries...)
+	}
+}
+
+func TestReadStartPositions(t *testing.T) {
+	afs := newMemMapFs()
+	go startGrpcServer(afs, "/tmp/data")
+	err := write("test", 100, 10)
+	assert.Nil(t, err)
+
+	entries, err := readBounded(&grpcApi.ReadParams{StopOnCompletion: true, Topic: "test", Offset: 50, StartPosition: grpcApi.StartPosition_EARLIEST, BatchSize: 100})
+	assert.Nil(t, err)
+	assert.Equal(t, 100, len(entries))
+	assert.Equal(t, uint64(0), entries[0].Offset)
+	entries, err = readBounded(&grpcApi.ReadParams{StopOnCompletion: true, Topic: "test", Offset: 10, StartPosition: grpcApi.StartPosition_LATEST_MINUS_N, BatchSize: 100})
+	assert.Nil(t, err)
+	assert.Equal(t, 10, len(entries))
+	assert.Equal(t, uint64(90), entries[0].Offset)
+	entries, err = readBounded(&grpcApi.ReadParams{StopOnCompletion: true, Topic: "test", Offset: 1000, StartPosition: grpcApi.StartPosition_LATEST_MINUS_N, BatchSize: 100})
+	assert.Nil(t, err)
+	assert.Equal(t, 100, len(entries))
+	_, err = readBounded(&grpcApi.ReadParams{StopOnCompletion: true, Topic: "test", StartPosition: 42})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// a following read from latest only gets entries written after it started
+	done := make(chan []*grpcApi.Entry)
+	go func() {
+		entries, err := readBounded(&grpcApi.ReadParams{Topic: "test", StartPosition: grpcApi.StartPosition_LATEST, MaxEntries: 10, BatchSize: 100})
+		assert.Nil(t, err)
+		done <- entries
+	}()
+	time.Sleep(50 * time.Millisecond)
+	err = write("test", 10, 10)
+	assert.Nil(t, err)
+	select {
+	case entries := <-done:
+		assert.Equal(t, 10, len(entries))
+		assert.Equal(t, uint64(100), entries[0].Offset)
+	case <-time.After(5 * time.Second):
+		t.Fatal("following read from latest did not get the entries written")
+	}
+	ibsenServer.Shutdown()
+}
+
+func TestDescribeTopic(t *testing.T) {
+	afs := newMemMapFs()
+	go startGrpcServer(afs, "/tmp/data")
+	err := write("test", 100, 10)
+	assert.Nil(t, err)
+	client, err := newIbsenClient(ibsenTestTarge)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	description, err := client.Client.DescribeTopic(ctx, &grpcApi.DescribeParams{Topic: "test"})
+	assert.Nil(t, err)
+	assert.Equal(t, "test", description.Topic)
+	assert.Equal(t, uint64(0), description.FirstOffset)
+	assert.Equal(t, uint64(100), description.NextOffset)
+	assert.Equal(t, 1, len(description.Blocks))
+	assert.Equal(t, description.HeadBlockSize, description.Blocks[0].ByteSize)
+	assert.Greater(t, description.LastWriteTime, int64(0))
+
+	_, err = client.Client.DescribeTopic(ctx, &grpcApi.DescribeParams{Topic: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	topics, err := list()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"test"}, topics.Topics)
+	ibsenServer.Shutdown()
+}
+
+func TestWriteReturnsAssignedOffsets(t *testing.T) {
+	afs := newMemMapFs()
+	go startGrpcServer(afs, "/tmp/data")
+	client, err := newIbsenClient(ibsenTestTarge)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for i := 0; i < 3; i++ {
+		entries := createInputEntries("test", 10, 10)
+		wrote, err := client.Client.Write(ctx, &entries)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(10), wrote.Wrote)
+		assert.Equal(t, uint64(i*10), wrote.FirstOffset)
+		assert.Equal(t, uint64(i*10+9), wrote.LastOffset)
+	}
+	wrote, err := client.Client.Write(ctx, &grpcApi.InputEntries{Topic: "test"})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), wrote.Wrote)
+	assert.Equal(t, uint64(0), wrote.LastOffset)
+	ibsenServer.Shutdown()
+}
+
+func TestConditionalWrite(t *testing.T) {
+	afs := newMemMapFs()
+	go startGrpcServer(afs, "/tmp/data")
+	client, err := newIbsenClient(ibsenTestTarge)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	expected := uint64(0)
+	wrote, err := client.Client.Write(ctx, &grpcApi.InputEntries{Topic: "test", Entries: [][]byte{[]byte("created")}, ExpectedNextOffset: &expected})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), wrote.FirstOffset)
+	_, err = client.Client.Write(ctx, &grpcApi.InputEntries{Topic: "test", Entries: [][]byte{[]byte("stale")}, ExpectedNextOffset: &expected})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	details := status.Convert(err).Details()
+	assert.Equal(t, 1, len(details))
+	mismatch, ok := details[0].(*grpcApi.OffsetMismatch)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), mismatch.ActualNextOffset)
+
+	expected = mismatch.ActualNextOffset
+	wrote, err = client.Client.Write(ctx, &grpcApi.InputEntries{Topic: "test", Entries: [][]byte{[]byte("updated")}, ExpectedNextOffset: &expected})
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), wrote.FirstOffset)
+	entries, err := read("test", 0, 10)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "updated", string(entries[1].Content))
+	ibsenServer.Shutdown()
+}
+
+func TestIdempotentProducerRetries(t *testing.T) {
+	afs := newMemMapFs()
+	go startGrpcServer(afs, "/tmp/data")
+	client, err := newIbsenClient(ibsenTestTarge)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	batch := createInputEntries("test", 10, 10)
+	batch.ProducerId = "producer1"
+	batch.Sequence = 1
+	wrote, err := client.Client.Write(ctx, &batch)
+	assert.Nil(t, err)
+	assert.False(t, wrote.Duplicate)
+	// retried after a timeout, when the first write landed
+	retried, err := client.Client.Write(ctx, &batch)
+	assert.Nil(t, err)
+	assert.True(t, retried.Duplicate)
+	assert.Equal(t, wrote.FirstOffset, retried.FirstOffset)
+	assert.Equal(t, wrote.LastOffset, retried.LastOffset)
+
+	batch.Sequence = 2
+	wrote, err = client.Client.Write(ctx, &batch)
+	assert.Nil(t, err)
+	assert.False(t, wrote.Duplicate)
+	assert.Equal(t, uint64(10), wrote.FirstOffset)
+	entries, err := read("test", 0, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, 20, len(entries))
+	ibsenServer.Shutdown()
+}
+
+func TestWriteStream(t *testing.T) {
+	afs := newMemMapFs()
+	go startGrpcServer(afs, "/tmp/data")
+	client, err := newIbsenClient(ibsenTestTarge)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	stream, err := client.Client.WriteStream(ctx)
+	assert.Nil(t, err)
+	acks := make(chan []*grpcApi.WriteAck)
+	go func() {
+		var received []*grpcApi.WriteAck
+		for {
+			ack, err := stream.Recv()
+			if err != nil {
+				assert.Equal(t, io.EOF, err)
+				acks <- received
+				return
+			}
+			received = append(received, ack)
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		batch := createInputEntries("test", 10, 10)
+		err = stream.Send(&batch)
+		assert.Nil(t, err)
+	}
+	err = stream.CloseSend()
+	assert.Nil(t, err)
+	received := <-acks
+	assert.Equal(t, 50, len(received))
+	for i, ack := range received {
+		assert.Equal(t, uint64(i), ack.Batch)
+		assert.Equal(t, int64(10), ack.Status.Wrote)
+		assert.Equal(t, uint64(i*10), ack.Status.FirstOffset)
+	}
+
+	// the stream ends with the error of the first failed batch, later batches are not written
+	stream, err = client.Client.WriteStream(ctx)
+	assert.Nil(t, err)
+	stale := uint64(0)
+	batch := createInputEntries("test", 10, 10)
+	batch.ExpectedNextOffset = &stale
+	err = stream.Send(&batch)
+	assert.Nil(t, err)
+	batch = createInputEntries("test", 10, 10)
+	_ = stream.Send(&batch)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	entries, err := read("test", 0, 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, 500, len(entries))
+	ibsenServer.Shutdown()
+}
+
+func TestSubscribePicksUpNewTopics(t *testing.T) {
+	afs := newMemMapFs()
+	go startGrpcServer(afs, "/tmp/data")
+	err := write("orders.eu", 10, 10)
+	assert.Nil(t, err)
+	err = write("other", 5, 10)
+	assert.Nil(t, err)
+	client, err := newIbsenClient(ibsenTestTarge)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	invalid, err := client.Client.Subscribe(ctx, &grpcApi.SubscribeParams{Pattern: "orders.["})
+	assert.Nil(t, err)
+	_, err = invalid.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	stream, err := client.Client.Subscribe(ctx, &grpcApi.SubscribeParams{
+		Topics:    []string{"audit"},
+		Pattern:   "orders.*",
+		BatchSize: 100,
+	})
+	assert.Nil(t, err)
+	received := make(chan map[string][]uint64)
+	go func() {
+		offsets := map[string][]uint64{}
+		for total := 0; total < 18; {
+			topicEntries, err := stream.Recv()
+			if err != nil {
+				break
+			}
+			for _, entry := range topicEntries.Entries {
+				offsets[topicEntries.Topic] = append(offsets[topicEntries.Topic], entry.Offset)
+				total++
+			}
+		}
+		received <- offsets
+	}()
+	time.Sleep(50 * time.Millisecond)
+	// created after the subscription started
+	err = write("orders.us", 5, 10)
+	assert.Nil(t, err)
+	err = write("audit", 3, 10)
+	assert.Nil(t, err)
+	err = write("other", 5, 10)
+	assert.Nil(t, err)
+	select {
+	case offsets := <-received:
+		assert.Equal(t, 3, len(offsets))
+		assert.Equal(t, 10, len(offsets["orders.eu"]))
+		assert.Equal(t, []uint64{0, 1, 2, 3, 4}, offsets["orders.us"])
+		assert.Equal(t, []uint64{0, 1, 2}, offsets["audit"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription did not get the entries of new topics")
+	}
+	ibsenServer.Shutdown()
+}
+
+func TestFilteredRead(t *testing.T) {
+	afs := newMemMapFs()
+	go startGrpcServer(afs, "/tmp/data")
+	client, err := newIbsenClient(ibsenTestTarge)
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	writeTenants := func(from int) {
+		var entries [][]byte
+		for i := from; i < from+100; i++ {
+			tenant := "other"
+			if i%10 == 0 {
+				tenant = "acme"
+			}
+			entries = append(entries, []byte(fmt.Sprintf(`{"tenant":"%s","n":%d}`, tenant, i)))
+		}
+		_, err := client.Client.Write(ctx, &grpcApi.InputEntries{Topic: "test", Entries: entries})
+		assert.Nil(t, err)
+	}
+	writeTenants(0)
+	acme := []*grpcApi.EntryFilter{{Filter: &grpcApi.EntryFilter_JsonField{
+		JsonField: &grpcApi.JsonFieldFilter{Field: "tenant", Value: "acme"},
+	}}}
+
+	// batches are filled with matching entries
+	entryStream, err := client.Client.Read(ctx, &grpcApi.ReadParams{Topic: "test", StopOnCompletion: true, BatchSize: 4, Filters: acme})
+	assert.Nil(t, err)
+	var batchSizes []int
+	var offsets []uint64
+	for {
+		in, err := entryStream.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		batchSizes = append(batchSizes, len(in.Entries))
+		for _, entry := range in.Entries {
+			offsets = append(offsets, entry.Offset)
+		}
+	}
+	assert.Equal(t, []int{4, 4, 2}, batchSizes)
+	assert.Equal(t, []uint64{0, 10, 20, 30, 40, 50, 60, 70, 80, 90}, offsets)
+
+	entries, err := readBounded(&grpcApi.ReadParams{Topic: "test", StopOnCompletion: true, BatchSize: 100, Filters: []*grpcApi.EntryFilter{
+		{Filter: &grpcApi.EntryFilter_Prefix{Prefix: []byte(`{"tenant":"other"`)}},
+		{Filter: &grpcApi.EntryFilter_Regex{Regex: `"n":9[0-9]`}},
+	}})
+	assert.Nil(t, err)
+	assert.Equal(t, 9, len(entries))
+
+	// limits count the entries sent
+	done := make(chan []*grpcApi.Entry)
+	go func() {
+		entries, err := readBounded(&grpcApi.ReadParams{Topic: "test", BatchSize: 100, MaxEntries: 12, Filters: acme})
+		assert.Nil(t, err)
+		done <- entries
+	}()
+	time.Sleep(50 * time.Millisecond)
+	writeTenants(100)
+	select {
+	case entries := <-done:
+		assert.Equal(t, 12, len(entries))
+		assert.Equal(t, uint64(110), entries[11].Offset)
+	case <-time.After(5 * time.Second):
+		t.Fatal("following filtered read did not get the entries written")
+	}
+
+	_, err = readBounded(&grpcApi.ReadParams{Topic: "test", StopOnCompletion: true, Filters: []*grpcApi.EntryFilter{
+		{Filter: &grpcApi.EntryFilter_Regex{Regex: "("}},
+	}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = readBounded(&grpcApi.ReadParams{Topic: "test", StopOnCompletion: true, Filters: []*grpcApi.EntryFilter{{}}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	ibsenServer.Shutdown()
+}
@@ -0,0 +1,6 @@
+//go:build race
+
+package test
+
+// the race detector multiplies memory use, tests writing very large entries are skipped
+const raceEnabled = true
@@ -29,18 +29,17 @@ func startGrpcServer(afs *afero.Afero, rootPath string) {
 		log.Fatal().Err(err)
 	}
 	params := manager.LogTopicManagerParams{
-		ReadOnly:         false,
-		Afs:              afs,
-		TTL:              5 * time.Second,
-		CheckForNewEvery: 100 * time.Millisecond,
-		MaxBlockSize:     10,
-		RootPath:         rootPath,
+		ReadOnly:     false,
+		Afs:          afs,
+		TTL:          5 * time.Second,
+		MaxBlockSize: 10,
+		RootPath:     rootPath,
 	}
 	topicsManager, err := manager.NewLogTopicsManager(params)
 	if err != nil {
 		log.Fatal().Err(err)
 	}
-	ibsenServer = grpcApi.NewUnsecureIbsenGrpcServer(&topicsManager, params.TTL, params.CheckForNewEvery)
+	ibsenServer = grpcApi.NewUnsecureIbsenGrpcServer(&topicsManager, params.TTL)
 	lis, err := net.Listen("tcp", ibsenTestTarge)
 	if err != nil {
 		log.Fatal().Err(err)
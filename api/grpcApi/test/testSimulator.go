@@ -120,8 +120,8 @@ func (s *Simulation) stop() {
 }
 
 func (u *User) run(t *testing.T, wg *sync.WaitGroup, cancel chan bool) {
+	wg.Add(1)
 	go func(wg *sync.WaitGroup, cancel chan bool) {
-		wg.Add(1)
 		topics := u.topics.topics
 		readersStarted := false
 
@@ -148,7 +148,8 @@ func (u *User) run(t *testing.T, wg *sync.WaitGroup, cancel chan bool) {
 }
 
 func (u *User) write(t *testing.T) {
-	ctx, _ := context.WithTimeout(context.Background(), 30*time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
 	numberOfEntries := u.params.entries.value()
 	randTopic := u.topics.randTopic()
 	entryByteSize := 100
@@ -162,7 +163,8 @@ func (u *User) write(t *testing.T) {
 }
 
 func (u *User) read(t *testing.T, topic string) {
-	ctx, _ := context.WithTimeout(context.Background(), 30*time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
 	var offset uint64 = 0
 	entryStream, err := u.ibsenClient.Client.Read(ctx, &grpcApi.ReadParams{
 		StopOnCompletion: false,
@@ -42,6 +42,30 @@ func TestName(t *testing.T) {
 	simulation.start(t)
 	//stopCpuPprof(err, file)
 	//memProfile()
+	ibsenServer.Shutdown()
+}
+
+func TestConcurrentReadersAndWriters(t *testing.T) {
+	afs = newMemMapFs()
+	go startGrpcServer(afs, "/tmp/data")
+	params := SimulationParams{
+		topics:       2,
+		users:        12,
+		dataLimit:    2 * 1024 * 1024,
+		testDuration: time.Second * 2,
+		writeDelay: RandomizedTimeInterval{
+			min: time.Millisecond * 1,
+			max: time.Millisecond * 5,
+		},
+		entries: RandomizedSizeInterval{
+			min: 1,
+			max: 1000,
+		},
+	}
+	simulation, err := newSimulation(params)
+	assert.Nil(t, err)
+	simulation.start(t)
+	ibsenServer.Shutdown()
 }
 
 func stopCpuPprof(err error, file *os.File) {
@@ -0,0 +1,472 @@
+package httpApi
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"github.com/tcw/ibsen/api/grpcApi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The HTTP gateway maps REST endpoints onto the Ibsen service, for clients that can not use gRPC
+//
+//	GET  /topics          lists the topics
+//	POST /topics/{topic}  writes one entry for each line of the body, or each element of a JSON array
+//	GET  /topics/{topic}  reads entries as Server-Sent Events, or as NDJSON
+//
+// Reads take the fields of ReadParams as query parameters, and follow the topic unless stopOnCompletion
+// is true, like gRPC reads. Entries are read as Server-Sent Events when the client accepts
+// text/event-stream, the id of each event is the offset of the entry, so an EventSource that reconnects
+// continues after the last entry it received. Content and keys of entries are read as text, bytes that
+// are not valid UTF-8 are replaced. Reads with encoding=base64 get content and keys base64 encoded, and
+// are lossless for any payload.
+//
+// The body of a write is at most maxBodySize bytes, larger bodies are rejected with 413.
+
+const topicsPath = "/topics"
+
+type IbsenHttpServer struct {
+	service     grpcApi.IbsenServer
+	maxBodySize int64
+	HttpServer  *http.Server
+}
+
+func NewIbsenHttpServer(service grpcApi.IbsenServer, maxBodySize int64) *IbsenHttpServer {
+	gateway := &IbsenHttpServer{
+		service:     service,
+		maxBodySize: maxBodySize,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(topicsPath, gateway.handleTopics)
+	mux.HandleFunc(topicsPath+"/", gateway.handleTopic)
+	gateway.HttpServer = &http.Server{Handler: mux}
+	return gateway
+}
+
+// Start serves http requests until the server is shut down
+func (s *IbsenHttpServer) Start(listener net.Listener) error {
+	err := s.HttpServer.Serve(listener)
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Shutdown waits for requests in progress until ctx is done, and closes the connections still open
+func (s *IbsenHttpServer) Shutdown(ctx context.Context) {
+	err := s.HttpServer.Shutdown(ctx)
+	if err != nil {
+		// reads following a topic are only ended by their ttl
+		closeErr := s.HttpServer.Close()
+		if closeErr != nil {
+			log.Err(closeErr).Msg("unable to close http connections")
+		}
+	}
+}
+
+func (s *IbsenHttpServer) handleTopics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return
+	}
+	list, err := s.service.List(r.Context(), &grpcApi.EmptyArgs{})
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+	topics := list.Topics
+	if topics == nil {
+		topics = []string{}
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"topics": topics})
+}
+
+func (s *IbsenHttpServer) handleTopic(w http.ResponseWriter, r *http.Request) {
+	topic := strings.TrimPrefix(r.URL.Path, topicsPath+"/")
+	if topic == "" || strings.Contains(topic, "/") {
+		writeError(w, http.StatusNotFound, "no topic in path %s", r.URL.Path)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		s.write(w, r, topic)
+	case http.MethodGet:
+		s.read(w, r, topic)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+	}
+}
+
+// write writes the body as one batch. A JSON array is written as one entry for each element, strings
+// with their content and other values with their JSON text. Any other body is written as one entry for
+// each line that is not empty.
+func (s *IbsenHttpServer) write(w http.ResponseWriter, r *http.Request, topic string) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.maxBodySize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, "body is larger than %d bytes", tooLarge.Limit)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "unable to read body: %s", err)
+		return
+	}
+	var entries [][]byte
+	if isJSONContent(r.Header.Get("Content-Type")) {
+		entries, err = jsonArrayEntries(body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "body is not a JSON array: %s", err)
+			return
+		}
+	} else {
+		entries = lineEntries(body)
+	}
+	input := &grpcApi.InputEntries{
+		Topic:   topic,
+		Entries: entries,
+	}
+	query := r.URL.Query()
+	if query.Has("expectedNextOffset") {
+		expected, err := strconv.ParseUint(query.Get("expectedNextOffset"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "expectedNextOffset %s is not a uint64", query.Get("expectedNextOffset"))
+			return
+		}
+		input.ExpectedNextOffset = &expected
+	}
+	if query.Has("producerId") {
+		input.ProducerId = query.Get("producerId")
+		input.Sequence, err = strconv.ParseUint(query.Get("sequence"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "sequence %s is not a uint64", query.Get("sequence"))
+			return
+		}
+	}
+	written, err := s.service.Write(r.Context(), input)
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, writeStatus{
+		Wrote:       written.Wrote,
+		FirstOffset: written.FirstOffset,
+		LastOffset:  written.LastOffset,
+		Duplicate:   written.Duplicate,
+	})
+}
+
+func (s *IbsenHttpServer) read(w http.ResponseWriter, r *http.Request, topic string) {
+	params, err := readParams(r, topic)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%s", err)
+		return
+	}
+	encoding := r.URL.Query().Get("encoding")
+	if encoding != "" && encoding != "text" && encoding != "base64" {
+		writeError(w, http.StatusBadRequest, "unknown encoding %s, use text or base64", encoding)
+		return
+	}
+	flusher, canFlush := w.(http.Flusher)
+	if !canFlush {
+		writeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	stream := &readStream{
+		ctx:     r.Context(),
+		writer:  w,
+		flusher: flusher,
+		sse:     strings.Contains(r.Header.Get("Accept"), "text/event-stream"),
+		base64:  encoding == "base64",
+	}
+	err = s.service.Read(params, stream)
+	if err != nil && !stream.started {
+		writeStatusError(w, err)
+		return
+	}
+	if err != nil {
+		stream.writeError(err)
+		return
+	}
+	// an empty read is a response without entries
+	stream.start()
+}
+
+// readParams maps the query parameters onto the fields of ReadParams, filters are given with prefix,
+// contains, regex and jsonField=field=value, and can be repeated
+func readParams(r *http.Request, topic string) (*grpcApi.ReadParams, error) {
+	query := r.URL.Query()
+	params := &grpcApi.ReadParams{
+		Topic:     topic,
+		BatchSize: 1000,
+		Group:     query.Get("group"),
+	}
+	var err error
+	uints := map[string]*uint64{
+		"offset":     &params.Offset,
+		"endOffset":  &params.EndOffset,
+		"maxEntries": &params.MaxEntries,
+		"maxBytes":   &params.MaxBytes,
+	}
+	for name, field := range uints {
+		if query.Has(name) {
+			*field, err = strconv.ParseUint(query.Get(name), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s is not a uint64", name, query.Get(name))
+			}
+		}
+	}
+	if query.Has("batchSize") {
+		batchSize, err := strconv.ParseUint(query.Get("batchSize"), 10, 32)
+		if err != nil || batchSize == 0 {
+			return nil, fmt.Errorf("batchSize %s is not a positive uint32", query.Get("batchSize"))
+		}
+		params.BatchSize = uint32(batchSize)
+	}
+	if query.Has("fromTimestamp") {
+		params.FromTimestamp, err = strconv.ParseInt(query.Get("fromTimestamp"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("fromTimestamp %s is not an int64", query.Get("fromTimestamp"))
+		}
+	}
+	if query.Has("stopOnCompletion") {
+		params.StopOnCompletion, err = strconv.ParseBool(query.Get("stopOnCompletion"))
+		if err != nil {
+			return nil, fmt.Errorf("stopOnCompletion %s is not a boolean", query.Get("stopOnCompletion"))
+		}
+	}
+	if query.Has("startPosition") {
+		position, found := grpcApi.StartPosition_value[strings.ToUpper(query.Get("startPosition"))]
+		if !found {
+			return nil, fmt.Errorf("unknown start position %s", query.Get("startPosition"))
+		}
+		params.StartPosition = grpcApi.StartPosition(position)
+	}
+	lastEventId := r.Header.Get("Last-Event-ID")
+	if lastEventId != "" {
+		// an EventSource reconnecting
+		lastOffset, err := strconv.ParseUint(lastEventId, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Last-Event-ID %s is not an offset", lastEventId)
+		}
+		params.StartPosition = grpcApi.StartPosition_OFFSET
+		params.Offset = lastOffset + 1
+		params.FromTimestamp = 0
+	}
+	for _, prefix := range query["prefix"] {
+		params.Filters = append(params.Filters, &grpcApi.EntryFilter{Filter: &grpcApi.EntryFilter_Prefix{Prefix: []byte(prefix)}})
+	}
+	for _, substring := range query["contains"] {
+		params.Filters = append(params.Filters, &grpcApi.EntryFilter{Filter: &grpcApi.EntryFilter_Contains{Contains: []byte(substring)}})
+	}
+	for _, regex := range query["regex"] {
+		params.Filters = append(params.Filters, &grpcApi.EntryFilter{Filter: &grpcApi.EntryFilter_Regex{Regex: regex}})
+	}
+	for _, jsonField := range query["jsonField"] {
+		field, value, ok := strings.Cut(jsonField, "=")
+		if !ok {
+			return nil, fmt.Errorf("jsonField %s is not field=value", jsonField)
+		}
+		params.Filters = append(params.Filters, &grpcApi.EntryFilter{Filter: &grpcApi.EntryFilter_JsonField{
+			JsonField: &grpcApi.JsonFieldFilter{Field: field, Value: value},
+		}})
+	}
+	return params, nil
+}
+
+// readStream writes the entries sent by a read to the response, as Server-Sent Events or NDJSON. The
+// response is started by the first batch, so errors found before any entries are read are sent with
+// the http status of the error.
+type readStream struct {
+	// only Send and Context are used by reads
+	grpc.ServerStream
+	ctx     context.Context
+	writer  http.ResponseWriter
+	flusher http.Flusher
+	sse     bool
+	base64  bool
+	started bool
+}
+
+var _ grpcApi.Ibsen_ReadServer = &readStream{}
+
+func (s *readStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *readStream) Send(output *grpcApi.OutputEntries) error {
+	s.start()
+	var buffer bytes.Buffer
+	for _, entry := range output.Entries {
+		content, err := json.Marshal(outputEntry{
+			Offset:    entry.Offset,
+			Content:   s.encode(entry.Content),
+			Timestamp: entry.Timestamp,
+			Key:       s.encode(entry.Key),
+			Headers:   entry.Headers,
+		})
+		if err != nil {
+			return err
+		}
+		if s.sse {
+			fmt.Fprintf(&buffer, "id: %d\ndata: %s\n\n", entry.Offset, content)
+		} else {
+			buffer.Write(content)
+			buffer.WriteByte('\n')
+		}
+	}
+	_, err := s.writer.Write(buffer.Bytes())
+	if err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *readStream) encode(payload []byte) string {
+	if s.base64 {
+		return base64.StdEncoding.EncodeToString(payload)
+	}
+	return string(payload)
+}
+
+func (s *readStream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	if s.sse {
+		s.writer.Header().Set("Content-Type", "text/event-stream")
+		s.writer.Header().Set("Cache-Control", "no-cache")
+	} else {
+		s.writer.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	s.writer.WriteHeader(http.StatusOK)
+	s.flusher.Flush()
+}
+
+// writeError ends a stream that is started with the error, as an error event or a last line
+func (s *readStream) writeError(err error) {
+	content, _ := json.Marshal(errorResponse{Error: status.Convert(err).Message()})
+	if s.sse {
+		fmt.Fprintf(s.writer, "event: error\ndata: %s\n\n", content)
+	} else {
+		fmt.Fprintf(s.writer, "%s\n", content)
+	}
+	s.flusher.Flush()
+}
+
+type outputEntry struct {
+	Offset uint64 `json:"offset"`
+	// Content is the entry as text, bytes that are not valid UTF-8 are replaced, or base64 encoded
+	Content   string            `json:"content"`
+	Timestamp int64             `json:"timestamp"`
+	Key       string            `json:"key,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+}
+
+type writeStatus struct {
+	Wrote       int64  `json:"wrote"`
+	FirstOffset uint64 `json:"firstOffset"`
+	LastOffset  uint64 `json:"lastOffset"`
+	Duplicate   bool   `json:"duplicate,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+	// ActualNextOffset is set when a conditional write fails
+	ActualNextOffset *uint64 `json:"actualNextOffset,omitempty"`
+}
+
+func isJSONContent(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.TrimSpace(mediaType) == "application/json"
+}
+
+func jsonArrayEntries(body []byte) ([][]byte, error) {
+	var elements []json.RawMessage
+	err := json.Unmarshal(body, &elements)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([][]byte, 0, len(elements))
+	for _, element := range elements {
+		var text string
+		if json.Unmarshal(element, &text) == nil {
+			entries = append(entries, []byte(text))
+			continue
+		}
+		var compacted bytes.Buffer
+		err = json.Compact(&compacted, element)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, compacted.Bytes())
+	}
+	return entries, nil
+}
+
+func lineEntries(body []byte) [][]byte {
+	var entries [][]byte
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if len(line) == 0 {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	return entries
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	err := json.NewEncoder(w).Encode(value)
+	if err != nil {
+		log.Err(err).Msg("unable to write http response")
+	}
+}
+
+func writeError(w http.ResponseWriter, statusCode int, format string, args ...any) {
+	writeJSON(w, statusCode, errorResponse{Error: fmt.Sprintf(format, args...)})
+}
+
+// writeStatusError writes the error returned by the Ibsen service with the http status of its code
+func writeStatusError(w http.ResponseWriter, err error) {
+	grpcStatus := status.Convert(err)
+	response := errorResponse{Error: grpcStatus.Message()}
+	for _, detail := range grpcStatus.Details() {
+		if mismatch, ok := detail.(*grpcApi.OffsetMismatch); ok {
+			actual := mismatch.ActualNextOffset
+			response.ActualNextOffset = &actual
+		}
+	}
+	writeJSON(w, httpStatus(grpcStatus.Code()), response)
+}
+
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.FailedPrecondition:
+		return http.StatusConflict
+	case codes.OutOfRange:
+		return http.StatusRequestedRangeNotSatisfiable
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,169 @@
+package httpApi
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/tcw/ibsen/api/grpcApi"
+	"github.com/tcw/ibsen/manager"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startGateway(t *testing.T) (*httptest.Server, func()) {
+	afs := &afero.Afero{Fs: afero.NewMemMapFs()}
+	err := afs.Mkdir("/tmp/data", 0600)
+	assert.Nil(t, err)
+	topicsManager, err := manager.NewLogTopicsManager(manager.LogTopicManagerParams{
+		Afs:          afs,
+		TTL:          5 * time.Second,
+		MaxBlockSize: 10,
+		RootPath:     "/tmp/data",
+	})
+	assert.Nil(t, err)
+	service := grpcApi.NewUnsecureIbsenGrpcServer(&topicsManager, 5*time.Second).Service()
+	gateway := httptest.NewServer(NewIbsenHttpServer(service, 1024).HttpServer.Handler)
+	return gateway, func() {
+		gateway.Close()
+		topicsManager.Shutdown()
+	}
+}
+
+func post(t *testing.T, url string, contentType string, body string) (int, map[string]any) {
+	response, err := http.Post(url, contentType, strings.NewReader(body))
+	assert.Nil(t, err)
+	defer response.Body.Close()
+	var result map[string]any
+	err = json.NewDecoder(response.Body).Decode(&result)
+	assert.Nil(t, err)
+	return response.StatusCode, result
+}
+
+func TestWriteListAndRead(t *testing.T) {
+	gateway, stop := startGateway(t)
+	defer stop()
+
+	code, written := post(t, gateway.URL+"/topics/orders", "text/plain", "a\nb\n\nc\n")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(3), written["wrote"])
+	assert.Equal(t, float64(2), written["lastOffset"])
+	code, written = post(t, gateway.URL+"/topics/orders", "application/json", `["d", {"tenant": "acme"}, 42]`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, float64(3), written["firstOffset"])
+	code, written = post(t, gateway.URL+"/topics/orders?expectedNextOffset=2", "text/plain", "e")
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, float64(6), written["actualNextOffset"])
+	code, _ = post(t, gateway.URL+"/topics/orders", "application/json", `{"not": "an array"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	response, err := http.Get(gateway.URL + "/topics")
+	assert.Nil(t, err)
+	var list map[string][]string
+	err = json.NewDecoder(response.Body).Decode(&list)
+	assert.Nil(t, err)
+	response.Body.Close()
+	assert.Equal(t, []string{"orders"}, list["topics"])
+
+	response, err = http.Get(gateway.URL + "/topics/orders?offset=1&stopOnCompletion=true&batchSize=2")
+	assert.Nil(t, err)
+	assert.Equal(t, "application/x-ndjson", response.Header.Get("Content-Type"))
+	var entries []outputEntry
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var entry outputEntry
+		err = decoder.Decode(&entry)
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		entries = append(entries, entry)
+	}
+	response.Body.Close()
+	assert.Equal(t, 5, len(entries))
+	assert.Equal(t, uint64(1), entries[0].Offset)
+	assert.Equal(t, "b", entries[0].Content)
+	assert.Equal(t, `{"tenant":"acme"}`, entries[3].Content)
+	assert.Equal(t, "42", entries[4].Content)
+
+	code, _ = post(t, gateway.URL+"/topics/orders", "text/plain", strings.Repeat("f", 1025))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+
+	response, err = http.Get(gateway.URL + "/topics/orders?stopOnCompletion=true&regex=(")
+	assert.Nil(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestReadBinaryEntriesAsBase64(t *testing.T) {
+	gateway, stop := startGateway(t)
+	defer stop()
+	code, _ := post(t, gateway.URL+"/topics/binary", "application/octet-stream", "\xff\xfe\x00")
+	assert.Equal(t, http.StatusOK, code)
+
+	response, err := http.Get(gateway.URL + "/topics/binary?stopOnCompletion=true&encoding=base64")
+	assert.Nil(t, err)
+	var entry outputEntry
+	err = json.NewDecoder(response.Body).Decode(&entry)
+	assert.Nil(t, err)
+	response.Body.Close()
+	assert.Equal(t, "//4A", entry.Content)
+
+	response, err = http.Get(gateway.URL + "/topics/binary?stopOnCompletion=true&encoding=hex")
+	assert.Nil(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestReadFollowsWithServerSentEvents(t *testing.T) {
+	gateway, stop := startGateway(t)
+	defer stop()
+	code, _ := post(t, gateway.URL+"/topics/events", "text/plain", "first\nsecond")
+	assert.Equal(t, http.StatusOK, code)
+
+	// an EventSource reconnecting after the first entry
+	request, err := http.NewRequest(http.MethodGet, gateway.URL+"/topics/events?maxEntries=3", nil)
+	assert.Nil(t, err)
+	request.Header.Set("Accept", "text/event-stream")
+	request.Header.Set("Last-Event-ID", "0")
+	events := make(chan string)
+	go func() {
+		response, err := http.DefaultClient.Do(request)
+		assert.Nil(t, err)
+		defer response.Body.Close()
+		assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+		scanner := bufio.NewScanner(response.Body)
+		for scanner.Scan() {
+			if strings.HasPrefix(scanner.Text(), "id: ") || strings.HasPrefix(scanner.Text(), "data: ") {
+				events <- scanner.Text()
+			}
+		}
+		close(events)
+	}()
+	assert.Equal(t, "id: 1", <-events)
+	assert.Contains(t, <-events, `"content":"second"`)
+	code, _ = post(t, gateway.URL+"/topics/events", "text/plain", "third\nfourth")
+	assert.Equal(t, http.StatusOK, code)
+
+	var received []string
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case event, open := <-events:
+			if !open {
+				done = true
+				break
+			}
+			received = append(received, event)
+		case <-timeout:
+			t.Fatal("following read did not get the entries written")
+		}
+	}
+	assert.Equal(t, 4, len(received))
+	assert.Equal(t, "id: 2", received[0])
+	assert.Equal(t, "id: 3", received[2])
+}
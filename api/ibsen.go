@@ -1,10 +1,13 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/afero"
+	"github.com/tcw/ibsen/access/common"
 	"github.com/tcw/ibsen/api/grpcApi"
+	"github.com/tcw/ibsen/api/httpApi"
 	"github.com/tcw/ibsen/consensus"
 	"github.com/tcw/ibsen/errore"
 	"github.com/tcw/ibsen/manager"
@@ -19,6 +22,7 @@ import (
 )
 
 var ibsenGrpcServer *grpcApi.IbsenGrpcServer
+var ibsenHttpServer *httpApi.IbsenHttpServer
 var ibsenFiglet = `
                            _____ _                    
                           |_   _| |                   
@@ -33,19 +37,34 @@ var ibsenFiglet = `
 `
 
 type IbsenServer struct {
-	Readonly         bool
-	Lock             consensus.SingleIbsenWriterLock
-	InMemory         bool
-	Afs              *afero.Afero
-	TTL              time.Duration
-	RootPath         string
-	MaxBlockSize     int
-	OTELExporterAddr string
-	GRPCPrivateKey   string
-	GRPCCertKey      string
-	CpuProfile       string
-	MemProfile       string
-	cpuProfileFile   *os.File
+	Readonly               bool
+	Lock                   consensus.SingleIbsenWriterLock
+	InMemory               bool
+	Afs                    *afero.Afero
+	TTL                    time.Duration
+	RootPath               string
+	MaxBlockSize           int
+	CorruptionPolicy       common.CorruptionPolicy
+	Durability             common.Durability
+	GroupCommitWindow      time.Duration
+	Retention              common.RetentionPolicy
+	Compaction             common.CompactionPolicy
+	CompactionEvery        time.Duration
+	Compression            common.Compression
+	ProducerExpiry         time.Duration
+	IndexCacheSize         int64
+	IndexWorkers           int
+	IndexMaxBytesPerSecond int64
+	WriteStreamWindow      int
+	HTTPListener           net.Listener
+	TopicConfigs           map[common.TopicName]manager.TopicConfig
+	OTELExporterAddr       string
+	GRPCPrivateKey         string
+	GRPCCertKey            string
+	CpuProfile             string
+	MemProfile             string
+	cpuProfileFile         *os.File
+	topicsManager          *manager.LogTopicsManager
 }
 
 func (ibs *IbsenServer) Start(listener net.Listener) error {
@@ -87,16 +106,29 @@ func (ibs *IbsenServer) Start(listener net.Listener) error {
 	}
 
 	topicsManager, err := manager.NewLogTopicsManager(manager.LogTopicManagerParams{
-		ReadOnly:         ibs.Readonly,
-		Afs:              ibs.Afs,
-		TTL:              ibs.TTL,
-		CheckForNewEvery: time.Second * 2,
-		MaxBlockSize:     ibs.MaxBlockSize,
-		RootPath:         ibs.RootPath,
+		ReadOnly:            ibs.Readonly,
+		Afs:                 ibs.Afs,
+		TTL:                 ibs.TTL,
+		MaxBlockSize:        ibs.MaxBlockSize,
+		CorruptionPolicy:    ibs.CorruptionPolicy,
+		Durability:          ibs.Durability,
+		GroupCommitWindow:   ibs.GroupCommitWindow,
+		Retention:           ibs.Retention,
+		RetentionCheckEvery: time.Minute,
+		Compaction:          ibs.Compaction,
+		CompactionEvery:     ibs.CompactionEvery,
+		Compression:         ibs.Compression,
+		ProducerExpiry:      ibs.ProducerExpiry,
+		IndexCacheSize:      ibs.IndexCacheSize,
+		IndexWorkers:        ibs.IndexWorkers,
+		IndexBytesPerSecond: ibs.IndexMaxBytesPerSecond,
+		TopicConfigs:        ibs.TopicConfigs,
+		RootPath:            ibs.RootPath,
 	})
 	if err != nil {
 		return errore.Wrap(err)
 	}
+	ibs.topicsManager = &topicsManager
 	err = ibs.startGRPCServer(listener, &topicsManager)
 	if err != nil {
 		return errore.Wrap(err)
@@ -107,12 +139,18 @@ func (ibs *IbsenServer) Start(listener net.Listener) error {
 func (ibs *IbsenServer) startGRPCServer(lis net.Listener, manager manager.LogManager) error {
 	if ibs.GRPCPrivateKey == "" && ibs.GRPCCertKey == "" {
 		log.Warn().Msg("ibsen server is starting in UNSECURE mode")
-		ibsenGrpcServer = grpcApi.NewUnsecureIbsenGrpcServer(manager, ibs.TTL, time.Second*2)
+		ibsenGrpcServer = grpcApi.NewUnsecureIbsenGrpcServer(manager, ibs.TTL)
 	} else {
 		ibsenGrpcServer = grpcApi.NewSecureIbsenGrpcServer(manager, grpcApi.GRPCSecurity{
 			CertKeyFile:   ibs.GRPCCertKey,
 			PrivteKeyFile: ibs.GRPCPrivateKey,
-		}, ibs.TTL, time.Second*2)
+		}, ibs.TTL)
+	}
+	if ibs.WriteStreamWindow > 0 {
+		ibsenGrpcServer.WriteStreamWindow = ibs.WriteStreamWindow
+	}
+	if ibs.HTTPListener != nil {
+		ibs.startHTTPGateway(ibsenGrpcServer.Service())
 	}
 	log.Info().Msg(fmt.Sprintf("Started ibsen server on: [%s]", lis.Addr().String()))
 	fmt.Print(ibsenFiglet)
@@ -126,6 +164,18 @@ func (ibs *IbsenServer) startGRPCServer(lis net.Listener, manager manager.LogMan
 	return nil
 }
 
+// startHTTPGateway serves the Ibsen service as REST endpoints on HTTPListener
+func (ibs *IbsenServer) startHTTPGateway(service grpcApi.IbsenServer) {
+	ibsenHttpServer = httpApi.NewIbsenHttpServer(service, int64(ibs.MaxBlockSize))
+	log.Info().Msg(fmt.Sprintf("Started http gateway on: [%s]", ibs.HTTPListener.Addr().String()))
+	go func() {
+		err := ibsenHttpServer.Start(ibs.HTTPListener)
+		if err != nil {
+			log.Err(err).Msg("http gateway failed")
+		}
+	}()
+}
+
 func (ibs *IbsenServer) initSignals() {
 	var captureSignal = make(chan os.Signal, 1)
 	signal.Notify(captureSignal, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGABRT)
@@ -159,6 +209,13 @@ func (ibs *IbsenServer) ShutdownCleanly() {
 		}
 	}
 
+	if ibsenHttpServer != nil {
+		log.Info().Msg("gracefully stopping http gateway...")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		ibsenHttpServer.Shutdown(ctx)
+		cancel()
+	}
+
 	log.Info().Msg("gracefully stopping grpc server...")
 
 	stopped := make(chan struct{})
@@ -176,6 +233,11 @@ func (ibs *IbsenServer) ShutdownCleanly() {
 		t.Stop()
 	}
 
+	if ibs.topicsManager != nil {
+		log.Info().Msg("indexing entries not yet indexed...")
+		ibs.topicsManager.Shutdown()
+	}
+
 	if !ibs.InMemory {
 		isReleased := ibs.Lock.ReleaseLock()
 		if isReleased {
@@ -19,9 +19,14 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	Ibsen_Write_FullMethodName = "/Ibsen/write"
-	Ibsen_Read_FullMethodName  = "/Ibsen/read"
-	Ibsen_List_FullMethodName  = "/Ibsen/list"
+	Ibsen_Write_FullMethodName                = "/Ibsen/write"
+	Ibsen_WriteStream_FullMethodName          = "/Ibsen/writeStream"
+	Ibsen_Read_FullMethodName                 = "/Ibsen/read"
+	Ibsen_Subscribe_FullMethodName            = "/Ibsen/subscribe"
+	Ibsen_List_FullMethodName                 = "/Ibsen/list"
+	Ibsen_CommitOffset_FullMethodName         = "/Ibsen/commitOffset"
+	Ibsen_FetchCommittedOffset_FullMethodName = "/Ibsen/fetchCommittedOffset"
+	Ibsen_DescribeTopic_FullMethodName        = "/Ibsen/describeTopic"
 )
 
 // IbsenClient is the client API for Ibsen service.
@@ -29,8 +34,15 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type IbsenClient interface {
 	Write(ctx context.Context, in *InputEntries, opts ...grpc.CallOption) (*WriteStatus, error)
+	// batches are written in the order they are sent, and acknowledged in the same order
+	WriteStream(ctx context.Context, opts ...grpc.CallOption) (Ibsen_WriteStreamClient, error)
 	Read(ctx context.Context, in *ReadParams, opts ...grpc.CallOption) (Ibsen_ReadClient, error)
+	// reads several topics in one stream, and topics matching the pattern when they are created
+	Subscribe(ctx context.Context, in *SubscribeParams, opts ...grpc.CallOption) (Ibsen_SubscribeClient, error)
 	List(ctx context.Context, in *EmptyArgs, opts ...grpc.CallOption) (*TopicList, error)
+	CommitOffset(ctx context.Context, in *CommitParams, opts ...grpc.CallOption) (*CommitStatus, error)
+	FetchCommittedOffset(ctx context.Context, in *FetchOffsetParams, opts ...grpc.CallOption) (*CommittedOffset, error)
+	DescribeTopic(ctx context.Context, in *DescribeParams, opts ...grpc.CallOption) (*TopicDescription, error)
 }
 
 type ibsenClient struct {
@@ -50,8 +62,39 @@ func (c *ibsenClient) Write(ctx context.Context, in *InputEntries, opts ...grpc.
 	return out, nil
 }
 
+func (c *ibsenClient) WriteStream(ctx context.Context, opts ...grpc.CallOption) (Ibsen_WriteStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Ibsen_ServiceDesc.Streams[0], Ibsen_WriteStream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &ibsenWriteStreamClient{stream}
+	return x, nil
+}
+
+type Ibsen_WriteStreamClient interface {
+	Send(*InputEntries) error
+	Recv() (*WriteAck, error)
+	grpc.ClientStream
+}
+
+type ibsenWriteStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *ibsenWriteStreamClient) Send(m *InputEntries) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *ibsenWriteStreamClient) Recv() (*WriteAck, error) {
+	m := new(WriteAck)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *ibsenClient) Read(ctx context.Context, in *ReadParams, opts ...grpc.CallOption) (Ibsen_ReadClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Ibsen_ServiceDesc.Streams[0], Ibsen_Read_FullMethodName, opts...)
+	stream, err := c.cc.NewStream(ctx, &Ibsen_ServiceDesc.Streams[1], Ibsen_Read_FullMethodName, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -82,6 +125,38 @@ func (x *ibsenReadClient) Recv() (*OutputEntries, error) {
 	return m, nil
 }
 
+func (c *ibsenClient) Subscribe(ctx context.Context, in *SubscribeParams, opts ...grpc.CallOption) (Ibsen_SubscribeClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Ibsen_ServiceDesc.Streams[2], Ibsen_Subscribe_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &ibsenSubscribeClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Ibsen_SubscribeClient interface {
+	Recv() (*TopicEntries, error)
+	grpc.ClientStream
+}
+
+type ibsenSubscribeClient struct {
+	grpc.ClientStream
+}
+
+func (x *ibsenSubscribeClient) Recv() (*TopicEntries, error) {
+	m := new(TopicEntries)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *ibsenClient) List(ctx context.Context, in *EmptyArgs, opts ...grpc.CallOption) (*TopicList, error) {
 	out := new(TopicList)
 	err := c.cc.Invoke(ctx, Ibsen_List_FullMethodName, in, out, opts...)
@@ -91,13 +166,47 @@ func (c *ibsenClient) List(ctx context.Context, in *EmptyArgs, opts ...grpc.Call
 	return out, nil
 }
 
+func (c *ibsenClient) CommitOffset(ctx context.Context, in *CommitParams, opts ...grpc.CallOption) (*CommitStatus, error) {
+	out := new(CommitStatus)
+	err := c.cc.Invoke(ctx, Ibsen_CommitOffset_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ibsenClient) FetchCommittedOffset(ctx context.Context, in *FetchOffsetParams, opts ...grpc.CallOption) (*CommittedOffset, error) {
+	out := new(CommittedOffset)
+	err := c.cc.Invoke(ctx, Ibsen_FetchCommittedOffset_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ibsenClient) DescribeTopic(ctx context.Context, in *DescribeParams, opts ...grpc.CallOption) (*TopicDescription, error) {
+	out := new(TopicDescription)
+	err := c.cc.Invoke(ctx, Ibsen_DescribeTopic_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // IbsenServer is the server API for Ibsen service.
 // All implementations must embed UnimplementedIbsenServer
 // for forward compatibility
 type IbsenServer interface {
 	Write(context.Context, *InputEntries) (*WriteStatus, error)
+	// batches are written in the order they are sent, and acknowledged in the same order
+	WriteStream(Ibsen_WriteStreamServer) error
 	Read(*ReadParams, Ibsen_ReadServer) error
+	// reads several topics in one stream, and topics matching the pattern when they are created
+	Subscribe(*SubscribeParams, Ibsen_SubscribeServer) error
 	List(context.Context, *EmptyArgs) (*TopicList, error)
+	CommitOffset(context.Context, *CommitParams) (*CommitStatus, error)
+	FetchCommittedOffset(context.Context, *FetchOffsetParams) (*CommittedOffset, error)
+	DescribeTopic(context.Context, *DescribeParams) (*TopicDescription, error)
 	mustEmbedUnimplementedIbsenServer()
 }
 
@@ -108,12 +217,27 @@ type UnimplementedIbsenServer struct {
 func (UnimplementedIbsenServer) Write(context.Context, *InputEntries) (*WriteStatus, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Write not implemented")
 }
+func (UnimplementedIbsenServer) WriteStream(Ibsen_WriteStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method WriteStream not implemented")
+}
 func (UnimplementedIbsenServer) Read(*ReadParams, Ibsen_ReadServer) error {
 	return status.Errorf(codes.Unimplemented, "method Read not implemented")
 }
+func (UnimplementedIbsenServer) Subscribe(*SubscribeParams, Ibsen_SubscribeServer) error {
+	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
 func (UnimplementedIbsenServer) List(context.Context, *EmptyArgs) (*TopicList, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
 }
+func (UnimplementedIbsenServer) CommitOffset(context.Context, *CommitParams) (*CommitStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CommitOffset not implemented")
+}
+func (UnimplementedIbsenServer) FetchCommittedOffset(context.Context, *FetchOffsetParams) (*CommittedOffset, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FetchCommittedOffset not implemented")
+}
+func (UnimplementedIbsenServer) DescribeTopic(context.Context, *DescribeParams) (*TopicDescription, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DescribeTopic not implemented")
+}
 func (UnimplementedIbsenServer) mustEmbedUnimplementedIbsenServer() {}
 
 // UnsafeIbsenServer may be embedded to opt out of forward compatibility for this service.
@@ -145,6 +269,32 @@ func _Ibsen_Write_Handler(srv interface{}, ctx context.Context, dec func(interfa
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Ibsen_WriteStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(IbsenServer).WriteStream(&ibsenWriteStreamServer{stream})
+}
+
+type Ibsen_WriteStreamServer interface {
+	Send(*WriteAck) error
+	Recv() (*InputEntries, error)
+	grpc.ServerStream
+}
+
+type ibsenWriteStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *ibsenWriteStreamServer) Send(m *WriteAck) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *ibsenWriteStreamServer) Recv() (*InputEntries, error) {
+	m := new(InputEntries)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func _Ibsen_Read_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(ReadParams)
 	if err := stream.RecvMsg(m); err != nil {
@@ -166,6 +316,27 @@ func (x *ibsenReadServer) Send(m *OutputEntries) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _Ibsen_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeParams)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(IbsenServer).Subscribe(m, &ibsenSubscribeServer{stream})
+}
+
+type Ibsen_SubscribeServer interface {
+	Send(*TopicEntries) error
+	grpc.ServerStream
+}
+
+type ibsenSubscribeServer struct {
+	grpc.ServerStream
+}
+
+func (x *ibsenSubscribeServer) Send(m *TopicEntries) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _Ibsen_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(EmptyArgs)
 	if err := dec(in); err != nil {
@@ -184,6 +355,60 @@ func _Ibsen_List_Handler(srv interface{}, ctx context.Context, dec func(interfac
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Ibsen_CommitOffset_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommitParams)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IbsenServer).CommitOffset(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ibsen_CommitOffset_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IbsenServer).CommitOffset(ctx, req.(*CommitParams))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Ibsen_FetchCommittedOffset_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FetchOffsetParams)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IbsenServer).FetchCommittedOffset(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ibsen_FetchCommittedOffset_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IbsenServer).FetchCommittedOffset(ctx, req.(*FetchOffsetParams))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Ibsen_DescribeTopic_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DescribeParams)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IbsenServer).DescribeTopic(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Ibsen_DescribeTopic_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IbsenServer).DescribeTopic(ctx, req.(*DescribeParams))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Ibsen_ServiceDesc is the grpc.ServiceDesc for Ibsen service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -199,13 +424,36 @@ var Ibsen_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "list",
 			Handler:    _Ibsen_List_Handler,
 		},
+		{
+			MethodName: "commitOffset",
+			Handler:    _Ibsen_CommitOffset_Handler,
+		},
+		{
+			MethodName: "fetchCommittedOffset",
+			Handler:    _Ibsen_FetchCommittedOffset_Handler,
+		},
+		{
+			MethodName: "describeTopic",
+			Handler:    _Ibsen_DescribeTopic_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "writeStream",
+			Handler:       _Ibsen_WriteStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 		{
 			StreamName:    "read",
 			Handler:       _Ibsen_Read_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "subscribe",
+			Handler:       _Ibsen_Subscribe_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "ibsen.proto",
 }
@@ -1 +1,4 @@
-protoc --proto_path=../../api/grpcApi --java_out=. ibsen.proto
\ No newline at end of file
+set -e
+cd "$(dirname "$0")"
+command -v protoc > /dev/null || { echo "protoc is required to generate the java client" >&2; exit 1; }
+protoc --proto_path=../../api/grpcApi --java_out=. ibsen.proto
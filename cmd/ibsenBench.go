@@ -17,6 +17,8 @@ import (
 type IbsenBench struct {
 	Client grpcApi.IbsenClient
 	Ctx    context.Context
+	// UseWriteStream writes all batches to a topic on one write stream
+	UseWriteStream bool
 }
 
 func newIbsenBench(target string) (IbsenBench, error) {
@@ -136,16 +138,34 @@ func (b *IbsenBench) benchWrite(topic string, entryByteSize int, entriesInEachBa
 	entriesWritten := 0
 	inputEntries := createInputEntries(topic, entriesInEachBatch, entryByteSize)
 	start := time.Now()
-	for i := 0; i < batches; i++ {
-		_, err := b.Client.Write(b.Ctx, &inputEntries)
+	if b.UseWriteStream {
+		writer, err := newStreamWriter(b.Ctx, b.Client, nil)
 		if err != nil {
 			return "", err
 		}
-		entriesWritten = entriesWritten + len(inputEntries.Entries)
+		for i := 0; i < batches; i++ {
+			err = writer.send(&inputEntries)
+			if err != nil {
+				return "", err
+			}
+			entriesWritten = entriesWritten + len(inputEntries.Entries)
+		}
+		err = writer.close()
+		if err != nil {
+			return "", err
+		}
+	} else {
+		for i := 0; i < batches; i++ {
+			_, err := b.Client.Write(b.Ctx, &inputEntries)
+			if err != nil {
+				return "", err
+			}
+			entriesWritten = entriesWritten + len(inputEntries.Entries)
+		}
 	}
 	used := time.Now().Sub(start)
-	return fmt.Sprintf("Wrote\t%d in %s\t[batchSize:%d, batches:%d, entryByteSize:%d]",
-		entriesWritten, used, entriesInEachBatch, batches, entryByteSize), nil
+	return fmt.Sprintf("Wrote\t%d in %s\t[batchSize:%d, batches:%d, entryByteSize:%d, stream:%t]",
+		entriesWritten, used, entriesInEachBatch, batches, entryByteSize, b.UseWriteStream), nil
 }
 
 func createInputEntries(topic string, numberOfEntries int, entryByteSize int) grpcApi.InputEntries {
@@ -49,31 +49,125 @@ func (ic *IbsenClient) List() (string, error) {
 	return strings.Join(list.Topics, "\n"), nil
 }
 
-func (ic *IbsenClient) Read(topic string, offset uint64, batchSize uint32) error {
-	entryStream, err := ic.Client.Read(ic.Ctx, &grpcApi.ReadParams{
-		StopOnCompletion: false,
-		Topic:            topic,
-		Offset:           offset,
-		BatchSize:        batchSize,
-	})
+// Describe returns the description of a topic, one field on each line and one line for each block
+func (ic *IbsenClient) Describe(topic string) (string, error) {
+	description, err := ic.Client.DescribeTopic(ic.Ctx, &grpcApi.DescribeParams{Topic: topic})
 	if err != nil {
-		return err
+		return "", err
+	}
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "topic\t%s\n", description.Topic)
+	fmt.Fprintf(&builder, "firstOffset\t%d\n", description.FirstOffset)
+	fmt.Fprintf(&builder, "nextOffset\t%d\n", description.NextOffset)
+	fmt.Fprintf(&builder, "headBlockSize\t%d\n", description.HeadBlockSize)
+	if description.IndexPosition != nil {
+		fmt.Fprintf(&builder, "indexPosition\t%d:%d\n", description.IndexPosition.Block, description.IndexPosition.ByteOffset)
+	} else {
+		fmt.Fprintf(&builder, "indexPosition\tnone\n")
 	}
+	if description.LastWriteTime > 0 {
+		fmt.Fprintf(&builder, "lastWriteTime\t%s\n", time.Unix(0, description.LastWriteTime).Format(time.RFC3339Nano))
+	} else {
+		fmt.Fprintf(&builder, "lastWriteTime\tnone\n")
+	}
+	for _, block := range description.Blocks {
+		compressed := ""
+		if block.Compressed {
+			compressed = "\tcompressed"
+		}
+		fmt.Fprintf(&builder, "block\t%020d\t%d bytes%s\n", block.Block, block.ByteSize, compressed)
+	}
+	return strings.TrimSuffix(builder.String(), "\n"), nil
+}
 
-	stdout := os.Stdout
-	defer stdout.Close()
+// Read writes entries to stdout, with a consumer group the read resumes from the offset committed by
+// the group, and the offset after each batch written is committed. A follow read follows the topic
+// like tail -f, and continues after the last entry when the server ends the stream.
+func (ic *IbsenClient) Read(params *grpcApi.ReadParams, follow bool) error {
+	ctx := ic.Ctx
+	if follow {
+		// following is only stopped by the user
+		ctx = context.Background()
+	}
+	defer os.Stdout.Close()
+	for {
+		entryStream, err := ic.Client.Read(ctx, params)
+		if err != nil {
+			return err
+		}
+		lastOffset, read, err := ic.writeEntries(ctx, entryStream, params)
+		if err != nil {
+			return err
+		}
+		if !follow {
+			return nil
+		}
+		if read {
+			params.StartPosition = grpcApi.StartPosition_OFFSET
+			params.Offset = lastOffset + 1
+			params.FromTimestamp = 0
+		}
+	}
+}
+
+// writeEntries writes the entries of a read stream to stdout, and returns the offset of the last one
+func (ic *IbsenClient) writeEntries(ctx context.Context, entryStream grpcApi.Ibsen_ReadClient, params *grpcApi.ReadParams) (uint64, bool, error) {
+	var lastOffset uint64
+	read := false
 	for {
 		in, err := entryStream.Recv()
 		if err == io.EOF {
-			return nil
+			return lastOffset, read, nil
 		}
 		if err != nil {
-			return err
+			return lastOffset, read, err
 		}
 		entries := in.Entries
 		for _, entry := range entries {
 			line := fmt.Sprintf("%d\t%s\n", entry.Offset, string(entry.Content))
-			_, err = stdout.Write([]byte(line))
+			_, err = os.Stdout.Write([]byte(line))
+			if err != nil {
+				return lastOffset, read, err
+			}
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		lastOffset = entries[len(entries)-1].Offset
+		read = true
+		if params.Group != "" {
+			_, err = ic.Client.CommitOffset(ctx, &grpcApi.CommitParams{
+				Group:  params.Group,
+				Topic:  params.Topic,
+				Offset: lastOffset + 1,
+			})
+			if err != nil {
+				return lastOffset, read, err
+			}
+		}
+	}
+}
+
+// Subscribe writes the entries of all topics in the subscription to stdout, tagged with their topic,
+// until the server ends the stream
+func (ic *IbsenClient) Subscribe(params *grpcApi.SubscribeParams) error {
+	defer os.Stdout.Close()
+	// a subscription is only stopped by the user
+	entryStream, err := ic.Client.Subscribe(context.Background(), params)
+	if err != nil {
+		return err
+	}
+	for {
+		in, err := entryStream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, entry := range in.Entries {
+			line := fmt.Sprintf("%s\t%d\t%s\n", in.Topic, entry.Offset, string(entry.Content))
+			_, err = os.Stdout.Write([]byte(line))
 			if err != nil {
 				return err
 			}
@@ -81,8 +175,35 @@ func (ic *IbsenClient) Read(topic string, offset uint64, batchSize uint32) error
 	}
 }
 
-func (ic *IbsenClient) Write(topic string, fileName ...string) (string, error) {
+// Write writes each line of the file, or stdin, as an entry. With printOffsets the first and last
+// offset given to each batch is printed when the batch is written. With useStream the batches are sent
+// on one write stream, without waiting for each batch to be written before sending the next.
+func (ic *IbsenClient) Write(topic string, printOffsets bool, useStream bool, fileName ...string) (string, error) {
 	start := time.Now()
+	writeBatch := func(entries [][]byte) error {
+		return ic.writeBatch(topic, entries, printOffsets)
+	}
+	finish := func() error {
+		return nil
+	}
+	if useStream {
+		writer, err := newStreamWriter(ic.Ctx, ic.Client, func(ack *grpcApi.WriteAck) {
+			if printOffsets {
+				printWriteStatus(ack.Status)
+			}
+		})
+		if err != nil {
+			return "", err
+		}
+		writeBatch = func(entries [][]byte) error {
+			return writer.send(&grpcApi.InputEntries{
+				Topic:   topic,
+				Entries: entries,
+			})
+		}
+		finish = writer.close
+		defer writer.close()
+	}
 	var reader io.Reader
 
 	if len(fileName) == 0 {
@@ -119,30 +240,46 @@ func (ic *IbsenClient) Write(topic string, fileName ...string) (string, error) {
 		tmpBytes = append(tmpBytes, []byte(text))
 		batchSize = batchSize + 1
 		if batchSize == 1000 {
-			mes := grpcApi.InputEntries{
-				Topic:   topic,
-				Entries: tmpBytes,
-			}
-			_, err := ic.Client.Write(ic.Ctx, &mes)
+			err := writeBatch(tmpBytes)
 			if err != nil {
 				return "", err
 			}
-			entriesWritten = entriesWritten + len(mes.Entries)
+			entriesWritten = entriesWritten + len(tmpBytes)
 			batchSize = 0
 			tmpBytes = make([][]byte, 0)
 		}
 	}
 	if len(tmpBytes) > 0 {
-		mes := grpcApi.InputEntries{
-			Topic:   topic,
-			Entries: tmpBytes,
-		}
-		_, err := ic.Client.Write(ic.Ctx, &mes)
+		err := writeBatch(tmpBytes)
 		if err != nil {
 			return "", err
 		}
 		entriesWritten = entriesWritten + len(tmpBytes)
 	}
+	err := finish()
+	if err != nil {
+		return "", err
+	}
 	used := time.Now().Sub(start)
 	return fmt.Sprintf("Wrote %d to %s topic in %s\n", entriesWritten, topic, used), nil
 }
+
+func (ic *IbsenClient) writeBatch(topic string, entries [][]byte, printOffsets bool) error {
+	status, err := ic.Client.Write(ic.Ctx, &grpcApi.InputEntries{
+		Topic:   topic,
+		Entries: entries,
+	})
+	if err != nil {
+		return err
+	}
+	if printOffsets {
+		printWriteStatus(status)
+	}
+	return nil
+}
+
+func printWriteStatus(status *grpcApi.WriteStatus) {
+	if status.Wrote > 0 {
+		fmt.Printf("%d\t%d\n", status.FirstOffset, status.LastOffset)
+	}
+}
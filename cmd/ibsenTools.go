@@ -12,7 +12,7 @@ import (
 )
 
 func ReadLogFile(fileName string, batchSize uint32) error {
-	logChan := make(chan *[]common.LogEntry)
+	logChan := make(chan *common.EntryBatch)
 	var wg sync.WaitGroup
 	var fs = afero.NewOsFs()
 	afs := &afero.Afero{Fs: fs}
@@ -54,17 +54,17 @@ func ReadLogIndexFile(fileName string) error {
 	return nil
 }
 
-func sendBatchMessage(logChan chan *[]common.LogEntry, wg *sync.WaitGroup, terminate chan bool) {
+func sendBatchMessage(logChan chan *common.EntryBatch, wg *sync.WaitGroup, terminate chan bool) {
 	for {
 		select {
 		case <-terminate:
 			close(logChan)
 			return
 		case entryBatch := <-logChan:
-			batch := *entryBatch
-			for _, entry := range batch {
+			for _, entry := range entryBatch.Entries {
 				fmt.Printf("%d\t%s\n", entry.Offset, string(entry.Entry))
 			}
+			entryBatch.Release()
 			wg.Done()
 		}
 	}
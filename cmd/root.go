@@ -6,12 +6,16 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
+	"github.com/tcw/ibsen/access/common"
 	"github.com/tcw/ibsen/access/locking"
 	"github.com/tcw/ibsen/api"
+	"github.com/tcw/ibsen/api/grpcApi"
+	"github.com/tcw/ibsen/manager"
 	"net"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -21,6 +25,35 @@ var (
 	host                        string
 	port                        int
 	maxBlockSizeMB              int
+	corruptionPolicy            string
+	durability                  string
+	groupCommitWindow           time.Duration
+	topicConfigFile             string
+	retentionMaxAge             time.Duration
+	retentionMaxMB              int64
+	retentionMaxBlocks          int
+	compacted                   bool
+	tombstoneGracePeriod        time.Duration
+	compactionEvery             time.Duration
+	compression                 string
+	producerExpiry              time.Duration
+	indexCacheSizeMB            int64
+	indexWorkers                int
+	indexMaxMBPerSecond         int64
+	writeStreamWindow           int
+	httpPort                    int
+	readFromTime                string
+	readGroup                   string
+	readFromEnd                 uint64
+	writeOffsets                bool
+	writeStream                 bool
+	readFollow                  bool
+	readStop                    bool
+	readPrefix                  string
+	readContains                string
+	readRegex                   string
+	readJSONField               string
+	subscribePattern            string
 	readOnly                    bool
 	rootDirectory               string
 	benchEntiesByteSize         int
@@ -87,27 +120,76 @@ var (
 					log.Fatal().Msgf("data root path [%s] does not exist", rootDirectory)
 				}
 			}
+			policy, err := common.ParseCorruptionPolicy(corruptionPolicy)
+			if err != nil {
+				log.Fatal().Err(err).Msg("invalid corruption policy")
+			}
+			durabilityMode, err := common.ParseDurability(durability)
+			if err != nil {
+				log.Fatal().Err(err).Msg("invalid durability")
+			}
+			compressionCodec, err := common.ParseCompression(compression)
+			if err != nil {
+				log.Fatal().Err(err).Msg("invalid compression")
+			}
+			var topicConfigs map[common.TopicName]manager.TopicConfig
+			if topicConfigFile != "" {
+				configBytes, err := os.ReadFile(topicConfigFile)
+				if err != nil {
+					log.Fatal().Err(err).Msgf("unable to read topic config file %s", topicConfigFile)
+				}
+				topicConfigs, err = manager.ParseTopicConfigs(configBytes)
+				if err != nil {
+					log.Fatal().Err(err).Msgf("unable to parse topic config file %s", topicConfigFile)
+				}
+			}
 			writeLock := absolutePath + string(os.PathSeparator) + ".writeLock"
 			lock := locking.NewFileLock(afs, writeLock, time.Second*10, time.Second*5)
 			ibsenServer := api.IbsenServer{
-				Readonly:         readOnly,
-				Lock:             lock,
-				InMemory:         inMemory,
-				Afs:              afs,
-				RootPath:         absolutePath,
-				TTL:              30 * time.Second,
-				MaxBlockSize:     maxBlockSizeMB * 1024 * 1024,
-				OTELExporterAddr: OTELExporterAddr,
-				GRPCCertKey:      AbsOrEmpty(certKey),
-				GRPCPrivateKey:   AbsOrEmpty(privateKey),
-				CpuProfile:       cpuProfile,
-				MemProfile:       memProfile,
+				Readonly:          readOnly,
+				Lock:              lock,
+				InMemory:          inMemory,
+				Afs:               afs,
+				RootPath:          absolutePath,
+				TTL:               30 * time.Second,
+				MaxBlockSize:      maxBlockSizeMB * 1024 * 1024,
+				CorruptionPolicy:  policy,
+				Durability:        durabilityMode,
+				GroupCommitWindow: groupCommitWindow,
+				Retention: common.RetentionPolicy{
+					MaxAge:    retentionMaxAge,
+					MaxBytes:  retentionMaxMB * 1024 * 1024,
+					MaxBlocks: retentionMaxBlocks,
+				},
+				Compaction: common.CompactionPolicy{
+					Enabled:              compacted,
+					TombstoneGracePeriod: tombstoneGracePeriod,
+				},
+				CompactionEvery:        compactionEvery,
+				Compression:            compressionCodec,
+				ProducerExpiry:         producerExpiry,
+				IndexCacheSize:         indexCacheSizeMB * 1024 * 1024,
+				IndexWorkers:           indexWorkers,
+				IndexMaxBytesPerSecond: indexMaxMBPerSecond * 1024 * 1024,
+				WriteStreamWindow:      writeStreamWindow,
+				TopicConfigs:           topicConfigs,
+				OTELExporterAddr:       OTELExporterAddr,
+				GRPCCertKey:            AbsOrEmpty(certKey),
+				GRPCPrivateKey:         AbsOrEmpty(privateKey),
+				CpuProfile:             cpuProfile,
+				MemProfile:             memProfile,
 			}
 			lis, err := net.Listen("tcp", fmt.Sprintf("%s:%d", host, port))
 			if err != nil {
 				log.Err(err)
 				return
 			}
+			if httpPort > 0 {
+				ibsenServer.HTTPListener, err = net.Listen("tcp", fmt.Sprintf("%s:%d", host, httpPort))
+				if err != nil {
+					log.Fatal().Err(err).Msgf("unable to listen for http on port %d", httpPort)
+				}
+			}
 			err = ibsenServer.Start(lis)
 			if err != nil {
 				log.Fatal().Err(err)
@@ -199,6 +281,7 @@ var (
 			if err != nil {
 				log.Fatal().Err(err)
 			}
+			client.UseWriteStream = writeStream
 			benchmarkReport := ""
 			if concurrent > 1 {
 				benchmarkReport, err = client.BenchmarkConcurrent(topic, benchEntiesByteSize, benchEntiesInEachBatchWrite, benchWriteBatches, benchReadBatches, concurrent)
@@ -234,12 +317,12 @@ var (
 			result := ""
 
 			if len(args) > 1 {
-				result, err = client.Write(topic, args[1])
+				result, err = client.Write(topic, writeOffsets, writeStream, args[1])
 				if err != nil {
 					log.Fatal().Err(err)
 				}
 			} else {
-				result, err = client.Write(topic)
+				result, err = client.Write(topic, writeOffsets, writeStream)
 				if err != nil {
 					log.Fatal().Err(err)
 				}
@@ -267,6 +350,55 @@ var (
 		},
 	}
 
+	cmdClientDescribe = &cobra.Command{
+		Use:              "describe [topic]",
+		Short:            "describe topic with grpc client",
+		Long:             `show offsets, blocks, index position and last write time of a topic`,
+		TraverseChildren: true,
+		Args:             cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			client, err := newIbsenClient(host + ":" + strconv.Itoa(port))
+			if err != nil {
+				log.Fatal().Err(err)
+			}
+			result, err := client.Describe(args[0])
+			if err != nil {
+				log.Fatal().Err(err)
+			}
+			fmt.Println(result)
+		},
+	}
+
+	cmdClientSubscribe = &cobra.Command{
+		Use:              "subscribe [topic...]",
+		Short:            "subscribe to topics with grpc client",
+		Long:             `read several topics, and topics matching a pattern when they are created, in one stream`,
+		TraverseChildren: true,
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 0 && subscribePattern == "" {
+				fmt.Println("Topic names or a pattern is required")
+				return
+			}
+			client, err := newIbsenClient(host + ":" + strconv.Itoa(port))
+			if err != nil {
+				log.Fatal().Err(err)
+			}
+			params := &grpcApi.SubscribeParams{
+				Topics:    args,
+				Pattern:   subscribePattern,
+				BatchSize: 1000,
+			}
+			if cmd.Flags().Changed("from-end") {
+				params.StartPosition = grpcApi.StartPosition_LATEST_MINUS_N
+				params.Offset = readFromEnd
+			}
+			err = client.Subscribe(params)
+			if err != nil {
+				log.Fatal().Err(err)
+			}
+		},
+	}
+
 	cmdClientRead = &cobra.Command{
 		Use:              "read [file] [offset (default=0)] [batch size (default=1000)]",
 		Short:            "read with grpc client",
@@ -293,11 +425,33 @@ var (
 					fmt.Printf("offset %s not a uint64", args[1])
 				}
 			}
+			var fromTimestamp int64 = 0
+			if readFromTime != "" {
+				fromTime, err := time.Parse(time.RFC3339, readFromTime)
+				if err != nil {
+					log.Fatal().Err(err).Msgf("from time %s is not a RFC3339 time", readFromTime)
+				}
+				fromTimestamp = fromTime.UnixNano()
+			}
 			client, err := newIbsenClient(host + ":" + strconv.Itoa(port))
 			if err != nil {
 				log.Fatal().Err(err)
 			}
-			err = client.Read(topic, offset, uint32(batchSize64))
+			params := &grpcApi.ReadParams{
+				StopOnCompletion: readStop && !readFollow,
+				Topic:            topic,
+				Offset:           offset,
+				BatchSize:        uint32(batchSize64),
+				FromTimestamp:    fromTimestamp,
+				Group:            readGroup,
+				Filters:          readFilters(),
+			}
+			if cmd.Flags().Changed("from-end") {
+				params.StartPosition = grpcApi.StartPosition_LATEST_MINUS_N
+				params.Offset = readFromEnd
+				params.FromTimestamp = 0
+			}
+			err = client.Read(params, readFollow)
 			if err != nil {
 				log.Fatal().Err(err)
 			}
@@ -305,6 +459,30 @@ var (
 	}
 )
 
+// readFilters are the filters given as flags to read, entries must match all of them
+func readFilters() []*grpcApi.EntryFilter {
+	var filters []*grpcApi.EntryFilter
+	if readPrefix != "" {
+		filters = append(filters, &grpcApi.EntryFilter{Filter: &grpcApi.EntryFilter_Prefix{Prefix: []byte(readPrefix)}})
+	}
+	if readContains != "" {
+		filters = append(filters, &grpcApi.EntryFilter{Filter: &grpcApi.EntryFilter_Contains{Contains: []byte(readContains)}})
+	}
+	if readRegex != "" {
+		filters = append(filters, &grpcApi.EntryFilter{Filter: &grpcApi.EntryFilter_Regex{Regex: readRegex}})
+	}
+	if readJSONField != "" {
+		field, value, ok := strings.Cut(readJSONField, "=")
+		if !ok {
+			log.Fatal().Msgf("json field filter %s is not field=value", readJSONField)
+		}
+		filters = append(filters, &grpcApi.EntryFilter{Filter: &grpcApi.EntryFilter_JsonField{
+			JsonField: &grpcApi.JsonFieldFilter{Field: field, Value: value},
+		}})
+	}
+	return filters
+}
+
 func AbsOrEmpty(path string) string {
 	if path == "" {
 		return ""
@@ -330,6 +508,23 @@ func init() {
 	host = getenv("IBSEN_HOST", "0.0.0.0")
 	maxBlockSizeMB, _ = strconv.Atoi(getenv("IBSEN_MAX_BLOCK_SIZE", "1000"))
 	readOnly, _ = strconv.ParseBool(getenv("IBSEN_READ_ONLY", "false"))
+	corruptionPolicy = getenv("IBSEN_CORRUPTION_POLICY", "fail")
+	durability = getenv("IBSEN_DURABILITY", "none")
+	groupCommitWindow, _ = time.ParseDuration(getenv("IBSEN_GROUP_COMMIT_WINDOW", "2ms"))
+	topicConfigFile = getenv("IBSEN_TOPIC_CONFIG", "")
+	retentionMaxAge, _ = time.ParseDuration(getenv("IBSEN_RETENTION_MAX_AGE", "0s"))
+	retentionMaxMB, _ = strconv.ParseInt(getenv("IBSEN_RETENTION_MAX_SIZE", "0"), 10, 64)
+	retentionMaxBlocks, _ = strconv.Atoi(getenv("IBSEN_RETENTION_MAX_BLOCKS", "0"))
+	compacted, _ = strconv.ParseBool(getenv("IBSEN_COMPACTED", "false"))
+	tombstoneGracePeriod, _ = time.ParseDuration(getenv("IBSEN_TOMBSTONE_GRACE_PERIOD", "24h"))
+	compactionEvery, _ = time.ParseDuration(getenv("IBSEN_COMPACTION_EVERY", "10m"))
+	compression = getenv("IBSEN_COMPRESSION", "none")
+	producerExpiry, _ = time.ParseDuration(getenv("IBSEN_PRODUCER_EXPIRY", "168h"))
+	indexCacheSizeMB, _ = strconv.ParseInt(getenv("IBSEN_INDEX_CACHE_SIZE", "64"), 10, 64)
+	indexWorkers, _ = strconv.Atoi(getenv("IBSEN_INDEX_WORKERS", "4"))
+	indexMaxMBPerSecond, _ = strconv.ParseInt(getenv("IBSEN_INDEX_MAX_MB_PER_SECOND", "0"), 10, 64)
+	writeStreamWindow, _ = strconv.Atoi(getenv("IBSEN_WRITE_STREAM_WINDOW", "16"))
+	httpPort, _ = strconv.Atoi(getenv("IBSEN_HTTP_PORT", "0"))
 	rootDirectory = getenv("IBSEN_ROOT_DIRECTORY", "")
 
 	rootCmd.PersistentFlags().IntVarP(&port, "port", "p", port, "config file (default is current directory)")
@@ -342,7 +537,24 @@ func init() {
 
 	cmdServer.Flags().IntVarP(&maxBlockSizeMB, "maxBlockSize", "m", maxBlockSizeMB, "Max MB in log files")
 	cmdServer.Flags().BoolVarP(&readOnly, "readOnly", "o", readOnly, "set Ibsen in read only mode")
+	cmdServer.Flags().StringVarP(&corruptionPolicy, "corruptionPolicy", "", corruptionPolicy, "action on corrupt entries when reading (fail, skip, stop)")
 	cmdServer.Flags().StringVarP(&rootDirectory, "rootDirectory", "d", rootDirectory, "root directory - where ibsen will write all files")
+	cmdServer.Flags().StringVarP(&durability, "durability", "", durability, "when writes are synced to disk (none, fsync-per-write, group-commit)")
+	cmdServer.Flags().DurationVarP(&groupCommitWindow, "groupCommitWindow", "", groupCommitWindow, "time window for coalescing writes into one sync with group-commit durability")
+	cmdServer.Flags().StringVarP(&topicConfigFile, "topicConfig", "", topicConfigFile, "json file with settings overridden per topic")
+	cmdServer.Flags().DurationVarP(&retentionMaxAge, "retentionMaxAge", "", retentionMaxAge, "delete sealed log blocks not written to for this long (0 keeps all)")
+	cmdServer.Flags().Int64VarP(&retentionMaxMB, "retentionMaxSize", "", retentionMaxMB, "max MB kept in each topic before the oldest blocks are deleted (0 keeps all)")
+	cmdServer.Flags().IntVarP(&retentionMaxBlocks, "retentionMaxBlocks", "", retentionMaxBlocks, "max log blocks kept in each topic (0 keeps all)")
+	cmdServer.Flags().BoolVarP(&compacted, "compacted", "", compacted, "keep only the latest entry for each key in all topics, see topicConfig for single topics")
+	cmdServer.Flags().DurationVarP(&tombstoneGracePeriod, "tombstoneGracePeriod", "", tombstoneGracePeriod, "time a tombstone (keyed entry without payload) is kept in compacted topics")
+	cmdServer.Flags().DurationVarP(&compactionEvery, "compactionEvery", "", compactionEvery, "time between compaction of compacted topics (0 disables compaction)")
+	cmdServer.Flags().StringVarP(&compression, "compression", "", compression, "compression of sealed log blocks (none, gzip, zlib, flate)")
+	cmdServer.Flags().DurationVarP(&producerExpiry, "producerExpiry", "", producerExpiry, "time an idempotent producer is remembered after its last write (0 remembers all)")
+	cmdServer.Flags().Int64VarP(&indexCacheSizeMB, "indexCacheSize", "", indexCacheSizeMB, "max MB of sparse index kept in memory for all topics (0 reads index files on every read)")
+	cmdServer.Flags().IntVarP(&indexWorkers, "indexWorkers", "", indexWorkers, "max number of topics indexed concurrently")
+	cmdServer.Flags().Int64VarP(&indexMaxMBPerSecond, "indexMaxMBPerSecond", "", indexMaxMBPerSecond, "max MB of log read per second by all index workers (0 is unlimited)")
+	cmdServer.Flags().IntVarP(&writeStreamWindow, "writeStreamWindow", "", writeStreamWindow, "max number of batches received ahead of the batch being written in a write stream")
+	cmdServer.Flags().IntVarP(&httpPort, "httpPort", "", httpPort, "port of the HTTP/JSON gateway (0 is disabled)")
 	cmdServer.Flags().StringVarP(&cpuProfile, "cpuProfile", "z", "", "Profile cpu usage")
 	cmdServer.Flags().StringVarP(&memProfile, "memProfile", "y", "", "Profile memory usage")
 
@@ -350,13 +562,28 @@ func init() {
 	cmdClientBench.Flags().IntVarP(&benchEntiesInEachBatchWrite, "batchSize", "", 1000, "Entries in each batch in bench")
 	cmdClientBench.Flags().IntVarP(&benchWriteBatches, "bwb", "", 1000, "Write in batches of")
 	cmdClientBench.Flags().IntVarP(&benchReadBatches, "brb", "", 1000, "Read in batches of")
+	cmdClientBench.Flags().BoolVarP(&writeStream, "stream", "", false, "write batches on one write stream for each topic")
 	cmdClientBench.Flags().IntVarP(&concurrent, "concurrent", "", 1, "Concurrency number")
 
+	cmdClientWrite.Flags().BoolVarP(&writeStream, "stream", "", false, "send batches on one write stream, without waiting for each batch to be written")
+	cmdClientWrite.Flags().BoolVarP(&writeOffsets, "offsets", "", false, "print the first and last offset given to each batch written")
+	cmdClientRead.Flags().StringVarP(&readFromTime, "fromTime", "", "", "read from the first entry written at or after this RFC3339 time, instead of offset")
+	cmdClientRead.Flags().StringVarP(&readGroup, "group", "g", "", "consumer group, resumes from and commits the offset of the group")
+	cmdClientRead.Flags().Uint64VarP(&readFromEnd, "from-end", "", 0, "start this many entries before the end of the topic, instead of offset")
+	cmdClientRead.Flags().BoolVarP(&readFollow, "follow", "f", false, "keep reading entries as they are written, like tail -f")
+	cmdClientRead.Flags().BoolVarP(&readStop, "stop", "", false, "stop when the last entry written is read, ignored when following")
+	cmdClientRead.Flags().StringVarP(&readPrefix, "prefix", "", "", "only read entries starting with this text")
+	cmdClientRead.Flags().StringVarP(&readContains, "contains", "", "", "only read entries containing this text")
+	cmdClientRead.Flags().StringVarP(&readRegex, "regex", "", "", "only read entries matching this regular expression")
+	cmdClientRead.Flags().StringVarP(&readJSONField, "json-field", "", "", "only read JSON entries where field=value, nested fields are separated with dots")
+	cmdClientSubscribe.Flags().StringVarP(&subscribePattern, "pattern", "", "", "also read topics matching this pattern, like orders.*")
+	cmdClientSubscribe.Flags().Uint64VarP(&readFromEnd, "from-end", "", 0, "start this many entries before the end of each topic existing when the subscription starts")
+
 	//writeEntryByteSize int, writeEntriesInEachBatch int, writeBatches int, readBatchSize int
 
 	rootCmd.AddCommand(cmdServer, cmdClient, cmdTools)
 	cmdTools.AddCommand(cmdToolsReadIndexLogFile, cmdToolsReadLogFile)
-	cmdClient.AddCommand(cmdClientList, cmdClientWrite, cmdClientRead, cmdClientBench)
+	cmdClient.AddCommand(cmdClientList, cmdClientDescribe, cmdClientWrite, cmdClientRead, cmdClientSubscribe, cmdClientBench)
 }
 
 func getenv(key, fallback string) string {
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"bytes"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCommands_help_does_not_panic_on_flag_conflicts(t *testing.T) {
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	defer rootCmd.SetOut(nil)
+	defer rootCmd.SetErr(nil)
+	for _, command := range allCommands(rootCmd) {
+		args := append(strings.Fields(command.CommandPath())[1:], "--help")
+		rootCmd.SetArgs(args)
+		assert.NotPanics(t, func() {
+			err := rootCmd.Execute()
+			assert.Nil(t, err, command.CommandPath())
+		}, command.CommandPath())
+	}
+}
+
+func allCommands(command *cobra.Command) []*cobra.Command {
+	commands := []*cobra.Command{command}
+	for _, child := range command.Commands() {
+		commands = append(commands, allCommands(child)...)
+	}
+	return commands
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"github.com/tcw/ibsen/api/grpcApi"
+	"io"
+	"sync"
+)
+
+// streamWriter sends batches on a write stream without waiting for each acknowledgement, the
+// acknowledgements are received in the background
+type streamWriter struct {
+	stream   grpcApi.Ibsen_WriteStreamClient
+	received chan error
+	once     sync.Once
+	err      error
+}
+
+func newStreamWriter(ctx context.Context, client grpcApi.IbsenClient, onAck func(ack *grpcApi.WriteAck)) (*streamWriter, error) {
+	stream, err := client.WriteStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	writer := &streamWriter{
+		stream:   stream,
+		received: make(chan error, 1),
+	}
+	go writer.receiveAcks(onAck)
+	return writer, nil
+}
+
+func (w *streamWriter) receiveAcks(onAck func(ack *grpcApi.WriteAck)) {
+	for {
+		ack, err := w.stream.Recv()
+		if err == io.EOF {
+			w.received <- nil
+			return
+		}
+		if err != nil {
+			w.received <- err
+			return
+		}
+		if onAck != nil {
+			onAck(ack)
+		}
+	}
+}
+
+func (w *streamWriter) send(batch *grpcApi.InputEntries) error {
+	err := w.stream.Send(batch)
+	if err == io.EOF {
+		// the server ended the stream, the reason is received with the acknowledgements
+		return w.close()
+	}
+	return err
+}
+
+// close waits until all batches sent are acknowledged
+func (w *streamWriter) close() error {
+	w.once.Do(func() {
+		err := w.stream.CloseSend()
+		w.err = <-w.received
+		if w.err == nil {
+			w.err = err
+		}
+	})
+	return w.err
+}
@@ -0,0 +1,50 @@
+package manager
+
+import (
+	"github.com/tcw/ibsen/access/common"
+	"sync"
+)
+
+// writeBroadcast wakes up all readers waiting for new entries in a topic. Waiting readers hold the
+// current channel, which is closed and replaced on every write.
+type writeBroadcast struct {
+	mu      sync.Mutex
+	written chan struct{}
+}
+
+func newWriteBroadcast() *writeBroadcast {
+	return &writeBroadcast{written: make(chan struct{})}
+}
+
+func (b *writeBroadcast) await() <-chan struct{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.written
+}
+
+func (b *writeBroadcast) notify() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	close(b.written)
+	b.written = make(chan struct{})
+}
+
+// AwaitWrite returns a channel that is closed on the next write to the topic. Get the channel before
+// reading, so a write between reading and waiting is not missed.
+func (l *LogTopicsManager) AwaitWrite(topicName common.TopicName) <-chan struct{} {
+	return l.broadcast(topicName).await()
+}
+
+// AwaitAnyWrite returns a channel that is closed on the next write to any topic, including the first
+// write creating a topic
+func (l *LogTopicsManager) AwaitAnyWrite() <-chan struct{} {
+	return l.anyWrite.await()
+}
+
+func (l *LogTopicsManager) broadcast(topicName common.TopicName) *writeBroadcast {
+	broadcast, ok := l.TopicBroadcasts.Load(string(topicName))
+	if !ok {
+		broadcast, _ = l.TopicBroadcasts.LoadOrStore(string(topicName), newWriteBroadcast())
+	}
+	return broadcast.(*writeBroadcast)
+}
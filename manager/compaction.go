@@ -0,0 +1,47 @@
+package manager
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/tcw/ibsen/errore"
+	"time"
+)
+
+func (l *LogTopicsManager) ShutdownCompaction() {
+	l.CompactionTerminationChannel <- true
+}
+
+func (l *LogTopicsManager) startCompactionScheduler(terminate chan bool) {
+	ticker := time.NewTicker(l.Params.CompactionEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-terminate:
+			close(terminate)
+			return
+		case now := <-ticker.C:
+			l.compact(now)
+		}
+	}
+}
+
+func (l *LogTopicsManager) compact(now time.Time) {
+	topicNames := l.List()
+	// hidden from List, but compacted once it is used
+	if _, ok := l.Topics.Load(string(ConsumerOffsetsTopic)); ok {
+		topicNames = append(topicNames, ConsumerOffsetsTopic)
+	}
+	for _, topicName := range topicNames {
+		topic := l.getOrCreateTopic(topicName)
+		if !topic.Compaction.Enabled {
+			continue
+		}
+		// only sealed blocks are rewritten, so producers are not stopped while a topic is compacted
+		compacted, err := topic.Compact(now)
+		if err != nil {
+			log.Err(err).Str("topic", string(topicName)).
+				Str("stack", errore.SprintStackTraceBd(err)).
+				Int("compactedBlocks", len(compacted)).
+				Msg("compaction failed")
+		}
+	}
+}
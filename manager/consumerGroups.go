@@ -0,0 +1,128 @@
+package manager
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/tcw/ibsen/access/common"
+	"github.com/tcw/ibsen/errore"
+	"strings"
+	"sync"
+)
+
+// ConsumerOffsetsTopic holds the offsets committed by consumer groups. It is hidden from List by
+// the leading dot, and compacted so only the latest commit of each group and topic is kept.
+const ConsumerOffsetsTopic common.TopicName = ".consumer_offsets"
+
+var InvalidConsumerGroup = errors.New("consumer group must be a non empty name without null characters")
+
+// groupTopic is the key of a commit, topic names can not contain null characters
+type groupTopic struct {
+	group string
+	topic common.TopicName
+}
+
+func (g groupTopic) key() []byte {
+	return []byte(g.group + "\x00" + string(g.topic))
+}
+
+func parseGroupTopic(key []byte) (groupTopic, bool) {
+	group, topic, ok := strings.Cut(string(key), "\x00")
+	return groupTopic{group: group, topic: common.TopicName(topic)}, ok
+}
+
+// consumerOffsets is the latest committed offset of every group and topic, loaded from
+// ConsumerOffsetsTopic on first use. Commits are written to the topic before they are visible.
+type consumerOffsets struct {
+	mu      sync.Mutex
+	loaded  bool
+	offsets map[groupTopic]common.Offset
+}
+
+func newConsumerOffsets() *consumerOffsets {
+	return &consumerOffsets{
+		offsets: map[groupTopic]common.Offset{},
+	}
+}
+
+// CommitOffset stores the next offset the consumer group will read from the topic
+func (l *LogTopicsManager) CommitOffset(group string, topicName common.TopicName, offset common.Offset) error {
+	if !isValidConsumerGroup(group) {
+		return InvalidConsumerGroup
+	}
+	l.consumerOffsets.mu.Lock()
+	defer l.consumerOffsets.mu.Unlock()
+	err := l.loadConsumerOffsets()
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	commit := groupTopic{group: group, topic: topicName}
+	entries := [][]byte{common.Uint64ToLittleEndian(uint64(offset))}
+	_, err = l.Write(ConsumerOffsetsTopic, &entries, []common.EntryMetadata{{Key: commit.key()}})
+	if err != nil {
+		return errore.Wrap(err)
+	}
+	l.consumerOffsets.offsets[commit] = offset
+	return nil
+}
+
+// FetchCommittedOffset returns the offset last committed by the consumer group, false if the group
+// has not committed an offset for the topic
+func (l *LogTopicsManager) FetchCommittedOffset(group string, topicName common.TopicName) (common.Offset, bool, error) {
+	if !isValidConsumerGroup(group) {
+		return 0, false, InvalidConsumerGroup
+	}
+	l.consumerOffsets.mu.Lock()
+	defer l.consumerOffsets.mu.Unlock()
+	err := l.loadConsumerOffsets()
+	if err != nil {
+		return 0, false, errore.Wrap(err)
+	}
+	offset, ok := l.consumerOffsets.offsets[groupTopic{group: group, topic: topicName}]
+	return offset, ok, nil
+}
+
+func (l *LogTopicsManager) loadConsumerOffsets() error {
+	if l.consumerOffsets.loaded {
+		return nil
+	}
+	topic := l.getOrCreateTopic(ConsumerOffsetsTopic)
+	logBlocks := topic.LogBlocks()
+	if len(logBlocks) == 0 {
+		l.consumerOffsets.loaded = true
+		return nil
+	}
+	logChan := make(chan *common.EntryBatch)
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	go func() {
+		for batch := range logChan {
+			for _, entry := range batch.Entries {
+				commit, ok := parseGroupTopic(entry.Key)
+				if ok && len(entry.Entry) == 8 {
+					l.consumerOffsets.offsets[commit] = common.Offset(binary.LittleEndian.Uint64(entry.Entry))
+				}
+			}
+			batch.Release()
+			wg.Done()
+		}
+		close(done)
+	}()
+	_, err := topic.Read(common.ReadLogParams{
+		LogChan:   logChan,
+		Wg:        &wg,
+		From:      common.Offset(logBlocks[0]),
+		BatchSize: 1000,
+	})
+	wg.Wait()
+	close(logChan)
+	<-done
+	if err != nil && err != common.NoEntriesFound {
+		return errore.Wrap(err)
+	}
+	l.consumerOffsets.loaded = true
+	return nil
+}
+
+func isValidConsumerGroup(group string) bool {
+	return group != "" && !strings.Contains(group, "\x00")
+}
@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/tcw/ibsen/access/common"
+	"testing"
+)
+
+func TestLogTopicsManager_CommitOffset_survives_restart(t *testing.T) {
+	afs := common.MemAfs()
+	err := afs.Mkdir("tmp", 0600)
+	assert.Nil(t, err)
+	params := LogTopicManagerParams{
+		Afs:          afs,
+		MaxBlockSize: 100,
+		RootPath:     "tmp",
+	}
+	manager, err := NewLogTopicsManager(params)
+	assert.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		err = manager.CommitOffset("group1", "topic1", common.Offset(i))
+		assert.Nil(t, err)
+	}
+	err = manager.CommitOffset("group1", "topic2", 5)
+	assert.Nil(t, err)
+	err = manager.CommitOffset("group2", "topic1", 7)
+	assert.Nil(t, err)
+	manager.Shutdown()
+
+	restarted, err := NewLogTopicsManager(params)
+	assert.Nil(t, err)
+	offset, committed, err := restarted.FetchCommittedOffset("group1", "topic1")
+	assert.Nil(t, err)
+	assert.True(t, committed)
+	assert.Equal(t, common.Offset(9), offset)
+	offset, _, err = restarted.FetchCommittedOffset("group1", "topic2")
+	assert.Nil(t, err)
+	assert.Equal(t, common.Offset(5), offset)
+	offset, _, err = restarted.FetchCommittedOffset("group2", "topic1")
+	assert.Nil(t, err)
+	assert.Equal(t, common.Offset(7), offset)
+	_, committed, err = restarted.FetchCommittedOffset("group3", "topic1")
+	assert.Nil(t, err)
+	assert.False(t, committed)
+	assert.Empty(t, restarted.List())
+	restarted.Shutdown()
+}
+
+func TestLogTopicsManager_CommitOffset_invalid_group(t *testing.T) {
+	manager := LogTopicsManager{consumerOffsets: newConsumerOffsets()}
+	err := manager.CommitOffset("", "topic1", 0)
+	assert.Equal(t, InvalidConsumerGroup, err)
+	_, _, err = manager.FetchCommittedOffset("group\x00", "topic1")
+	assert.Equal(t, InvalidConsumerGroup, err)
+}
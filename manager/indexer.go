@@ -0,0 +1,164 @@
+package manager
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/tcw/ibsen/access"
+	"github.com/tcw/ibsen/access/common"
+	"github.com/tcw/ibsen/access/index"
+	"github.com/tcw/ibsen/errore"
+	"sync"
+	"time"
+)
+
+type indexState int
+
+const (
+	indexIdle indexState = iota
+	// indexQueued topics are waiting for a worker
+	indexQueued
+	// indexRunning topics are being indexed by a worker
+	indexRunning
+	// indexDirty topics were written to while being indexed, and are queued again when the worker is done
+	indexDirty
+)
+
+// indexWorkers indexes topics marked dirty by writes. A topic is indexed by at most one worker at a
+// time, and is queued at most once, no matter how many writes it gets while waiting.
+type indexWorkers struct {
+	mu       sync.Mutex
+	ready    *sync.Cond
+	states   map[common.TopicName]indexState
+	queue    []common.TopicName
+	stopped  bool
+	workers  sync.WaitGroup
+	throttle *ioThrottle
+}
+
+func newIndexWorkers(bytesPerSecond int64) *indexWorkers {
+	w := &indexWorkers{
+		states:   map[common.TopicName]indexState{},
+		throttle: &ioThrottle{bytesPerSecond: bytesPerSecond},
+	}
+	w.ready = sync.NewCond(&w.mu)
+	return w
+}
+
+// markDirty queues the topic for indexing, unless it is queued already
+func (w *indexWorkers) markDirty(topicName common.TopicName) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch w.states[topicName] {
+	case indexIdle:
+		w.states[topicName] = indexQueued
+		w.queue = append(w.queue, topicName)
+		w.ready.Signal()
+	case indexRunning:
+		w.states[topicName] = indexDirty
+	}
+}
+
+// next blocks until a topic is queued, returns false when stopped and the queue is drained
+func (w *indexWorkers) next() (common.TopicName, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for len(w.queue) == 0 && !w.stopped {
+		w.ready.Wait()
+	}
+	if len(w.queue) == 0 {
+		return "", false
+	}
+	topicName := w.queue[0]
+	w.queue = w.queue[1:]
+	w.states[topicName] = indexRunning
+	return topicName, true
+}
+
+func (w *indexWorkers) done(topicName common.TopicName) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.states[topicName] == indexDirty {
+		w.states[topicName] = indexQueued
+		w.queue = append(w.queue, topicName)
+		w.ready.Signal()
+		return
+	}
+	delete(w.states, topicName)
+}
+
+func (w *indexWorkers) start(workers int, indexTopic func(topicName common.TopicName, throttle index.ReadThrottle)) {
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		w.workers.Add(1)
+		go func() {
+			defer w.workers.Done()
+			for {
+				topicName, ok := w.next()
+				if !ok {
+					return
+				}
+				indexTopic(topicName, w.throttle.wait)
+				w.done(topicName)
+			}
+		}()
+	}
+}
+
+// stop lets the workers drain the queue, and waits for them to finish
+func (w *indexWorkers) stop() {
+	w.mu.Lock()
+	w.stopped = true
+	w.ready.Broadcast()
+	w.mu.Unlock()
+	w.workers.Wait()
+}
+
+// ioThrottle limits the number of bytes read per second, shared by all index workers. Every chunk read is
+// paid for afterwards, by delaying the next read.
+type ioThrottle struct {
+	mu             sync.Mutex
+	bytesPerSecond int64
+	next           time.Time
+}
+
+func (t *ioThrottle) wait(bytes int64) {
+	if t.bytesPerSecond <= 0 || bytes <= 0 {
+		return
+	}
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	t.next = t.next.Add(time.Duration(bytes * int64(time.Second) / t.bytesPerSecond))
+	delay := t.next.Sub(now)
+	t.mu.Unlock()
+	time.Sleep(delay)
+}
+
+// ShutdownIndexer indexes all topics with entries not yet indexed, and stops the index workers
+func (l *LogTopicsManager) ShutdownIndexer() {
+	l.Topics.Range(func(key, value any) bool {
+		l.indexer.markDirty(common.TopicName(key.(string)))
+		return true
+	})
+	l.indexer.stop()
+}
+
+func (l *LogTopicsManager) startIndexWorkers() {
+	l.indexer.start(l.Params.IndexWorkers, l.indexTopic)
+}
+
+func (l *LogTopicsManager) indexTopic(topicName common.TopicName, throttle index.ReadThrottle) {
+	topic, ok := l.Topics.Load(string(topicName))
+	if !ok {
+		return
+	}
+	_, err := topic.(*access.Topic).IndexNewEntries(throttle)
+	if err != nil {
+		log.Err(err).Str("topic", string(topicName)).
+			Str("stack", errore.SprintStackTraceBd(err)).
+			Msg("index builder failed")
+	}
+}
@@ -0,0 +1,68 @@
+package manager
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/tcw/ibsen/access/common"
+	"github.com/tcw/ibsen/access/index"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIndexWorkers_requeues_topic_written_while_indexing(t *testing.T) {
+	workers := newIndexWorkers(0)
+	indexing := make(chan struct{})
+	proceed := make(chan struct{})
+	var mu sync.Mutex
+	runs := 0
+	workers.start(4, func(topicName common.TopicName, throttle index.ReadThrottle) {
+		mu.Lock()
+		runs = runs + 1
+		first := runs == 1
+		mu.Unlock()
+		if first {
+			close(indexing)
+			<-proceed
+		}
+	})
+	workers.markDirty("topic1")
+	<-indexing
+	// written to while indexed, only one more run is needed for all the writes
+	workers.markDirty("topic1")
+	workers.markDirty("topic1")
+	close(proceed)
+	workers.stop()
+	assert.Equal(t, 2, runs)
+}
+
+func TestLogTopicsManager_ShutdownIndexer_indexes_written_entries(t *testing.T) {
+	afs := common.MemAfs()
+	err := afs.Mkdir("tmp", 0600)
+	assert.Nil(t, err)
+	manager, err := NewLogTopicsManager(LogTopicManagerParams{
+		Afs:          afs,
+		MaxBlockSize: 1,
+		IndexWorkers: 2,
+		RootPath:     "tmp",
+	})
+	assert.Nil(t, err)
+	entries := make([][]byte, 100)
+	for i := range entries {
+		entries[i] = []byte("entry")
+	}
+	_, err = manager.Write("topic1", &entries, nil)
+	assert.Nil(t, err)
+
+	manager.ShutdownIndexer()
+	exists, err := afs.Exists("tmp/topic1/00000000000000000000.idx")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+}
+
+func TestIoThrottle_delays_reads_over_rate(t *testing.T) {
+	throttle := &ioThrottle{bytesPerSecond: 1000}
+	start := time.Now()
+	throttle.wait(50)
+	throttle.wait(50)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
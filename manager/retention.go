@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"github.com/rs/zerolog/log"
+	"github.com/tcw/ibsen/errore"
+	"sync"
+	"time"
+)
+
+func (l *LogTopicsManager) ShutdownRetention() {
+	l.RetentionTerminationChannel <- true
+}
+
+func (l *LogTopicsManager) startRetentionScheduler(terminate chan bool) {
+	ticker := time.NewTicker(l.Params.RetentionCheckEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-terminate:
+			close(terminate)
+			return
+		case now := <-ticker.C:
+			l.applyRetention(now)
+		}
+	}
+}
+
+func (l *LogTopicsManager) applyRetention(now time.Time) {
+	for _, topicName := range l.List() {
+		topic := l.getOrCreateTopic(topicName)
+		locker, _ := l.TopicWriteLocker.LoadOrStore(string(topicName), &sync.Mutex{})
+		var mutex = locker.(*sync.Mutex)
+		mutex.Lock()
+		expired, err := topic.ExpireProducers(now)
+		mutex.Unlock()
+		if err != nil {
+			log.Err(err).Str("topic", string(topicName)).
+				Str("stack", errore.SprintStackTraceBd(err)).
+				Int("expiredProducers", expired).
+				Msg("producer expiry failed")
+		}
+		if !topic.Retention.IsEnabled() {
+			continue
+		}
+		mutex.Lock()
+		deleted, err := topic.ApplyRetention(now)
+		mutex.Unlock()
+		if err != nil {
+			log.Err(err).Str("topic", string(topicName)).
+				Str("stack", errore.SprintStackTraceBd(err)).
+				Int("deletedBlocks", len(deleted)).
+				Msg("retention failed")
+		}
+	}
+}
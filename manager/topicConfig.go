@@ -0,0 +1,99 @@
+package manager
+
+import (
+	"encoding/json"
+	"github.com/tcw/ibsen/access/common"
+	"github.com/tcw/ibsen/errore"
+	"time"
+)
+
+// TopicConfig overrides server wide settings for a single topic, unset fields keep the server setting.
+//
+// Example of a topic config file:
+//
+//	{
+//	  "orders": {"durability": "fsync-per-write", "retentionMaxAge": "168h"},
+//	  "clicks": {"durability": "none", "retentionMaxBytes": 10737418240, "compression": "gzip"},
+//	  "users": {"compacted": true, "tombstoneGracePeriod": "24h"}
+//	}
+type TopicConfig struct {
+	Durability           *common.Durability  `json:"durability"`
+	RetentionMaxAge      *Duration           `json:"retentionMaxAge"`
+	RetentionMaxBytes    *int64              `json:"retentionMaxBytes"`
+	RetentionMaxBlocks   *int                `json:"retentionMaxBlocks"`
+	Compacted            *bool               `json:"compacted"`
+	TombstoneGracePeriod *Duration           `json:"tombstoneGracePeriod"`
+	Compression          *common.Compression `json:"compression"`
+}
+
+// Duration is a time.Duration written as a duration string, like "72h", in config files
+type Duration time.Duration
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+func ParseTopicConfigs(bytes []byte) (map[common.TopicName]TopicConfig, error) {
+	var configs map[common.TopicName]TopicConfig
+	err := json.Unmarshal(bytes, &configs)
+	if err != nil {
+		return nil, errore.WrapWithContextF(err, "invalid topic config")
+	}
+	return configs, nil
+}
+
+func (l *LogTopicsManager) topicParams(topicName common.TopicName) common.TopicParams {
+	params := common.TopicParams{
+		ReadOnly:          l.Params.ReadOnly,
+		Afs:               l.Params.Afs,
+		RootPath:          l.Params.RootPath,
+		TopicName:         string(topicName),
+		MaxBlockSize:      l.Params.MaxBlockSize,
+		CorruptionPolicy:  l.Params.CorruptionPolicy,
+		Durability:        l.Params.Durability,
+		GroupCommitWindow: l.Params.GroupCommitWindow,
+		Retention:         l.Params.Retention,
+		Compaction:        l.Params.Compaction,
+		Compression:       l.Params.Compression,
+		ProducerExpiry:    l.Params.ProducerExpiry,
+		IndexCacheSize:    l.Params.IndexCacheSize,
+	}
+	if topicName == ConsumerOffsetsTopic {
+		// commits are kept until a newer commit of the same group and topic replaces them
+		params.Durability = common.FsyncPerWrite
+		params.Retention = common.RetentionPolicy{}
+		params.Compaction.Enabled = true
+		return params
+	}
+	config, ok := l.Params.TopicConfigs[topicName]
+	if !ok {
+		return params
+	}
+	if config.Durability != nil {
+		params.Durability = *config.Durability
+	}
+	if config.RetentionMaxAge != nil {
+		params.Retention.MaxAge = time.Duration(*config.RetentionMaxAge)
+	}
+	if config.RetentionMaxBytes != nil {
+		params.Retention.MaxBytes = *config.RetentionMaxBytes
+	}
+	if config.RetentionMaxBlocks != nil {
+		params.Retention.MaxBlocks = *config.RetentionMaxBlocks
+	}
+	if config.Compacted != nil {
+		params.Compaction.Enabled = *config.Compacted
+	}
+	if config.TombstoneGracePeriod != nil {
+		params.Compaction.TombstoneGracePeriod = time.Duration(*config.TombstoneGracePeriod)
+	}
+	if config.Compression != nil {
+		params.Compression = *config.Compression
+	}
+	return params
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/spf13/afero"
 	"github.com/tcw/ibsen/access"
 	"github.com/tcw/ibsen/access/common"
+	"github.com/tcw/ibsen/access/index"
 	"github.com/tcw/ibsen/errore"
 	"sync"
 	"time"
@@ -14,63 +15,200 @@ import (
 
 type ReadParams struct {
 	TopicName common.TopicName
-	LogChan   chan *[]common.LogEntry
+	LogChan   chan *common.EntryBatch
 	Wg        *sync.WaitGroup
 	From      common.Offset
 	BatchSize uint32
+	// EndOffset, MaxEntries and MaxBytes bound the read, see common.ReadLogParams
+	EndOffset  common.Offset
+	MaxEntries uint64
+	MaxBytes   uint64
+	// Filter selects the entries sent, nil sends all entries
+	Filter common.EntryFilter
 }
 
 type LogManager interface {
 	List() []common.TopicName
-	Write(topic common.TopicName, entries common.EntriesPtr) error
-	Read(params ReadParams) error
+	Write(topic common.TopicName, entries common.EntriesPtr, metadata []common.EntryMetadata) (common.Offset, error)
+	WriteIfNextOffset(topic common.TopicName, entries common.EntriesPtr, metadata []common.EntryMetadata, expectedNextOffset common.Offset) (common.Offset, error)
+	WriteFromProducer(topic common.TopicName, entries common.EntriesPtr, metadata []common.EntryMetadata, producer common.ProducerBatch, expectedNextOffset *common.Offset) (access.ProducerWrite, error)
+	Read(params ReadParams) (common.Offset, error)
+	OffsetForTimestamp(topic common.TopicName, timestamp int64) (common.Offset, error)
+	OffsetRange(topic common.TopicName) (common.Offset, common.Offset)
+	DescribeTopic(topic common.TopicName) (access.TopicDescription, error)
+	AwaitWrite(topic common.TopicName) <-chan struct{}
+	AwaitAnyWrite() <-chan struct{}
+	CommitOffset(group string, topic common.TopicName, offset common.Offset) error
+	FetchCommittedOffset(group string, topic common.TopicName) (common.Offset, bool, error)
 }
 
 var _ LogManager = &LogTopicsManager{}
 
 type LogTopicManagerParams struct {
-	ReadOnly         bool
-	Afs              *afero.Afero
-	TTL              time.Duration
-	CheckForNewEvery time.Duration
-	MaxBlockSize     int
-	RootPath         string
+	ReadOnly            bool
+	Afs                 *afero.Afero
+	TTL                 time.Duration
+	MaxBlockSize        int
+	CorruptionPolicy    common.CorruptionPolicy
+	Durability          common.Durability
+	GroupCommitWindow   time.Duration
+	Retention           common.RetentionPolicy
+	RetentionCheckEvery time.Duration
+	Compaction          common.CompactionPolicy
+	CompactionEvery     time.Duration
+	Compression         common.Compression
+	ProducerExpiry      time.Duration
+	// IndexCacheSize is the max bytes of sparse index kept in memory for all topics
+	IndexCacheSize      int64
+	IndexWorkers        int
+	IndexBytesPerSecond int64
+	TopicConfigs        map[common.TopicName]TopicConfig
+	RootPath            string
 }
 
 type LogTopicsManager struct {
-	Params             LogTopicManagerParams
-	TopicWriteLocker   *sync.Map
-	Topics             *sync.Map
-	TerminationChannel chan bool
-	StatusAccess       access.StatusAccess
+	Params                       LogTopicManagerParams
+	TopicWriteLocker             *sync.Map
+	Topics                       *sync.Map
+	TopicBroadcasts              *sync.Map
+	anyWrite                     *writeBroadcast
+	RetentionTerminationChannel  chan bool
+	CompactionTerminationChannel chan bool
+	StatusAccess                 access.StatusAccess
+	indexer                      *indexWorkers
+	indexCache                   *index.Cache
+	consumerOffsets              *consumerOffsets
 }
 
 var TopicNotFound = errors.New("topic not found")
 
+// OffsetMismatchError is returned by a conditional write when the topic has moved past the expected offset
+type OffsetMismatchError struct {
+	Topic    common.TopicName
+	Expected common.Offset
+	Actual   common.Offset
+}
+
+func (e *OffsetMismatchError) Error() string {
+	return fmt.Sprintf("expected next offset [%d] in topic [%s], actual next offset is [%d]", e.Expected, e.Topic, e.Actual)
+}
+
 func NewLogTopicsManager(params LogTopicManagerParams) (LogTopicsManager, error) {
 	manager := LogTopicsManager{
-		Params:             params,
-		TopicWriteLocker:   &sync.Map{},
-		Topics:             &sync.Map{},
-		TerminationChannel: make(chan bool),
+		Params:                       params,
+		TopicWriteLocker:             &sync.Map{},
+		Topics:                       &sync.Map{},
+		TopicBroadcasts:              &sync.Map{},
+		anyWrite:                     newWriteBroadcast(),
+		RetentionTerminationChannel:  make(chan bool),
+		CompactionTerminationChannel: make(chan bool),
 		StatusAccess: &access.Status{
 			Afs:      params.Afs,
 			RootPath: params.RootPath,
 		},
+		indexer:         newIndexWorkers(params.IndexBytesPerSecond),
+		indexCache:      index.NewCache(params.IndexCacheSize),
+		consumerOffsets: newConsumerOffsets(),
+	}
+	manager.startIndexWorkers()
+	if !params.ReadOnly && params.RetentionCheckEvery > 0 {
+		go manager.startRetentionScheduler(manager.RetentionTerminationChannel)
+	}
+	if !params.ReadOnly && params.CompactionEvery > 0 {
+		go manager.startCompactionScheduler(manager.CompactionTerminationChannel)
 	}
-	go manager.startIndexScheduler(manager.TerminationChannel)
 	return manager, nil
 }
 
-func (l *LogTopicsManager) ShutdownIndexer() {
-	l.TerminationChannel <- true
+// Shutdown stops the schedulers and the index workers, after indexing entries not yet indexed, and
+// closes the head block of all topics
+func (l *LogTopicsManager) Shutdown() {
+	if !l.Params.ReadOnly && l.Params.RetentionCheckEvery > 0 {
+		l.ShutdownRetention()
+	}
+	if !l.Params.ReadOnly && l.Params.CompactionEvery > 0 {
+		l.ShutdownCompaction()
+	}
+	l.ShutdownIndexer()
+	l.Topics.Range(func(key, value any) bool {
+		err := value.(*access.Topic).Close()
+		if err != nil {
+			log.Err(err).Str("topic", key.(string)).Msg("unable to close head block")
+		}
+		return true
+	})
 }
 
 func (l *LogTopicsManager) List() []common.TopicName {
 	return l.StatusAccess.List()
 }
 
-func (l *LogTopicsManager) Write(topicName common.TopicName, entries common.EntriesPtr) error {
+// Write returns the offset of the first entry written, the entries in the batch have consecutive offsets
+func (l *LogTopicsManager) Write(topicName common.TopicName, entries common.EntriesPtr, metadata []common.EntryMetadata) (common.Offset, error) {
+	var firstOffset common.Offset
+	err := l.write(topicName, func(topic *access.Topic) (bool, error) {
+		var err error
+		firstOffset, err = topic.Write(entries, metadata)
+		return err == nil, err
+	})
+	return firstOffset, err
+}
+
+// WriteIfNextOffset writes the entries only if the next offset of the topic is expectedNextOffset, and
+// returns an *OffsetMismatchError if it is not. Concurrent writers can use it to append to a topic
+// without overwriting each others assumptions of the topic.
+func (l *LogTopicsManager) WriteIfNextOffset(topicName common.TopicName, entries common.EntriesPtr, metadata []common.EntryMetadata, expectedNextOffset common.Offset) (common.Offset, error) {
+	var firstOffset common.Offset
+	err := l.write(topicName, func(topic *access.Topic) (bool, error) {
+		err := checkNextOffset(topicName, topic, expectedNextOffset)
+		if err != nil {
+			return false, err
+		}
+		firstOffset, err = topic.Write(entries, metadata)
+		return err == nil, err
+	})
+	return firstOffset, err
+}
+
+// WriteFromProducer writes a batch from an idempotent producer, a retry of a batch the producer has
+// written already is acknowledged without writing it again. If expectedNextOffset is not nil, a new
+// batch is only written if it is the next offset of the topic, see WriteIfNextOffset.
+func (l *LogTopicsManager) WriteFromProducer(topicName common.TopicName, entries common.EntriesPtr, metadata []common.EntryMetadata,
+	producer common.ProducerBatch, expectedNextOffset *common.Offset) (access.ProducerWrite, error) {
+	var written access.ProducerWrite
+	err := l.write(topicName, func(topic *access.Topic) (bool, error) {
+		retry, isRetry := topic.ProducerRetry(producer)
+		if isRetry {
+			written = retry
+			return false, nil
+		}
+		if expectedNextOffset != nil {
+			err := checkNextOffset(topicName, topic, *expectedNextOffset)
+			if err != nil {
+				return false, err
+			}
+		}
+		var err error
+		written, err = topic.WriteFromProducer(entries, metadata, producer)
+		return written.Entries > 0, err
+	})
+	return written, err
+}
+
+func checkNextOffset(topicName common.TopicName, topic *access.Topic, expectedNextOffset common.Offset) error {
+	if topic.NextOffset != expectedNextOffset {
+		return &OffsetMismatchError{
+			Topic:    topicName,
+			Expected: expectedNextOffset,
+			Actual:   topic.NextOffset,
+		}
+	}
+	return nil
+}
+
+// write calls write while holding the topic write lock, readers and the indexer are notified if it
+// wrote entries to the topic
+func (l *LogTopicsManager) write(topicName common.TopicName, write func(topic *access.Topic) (bool, error)) error {
 	if l.Params.ReadOnly {
 		return errors.New("ibsen is in read only mode and will not accept any writes")
 	}
@@ -78,37 +216,103 @@ func (l *LogTopicsManager) Write(topicName common.TopicName, entries common.Entr
 	locker, _ := l.TopicWriteLocker.LoadOrStore(string(topicName), &sync.Mutex{})
 	var mutex = locker.(*sync.Mutex)
 	mutex.Lock()
-	defer mutex.Unlock()
-	return topic.Write(entries)
+	wrote, err := write(topic)
+	mutex.Unlock()
+	if wrote {
+		l.broadcast(topicName).notify()
+		l.anyWrite.notify()
+		l.indexer.markDirty(topicName)
+	}
+	if err != nil {
+		return err
+	}
+	// writers waiting for a group commit are not holding the topic lock, so they can share one sync
+	return topic.AwaitDurable()
 }
 
-func (l *LogTopicsManager) Read(params ReadParams) error {
+// Read reads a topic, and returns the offset a following read continues from, see access.Topic Read
+func (l *LogTopicsManager) Read(params ReadParams) (common.Offset, error) {
 	topic := l.getOrCreateTopic(params.TopicName)
 	readFrom := params.From
 	return topic.Read(common.ReadLogParams{
-		LogChan:   params.LogChan,
-		Wg:        params.Wg,
-		From:      readFrom,
-		BatchSize: params.BatchSize,
+		LogChan:    params.LogChan,
+		Wg:         params.Wg,
+		From:       readFrom,
+		BatchSize:  params.BatchSize,
+		EndOffset:  params.EndOffset,
+		MaxEntries: params.MaxEntries,
+		MaxBytes:   params.MaxBytes,
+		Filter:     params.Filter,
 	})
 }
 
+func (l *LogTopicsManager) OffsetForTimestamp(topicName common.TopicName, timestamp int64) (common.Offset, error) {
+	topic := l.getOrCreateTopic(topicName)
+	return topic.FindOffsetForTimestamp(timestamp)
+}
+
+// OffsetRange returns the offset of the first entry that can be read from the topic, and the offset
+// of the next entry written to it. Both are the next offset when the topic has no entries.
+func (l *LogTopicsManager) OffsetRange(topicName common.TopicName) (common.Offset, common.Offset) {
+	topic := l.getOrCreateTopic(topicName)
+	next := topic.HighWatermark()
+	logBlocks := topic.LogBlocks()
+	if len(logBlocks) == 0 {
+		return next, next
+	}
+	return common.Offset(logBlocks[0]), next
+}
+
+// DescribeTopic returns the offsets, blocks and index status of an existing topic
+func (l *LogTopicsManager) DescribeTopic(topicName common.TopicName) (access.TopicDescription, error) {
+	if !l.exists(topicName) {
+		return access.TopicDescription{}, TopicNotFound
+	}
+	topic := l.getOrCreateTopic(topicName)
+	locker, _ := l.TopicWriteLocker.LoadOrStore(string(topicName), &sync.Mutex{})
+	description, err := topic.Describe(locker.(*sync.Mutex))
+	if err != nil {
+		return access.TopicDescription{}, errore.Wrap(err)
+	}
+	return description, nil
+}
+
+func (l *LogTopicsManager) exists(topicName common.TopicName) bool {
+	if _, ok := l.Topics.Load(string(topicName)); ok {
+		return true
+	}
+	for _, name := range l.List() {
+		if name == topicName {
+			return true
+		}
+	}
+	return false
+}
+
+// getOrCreateTopic returns the loaded topic, or loads it. Loading recovers the files of the topic, so
+// a topic is loaded once, under the topic write lock.
 func (l *LogTopicsManager) getOrCreateTopic(name common.TopicName) *access.Topic {
 	topic, ok := l.Topics.Load(string(name))
-	if !ok {
-		topic = l.loadOrCreateNewTopic(name)
-		topic, _ = l.Topics.LoadOrStore(string(name), topic)
+	if ok {
+		return topic.(*access.Topic)
+	}
+	locker, _ := l.TopicWriteLocker.LoadOrStore(string(name), &sync.Mutex{})
+	var mutex = locker.(*sync.Mutex)
+	mutex.Lock()
+	defer mutex.Unlock()
+	topic, ok = l.Topics.Load(string(name))
+	if ok {
+		return topic.(*access.Topic)
 	}
-	return topic.(*access.Topic)
+	loaded := l.loadOrCreateNewTopic(name)
+	l.Topics.Store(string(name), loaded)
+	// index entries written before the topic was loaded
+	l.indexer.markDirty(name)
+	return loaded
 }
 
 func (l *LogTopicsManager) loadOrCreateNewTopic(topicName common.TopicName) *access.Topic {
-	topic := access.NewLogTopic(common.TopicParams{
-		Afs:          l.Params.Afs,
-		RootPath:     l.Params.RootPath,
-		TopicName:    string(topicName),
-		MaxBlockSize: l.Params.MaxBlockSize,
-	})
+	topic := access.NewLogTopicWithIndexCache(l.topicParams(topicName), l.indexCache)
 	err := topic.LoadOrCreate()
 	if err == common.NoBlocksFound {
 		log.Err(err).Str("topic", string(topicName)).
@@ -122,22 +326,3 @@ func (l *LogTopicsManager) loadOrCreateNewTopic(topicName common.TopicName) *acc
 	}
 	return topic
 }
-
-func (l *LogTopicsManager) startIndexScheduler(terminate chan bool) {
-	for {
-		select {
-		case <-terminate:
-			close(terminate)
-			return
-		default:
-			time.Sleep(time.Second * 10)
-			l.Topics.Range(func(key, value any) bool {
-				_, err := value.(*access.Topic).UpdateIndex()
-				if err != nil {
-					log.Err(err).Msg(fmt.Sprintf("index builder for topic %s has failed", key.(string)))
-				}
-				return true
-			})
-		}
-	}
-}
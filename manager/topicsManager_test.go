@@ -0,0 +1,89 @@
+package manager
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/tcw/ibsen/access/common"
+	"sync"
+	"testing"
+)
+
+func TestLogTopicsManager_WriteIfNextOffset_only_one_concurrent_writer_succeeds(t *testing.T) {
+	afs := common.MemAfs()
+	err := afs.Mkdir("tmp", 0600)
+	assert.Nil(t, err)
+	manager, err := NewLogTopicsManager(LogTopicManagerParams{
+		Afs:          afs,
+		MaxBlockSize: 1024,
+		RootPath:     "tmp",
+	})
+	assert.Nil(t, err)
+	entries := [][]byte{[]byte("event")}
+	_, err = manager.Write("topic1", &entries, nil)
+	assert.Nil(t, err)
+
+	var wg sync.WaitGroup
+	results := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entries := [][]byte{[]byte("event")}
+			_, err := manager.WriteIfNextOffset("topic1", &entries, nil, 1)
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+	succeeded := 0
+	for err := range results {
+		if err == nil {
+			succeeded = succeeded + 1
+			continue
+		}
+		var mismatch *OffsetMismatchError
+		assert.True(t, errors.As(err, &mismatch))
+		assert.Equal(t, common.Offset(1), mismatch.Expected)
+		assert.Equal(t, common.Offset(2), mismatch.Actual)
+	}
+	assert.Equal(t, 1, succeeded)
+
+	firstOffset, err := manager.WriteIfNextOffset("topic1", &entries, nil, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, common.Offset(2), firstOffset)
+	manager.Shutdown()
+}
+
+func TestLogTopicsManager_concurrent_first_writes_load_topic_once(t *testing.T) {
+	afs := common.MemAfs()
+	err := afs.Mkdir("tmp", 0600)
+	assert.Nil(t, err)
+	params := LogTopicManagerParams{
+		Afs:          afs,
+		MaxBlockSize: 1024,
+		RootPath:     "tmp",
+	}
+	manager, err := NewLogTopicsManager(params)
+	assert.Nil(t, err)
+	entries := [][]byte{[]byte("event")}
+	_, err = manager.Write("topic1", &entries, nil)
+	assert.Nil(t, err)
+	manager.Shutdown()
+
+	restarted, err := NewLogTopicsManager(params)
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entries := [][]byte{[]byte("event")}
+			_, err := restarted.Write("topic1", &entries, nil)
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+	_, next := restarted.OffsetRange("topic1")
+	assert.Equal(t, common.Offset(11), next)
+	restarted.Shutdown()
+}